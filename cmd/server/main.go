@@ -29,19 +29,18 @@ func main() {
 		zap.L().Error("Failed to initialize database", zap.Error(err))
 	}
 
+	if err := services.EncryptLLMSettingsKeys(); err != nil {
+		zap.L().Error("Failed to encrypt LLM api keys", zap.Error(err))
+	}
 	llmSettings, err := database.GetLLMSettings()
 	if err != nil {
 		zap.L().Error("Failed to get LLM settings", zap.Error(err))
+	} else if err = services.ApplyLLMSettings(llmSettings); err != nil {
+		zap.L().Error("Failed to apply LLM settings", zap.Error(err))
 	}
 
-	cfg.LLM.ProviderType = llmSettings.ProviderType
-	cfg.LLM.APIKey = llmSettings.APIKey
-	cfg.LLM.Model = llmSettings.ModelLLM
-	cfg.LLM.MaxTokens = llmSettings.MaxTokens
-	cfg.LLM.Temperature = llmSettings.Temperature
-
 	// Initialize task queue with config
-	taskQueue := services.NewTaskQueue(cfg.Repository.Dir)
+	taskQueue := services.NewTaskQueue(cfg.Repository.Dir, &cfg.Task)
 	if taskQueue == nil {
 		zap.L().Error("Failed to initialize task queue")
 		return
@@ -57,6 +56,11 @@ func main() {
 
 	// Setup Gin router
 	router := gin.Default()
+	// the client address owns the submitted tasks, only trusted proxies may forward it
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		zap.L().Error("Invalid trusted proxies", zap.Error(err))
+		return
+	}
 
 	router.Use(Serve("/", EmbedFolder(templateFS, "web")))
 	router.NoRoute(func(c *gin.Context) {
@@ -74,7 +78,7 @@ func main() {
 	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
 	router.Use(cors.New(corsConfig))
 	// Register API routes
-	api.RegisterRoutes(router)
+	api.RegisterRoutes(router, taskQueue)
 
 	// Start server
 	zap.L().Info("Starting server", zap.String("address", cfg.Server.Address)) // Use zap for logging in main.go instead of in api.go or htt
@@ -56,6 +56,10 @@ func (i *CodeIndexer) IndexCodeFile(filePath string, warehouseID string) error {
 	if i.inited {
 		return nil
 	}
+	return i.indexCodeFile(filePath, warehouseID)
+}
+
+func (i *CodeIndexer) indexCodeFile(filePath string, warehouseID string) error {
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return fmt.Errorf("file not found: %s", filePath)
@@ -113,6 +113,39 @@ func (s *CodeMapService) IndexRepository(repoPath, warehouseID string) (bool, er
 	return true, nil
 }
 
+// UpdateFiles indexes the changed files of a loaded index again and drops the deleted ones, the
+// embeddings of the other files are kept. The dependencies only need the local files, they are
+// analyzed from scratch. The paths are relative to repoPath.
+func (s *CodeMapService) UpdateFiles(repoPath, warehouseID string, changed, deleted []string) error {
+	s.analyzer = NewDependencyAnalyzer(s.analyzer.BasePath)
+	s.indexer.analyzer = s.analyzer
+	if err := s.analyzer.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize analyzer: %w", err)
+	}
+
+	var stale = make(map[string]bool)
+	for _, file := range append(changed, deleted...) {
+		stale[filepath.Join(repoPath, file)] = true
+	}
+	for _, record := range s.embedding.ListEmbeddings() {
+		if stale[record.Metadata["file_path"]] {
+			s.embedding.DeleteEmbedding(record.ID)
+		}
+	}
+
+	for _, file := range changed {
+		path := filepath.Join(repoPath, file)
+		if !isSupportedExtension(filepath.Ext(path)) {
+			continue
+		}
+		if err := s.indexer.indexCodeFile(path, warehouseID); err != nil {
+			return fmt.Errorf("failed to index file %s: %w", path, err)
+		}
+	}
+	s.indexer.inited = true
+	return nil
+}
+
 // SearchCode searches for code matching the query
 func (s *CodeMapService) SearchCode(query, warehouseID string, limit int) ([]SearchResult, error) {
 	return s.indexer.SearchCode(query, warehouseID, limit, 0.3) // 0.3 is the minimum relevance threshold
@@ -1,9 +1,14 @@
 package analyzer
 
 import (
+	"context"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/o0olele/opendeepwiki-go/internal/llm/chat"
+	"github.com/o0olele/opendeepwiki-go/internal/progress"
+	"golang.org/x/sync/errgroup"
 )
 
 // PathInfo 表示文件或目录的路径信息
@@ -35,6 +40,16 @@ type CommitRecord struct {
 	Description string    `json:"description"` // 描述
 	Date        time.Time `json:"date"`        // 日期
 	Author      string    `json:"author"`      // 作者
+	CommitID    string    `json:"commit_id"`   // 最新提交
+}
+
+// CommitGroup 表示一个版本或时间窗口内的提交
+type CommitGroup struct {
+	Title    string    `json:"title"`     // 版本标签或时间窗口
+	CommitID string    `json:"commit_id"` // 最新提交
+	Date     time.Time `json:"date"`      // 最新提交日期
+	Authors  []string  `json:"authors"`   // 作者
+	Messages []string  `json:"messages"`  // 提交信息
 }
 
 // WikiDocument 表示生成的 Wiki 文档
@@ -58,22 +73,9 @@ type DocumentResultCalalogue struct {
 	Items []DocumentResultCalalogueItem `json:"items"`
 }
 
-func (c *DocumentResultCalalogue) Generate(provider chat.Provider, r *Repository, wiki *WikiDocument) {
-	for idx := range c.Items {
-		item := &c.Items[idx]
-
-		// generate wiki item
-		tmpWiki, err := r.generateCatalogueItem(provider, item)
-		if err != nil {
-			continue
-		}
-
-		// generate children
-		item.Generate(provider, r, tmpWiki)
-		// append to wiki children
-		wiki.Children = append(wiki.Children, tmpWiki)
-	}
-
+// Generate generates the documents of the catalogue, it stops at the first document that fails.
+func (c *DocumentResultCalalogue) Generate(ctx context.Context, provider chat.Provider, r *Repository, wiki *WikiDocument) error {
+	return generateCatalogueItems(ctx, provider, r, c.Items, wiki, "")
 }
 
 type DocumentResultCalalogueItem struct {
@@ -84,20 +86,100 @@ type DocumentResultCalalogueItem struct {
 	Children       []DocumentResultCalalogueItem `json:"children"`
 }
 
-func (c *DocumentResultCalalogueItem) Generate(provider chat.Provider, r *Repository, wiki *WikiDocument) {
+// Generate generates the children of the item, key is the checkpoint key of the item.
+func (c *DocumentResultCalalogueItem) Generate(ctx context.Context, provider chat.Provider, r *Repository, wiki *WikiDocument, key string) error {
 	if len(c.Children) == 0 {
-		return
+		return nil
 	}
-	for idx := range c.Children {
-		item := &c.Children[idx]
+	return generateCatalogueItems(ctx, provider, r, c.Children, wiki, key)
+}
 
-		tmpWiki, err := r.generateCatalogueItem(provider, item)
-		if err != nil {
-			continue
+// generateCatalogueItems generates sibling items concurrently, the documents keep the catalogue order.
+// The model calls are bounded by the repository limiter, a slot is not held while waiting for children.
+// The first failure cancels the siblings and is returned, the saved checkpoints let a retry resume.
+func generateCatalogueItems(ctx context.Context, provider chat.Provider, r *Repository,
+	items []DocumentResultCalalogueItem, wiki *WikiDocument, parentKey string) error {
+
+	var results = make([]*WikiDocument, len(items))
+	group, ctx := errgroup.WithContext(ctx)
+	for idx := range items {
+		item := &items[idx]
+		key := strconv.Itoa(idx + 1)
+		if len(parentKey) > 0 {
+			key = parentKey + "." + key
 		}
 
-		item.Generate(provider, r, tmpWiki)
+		group.Go(func() error {
+			if !r.acquire(ctx) {
+				return ctx.Err()
+			}
+			tmpWiki, err := r.checkpointCatalogueItem(progress.WithKey(ctx, key), provider, key, item)
+			r.release()
+			if err != nil {
+				return fmt.Errorf("generate document %s (%s) failed: %w", key, item.Title, err)
+			}
+
+			// generate children
+			if err = item.Generate(ctx, provider, r, tmpWiki, key); err != nil {
+				return err
+			}
+			results[idx] = tmpWiki
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return err
+	}
+
+	wiki.Children = append(wiki.Children, results...)
+	return nil
+}
+
+const (
+	ChatRoleUser      = "user"
+	ChatRoleAssistant = "assistant"
+)
+
+// ChatMessage 表示一轮对话消息
+type ChatMessage struct {
+	Role    string `json:"role"`    // 角色：user 或 assistant
+	Content string `json:"content"` // 内容
+}
+
+// ChatAnswer 表示仓库问答的回答
+type ChatAnswer struct {
+	Answer string   `json:"answer"` // 回答内容
+	Files  []string `json:"files"`  // 引用的文件路径
+}
+
+// DocumentUpdateCatalogue 表示增量更新时模型给出的文档结构变更
+type DocumentUpdateCatalogue struct {
+	DeleteID []any                `json:"delete_id"` // 需要删除的文档 ID
+	Items    []DocumentUpdateItem `json:"items"`     // 新增或更新的文档
+}
+
+const (
+	DocumentUpdateAdd    = "add"
+	DocumentUpdateUpdate = "update"
+)
+
+// DocumentUpdateItem 表示一个新增或更新的文档
+type DocumentUpdateItem struct {
+	Name           string               `json:"name"`
+	Title          string               `json:"title"`
+	Prompt         string               `json:"prompt"`
+	DependentFiles []string             `json:"dependent_file"`
+	Type           string               `json:"type"` // add 或 update
+	ID             any                  `json:"id"`   // 更新的文档 ID
+	Children       []DocumentUpdateItem `json:"children"`
+}
 
-		wiki.Children = append(wiki.Children, tmpWiki)
+// CatalogueItem converts the update item to a catalogue item without children.
+func (c *DocumentUpdateItem) CatalogueItem() *DocumentResultCalalogueItem {
+	return &DocumentResultCalalogueItem{
+		Name:           c.Name,
+		Title:          c.Title,
+		Prompt:         c.Prompt,
+		DependentFiles: c.DependentFiles,
 	}
 }
@@ -0,0 +1,94 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/progress"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/prompts"
+	"go.uber.org/zap"
+)
+
+// Chat answers a question about the repository, using the prior turns as context.
+func (r *Repository) Chat(ctx context.Context, question string, history []ChatMessage) (*ChatAnswer, error) {
+	var prompt = prompts.PromptTemplate{
+		Template: config.ChatPrompt,
+		PartialVariables: map[string]any{
+			"catalogue": r.fitPrompt(progress.StageChat, r.StructedCatalogue, catalogueShare),
+			"repo_name": r.Name,
+			"repo_url":  r.GitURL,
+			"language":  r.Language,
+		},
+		TemplateFormat: prompts.TemplateFormatGoTemplate,
+	}
+	content, err := prompt.Format(nil)
+	if err != nil {
+		zap.L().Error("cannot format prompt", zap.Error(err))
+		return nil, err
+	}
+
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, content),
+	}
+	for _, msg := range history {
+		switch msg.Role {
+		case ChatRoleUser:
+			messages = append(messages, llms.TextParts(llms.ChatMessageTypeHuman, msg.Content))
+		case ChatRoleAssistant:
+			messages = append(messages, llms.TextParts(llms.ChatMessageTypeAI, msg.Content))
+		}
+	}
+	messages = append(messages, llms.TextParts(llms.ChatMessageTypeHuman, question))
+
+	answer, messages, err := r.generateWithTools(ctx, r.stageProvider(progress.StageChat), progress.StageChat, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChatAnswer{
+		Answer: answer,
+		Files:  r.citedFiles(messages),
+	}, nil
+}
+
+// citedFiles collects the repository files read by the model during the tool-calling loop.
+func (r *Repository) citedFiles(messages []llms.MessageContent) []string {
+	var files = make(map[string]bool)
+	for _, msg := range messages {
+		if msg.Role != llms.ChatMessageTypeAI {
+			continue
+		}
+		for _, part := range msg.Parts {
+			toolCall, ok := part.(llms.ToolCall)
+			if !ok || toolCall.FunctionCall == nil || toolCall.FunctionCall.Name != "readFiles" {
+				continue
+			}
+			var args struct {
+				FilePaths []string `json:"filePaths"`
+			}
+			if err := json.Unmarshal([]byte(toolCall.FunctionCall.Arguments), &args); err != nil {
+				continue
+			}
+			for _, p := range args.FilePaths {
+				item, err := r.resolvePath(p)
+				if err != nil {
+					continue
+				}
+				if _, err := os.Stat(item); err == nil {
+					files[p] = true
+				}
+			}
+		}
+	}
+
+	var list = make([]string, 0, len(files))
+	for p := range files {
+		list = append(list, p)
+	}
+	sort.Strings(list)
+	return list
+}
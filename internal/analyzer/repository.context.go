@@ -0,0 +1,26 @@
+package analyzer
+
+import (
+	"github.com/o0olele/opendeepwiki-go/internal/llm/chat"
+)
+
+// shares of the context window of a stage, in percent, a prompt variable may take
+const (
+	catalogueShare = 30 // the file tree of the repository
+	readmeShare    = 10
+	changesShare   = 15 // git changes, commits and the document catalogue of an update
+	readFilesShare = 25 // the files of one readFiles call
+)
+
+// maxReadTokens bounds one readFiles call in large windows, the tool loop may read many times.
+const maxReadTokens = 20480
+
+// fitPrompt truncates a prompt variable to its share of the context window of a stage.
+func (r *Repository) fitPrompt(stage, text string, share int) string {
+	return chat.TruncateText(text, r.stageWindow(stage)*share/100)
+}
+
+// readBudget is the number of tokens one readFiles call of a stage may return.
+func (r *Repository) readBudget(stage string) int {
+	return min(r.stageWindow(stage)*readFilesShare/100, maxReadTokens)
+}
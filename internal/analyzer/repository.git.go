@@ -0,0 +1,248 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+	"go.uber.org/zap"
+)
+
+// maxChangeCommits is the maximum number of commit messages included in a change summary.
+const maxChangeCommits = 50
+
+// ErrNotGitRepository is returned by the git operations of a plain directory.
+var ErrNotGitRepository = errors.New("repository is not a git repository")
+
+// ErrDetachedHead is returned for tags and commits, they are pinned and never move.
+var ErrDetachedHead = errors.New("repository head is detached")
+
+// HeadCommit returns the hash of the checked out commit.
+func (r *Repository) HeadCommit() (string, error) {
+	if r.repo == nil {
+		return "", ErrNotGitRepository
+	}
+	head, err := r.repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("get repository head failed: %w", err)
+	}
+	return head.Hash().String(), nil
+}
+
+// RemoteHead returns the commit of the branch checked out at path on the remote, nothing is fetched.
+func RemoteHead(ctx context.Context, path string, auth transport.AuthMethod) (string, error) {
+	repo, err := git.PlainOpen(path)
+	if err == git.ErrRepositoryNotExists {
+		return "", ErrNotGitRepository
+	}
+	if err != nil {
+		return "", fmt.Errorf("open repository failed: %w", err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("get repository head failed: %w", err)
+	}
+	if !head.Name().IsBranch() {
+		return "", ErrDetachedHead
+	}
+
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return "", fmt.Errorf("get repository remote failed: %w", err)
+	}
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth})
+	if err != nil {
+		return "", fmt.Errorf("list remote references failed: %w", err)
+	}
+	for _, ref := range refs {
+		if ref.Name() == head.Name() {
+			return ref.Hash().String(), nil
+		}
+	}
+	return "", fmt.Errorf("branch %s not found on the remote", head.Name().Short())
+}
+
+// Pull fetches the remote and moves the current branch to the remote head.
+// A hard reset is used so force pushes upstream do not break the sync.
+func (r *Repository) Pull(ctx context.Context) error {
+	if r.repo == nil {
+		return ErrNotGitRepository
+	}
+	err := r.repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: git.DefaultRemoteName,
+		Auth:       r.auth,
+		Force:      true,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return fmt.Errorf("fetch repository failed: %w", err)
+	}
+
+	head, err := r.repo.Head()
+	if err != nil {
+		return fmt.Errorf("get repository head failed: %w", err)
+	}
+	if !head.Name().IsBranch() {
+		// detached head, nothing to move
+		return nil
+	}
+
+	remoteRef, err := r.repo.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, head.Name().Short()), true)
+	if err != nil {
+		return fmt.Errorf("get remote reference failed: %w", err)
+	}
+
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("get worktree failed: %w", err)
+	}
+
+	err = worktree.Reset(&git.ResetOptions{
+		Commit: remoteRef.Hash(),
+		Mode:   git.HardReset,
+	})
+	if err != nil {
+		return fmt.Errorf("reset worktree failed: %w", err)
+	}
+
+	zap.L().Info("Pulled repository", zap.String("git_url", r.GitURL), zap.String("commit", remoteRef.Hash().String()))
+	return nil
+}
+
+// ChangesSince describes the commits and changed files between the given commit and HEAD.
+// It returns an empty string when nothing changed.
+func (r *Repository) ChangesSince(commit string) (string, error) {
+	oldCommit, newCommit, changes, err := r.diffSince(commit)
+	if err != nil || newCommit == nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Commits:\n")
+	iter, err := r.repo.Log(&git.LogOptions{From: newCommit.Hash})
+	if err != nil {
+		return "", fmt.Errorf("get commit log failed: %w", err)
+	}
+	var count int
+	for {
+		c, err := iter.Next()
+		if err != nil || c.Hash == oldCommit.Hash || count >= maxChangeCommits {
+			break
+		}
+		sb.WriteString(fmt.Sprintf("- %s %s: %s\n", c.Hash.String()[:8], c.Author.Name, firstLine(c.Message)))
+		count++
+	}
+	iter.Close()
+
+	sb.WriteString("\nChanged files:\n")
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			continue
+		}
+		switch action {
+		case merkletrie.Insert:
+			sb.WriteString("- added: " + change.To.Name + "\n")
+		case merkletrie.Delete:
+			sb.WriteString("- deleted: " + change.From.Name + "\n")
+		case merkletrie.Modify:
+			sb.WriteString("- modified: " + change.To.Name + "\n")
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// FileChanges are the files changed between two commits, relative to the repository.
+type FileChanges struct {
+	Added    []string
+	Modified []string
+	Deleted  []string
+}
+
+// FilesChangedSince returns the files changed between the given commit and HEAD.
+func (r *Repository) FilesChangedSince(commit string) (*FileChanges, error) {
+	_, _, changes, err := r.diffSince(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	var files = new(FileChanges)
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			continue
+		}
+		switch action {
+		case merkletrie.Insert:
+			files.Added = append(files.Added, change.To.Name)
+		case merkletrie.Delete:
+			files.Deleted = append(files.Deleted, change.From.Name)
+		case merkletrie.Modify:
+			files.Modified = append(files.Modified, change.To.Name)
+		}
+	}
+	return files, nil
+}
+
+// diffSince returns the given commit, HEAD and the changes between their trees.
+// The commits are nil when HEAD is the given commit.
+func (r *Repository) diffSince(commit string) (*object.Commit, *object.Commit, object.Changes, error) {
+	if r.repo == nil {
+		return nil, nil, nil, ErrNotGitRepository
+	}
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("get repository head failed: %w", err)
+	}
+	if head.Hash().String() == commit {
+		return nil, nil, nil, nil
+	}
+
+	oldCommit, err := r.repo.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("get commit %s failed: %w", commit, err)
+	}
+	newCommit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("get head commit failed: %w", err)
+	}
+
+	oldTree, err := oldCommit.Tree()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	newTree, err := newCommit.Tree()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	changes, err := object.DiffTree(oldTree, newTree)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("diff tree failed: %w", err)
+	}
+	return oldCommit, newCommit, changes, nil
+}
+
+// RefreshCatalogue rescans the repository files, e.g. after a pull.
+func (r *Repository) RefreshCatalogue() error {
+	catalogs, err := r.fileScanner.GetCatalogue(r.Path)
+	if err != nil {
+		return err
+	}
+	r.catalogs = catalogs
+	return nil
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if idx := strings.Index(s, "\n"); idx >= 0 {
+		return strings.TrimSpace(s[:idx])
+	}
+	return s
+}
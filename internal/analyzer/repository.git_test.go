@@ -0,0 +1,71 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestFilesChangedSince(t *testing.T) {
+	path := t.TempDir()
+	repo, err := git.PlainInit(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(message string) string {
+		if _, err := worktree.Add("."); err != nil {
+			t.Fatal(err)
+		}
+		signature := &object.Signature{Name: "author", Email: "author@example.com", When: time.Now()}
+		hash, err := worktree.Commit(message, &git.CommitOptions{All: true, Author: signature})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash.String()
+	}
+	write := func(file, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(path, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("main.go", "package main\n")
+	write("util/old.go", "package util\n")
+	first := commit("first")
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write("util/new.go", "package util\n")
+	if err = os.Remove(filepath.Join(path, "util/old.go")); err != nil {
+		t.Fatal(err)
+	}
+	head := commit("second")
+
+	r := &Repository{Path: path, repo: repo}
+	files, err := r.FilesChangedSince(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &FileChanges{Added: []string{"util/new.go"}, Modified: []string{"main.go"}, Deleted: []string{"util/old.go"}}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("got changes %+v, want %+v", files, want)
+	}
+
+	files, err = r.FilesChangedSince(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files.Added)+len(files.Modified)+len(files.Deleted) != 0 {
+		t.Fatalf("got changes %+v at head, want none", files)
+	}
+}
@@ -1,6 +1,8 @@
 package analyzer
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path"
@@ -8,11 +10,13 @@ import (
 	"strings"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/google/uuid"
 	"github.com/o0olele/opendeepwiki-go/internal/analyzer/codemap"
 	"github.com/o0olele/opendeepwiki-go/internal/config"
 	"github.com/o0olele/opendeepwiki-go/internal/database/models"
 	"github.com/o0olele/opendeepwiki-go/internal/llm/chat"
+	"github.com/o0olele/opendeepwiki-go/internal/progress"
 	"go.uber.org/zap"
 )
 
@@ -29,6 +33,7 @@ type Repository struct {
 	StructedCodePath   string
 	StructedVectorPath string
 	Language           string // language of the repository
+	DocumentCatalogue  string // generated document catalogue in json
 
 	// internal fields
 	repo        *git.Repository
@@ -36,6 +41,23 @@ type Repository struct {
 	fileScanner *FileScanner
 	catalogs    []PathInfo
 	provider    chat.Provider
+	// providers of the routed stages, the model and the context window each stage runs on
+	stageProviders map[string]chat.Provider
+	stageModels    map[string]string
+	stageWindows   map[string]int
+	stageCacheKeys map[string]string // the providers a stage is routed to, keys its cached answers
+	meter          chat.Meter        // accounts the token usage, nil if not metered
+	reporter       *progress.Reporter
+	checkpoint     DocumentCheckpoint
+	limiter        chan struct{} // bounds the concurrent document generations
+	auth           transport.AuthMethod
+}
+
+// DocumentCheckpoint persists the generated documents so an interrupted generation can resume.
+// The key is the position of the item in the document catalogue, e.g. "2.1".
+type DocumentCheckpoint interface {
+	Load(key string) (*WikiDocument, bool)
+	Save(key string, doc *WikiDocument) error
 }
 
 func NewRepositoryFromModel(repo *models.Repository) (*Repository, error) {
@@ -51,12 +73,17 @@ func NewRepositoryFromModel(repo *models.Repository) (*Repository, error) {
 		StructedCodePath:   repo.StructedCodePath,
 		StructedVectorPath: repo.StructedVectorPath,
 		Language:           repo.Language,
+		DocumentCatalogue:  repo.DocumentCatalogue,
 	}
+	// imported directories and archives are documented without git
 	gitRepo, err := git.PlainOpen(repo.Path)
-	if err != nil {
+	if err == nil {
+		r.repo = gitRepo
+	} else if err != git.ErrRepositoryNotExists {
+		return nil, fmt.Errorf("open repository failed: %w", err)
+	} else if _, err = os.Stat(repo.Path); err != nil {
 		return nil, fmt.Errorf("open repository failed: %w", err)
 	}
-	r.repo = gitRepo
 	r.fileScanner = NewFileScanner(&AnalyzeOptions{
 		EnableSmartFilter: true,
 		ExcludedFiles:     DefaultExcludedFiles,
@@ -74,23 +101,49 @@ func NewRepositoryFromModel(repo *models.Repository) (*Repository, error) {
 	r.catalogs = catalogs
 
 	llmConfig := config.GetLLMConfig()
-	// create the llm provider
-	provider, err := chat.NewProvider(&chat.ProviderConfig{
-		Type:        chat.ProviderType(llmConfig.ProviderType),
-		APIKey:      llmConfig.APIKey,
-		Model:       llmConfig.Model,
-		MaxTokens:   llmConfig.MaxTokens,
-		Temperature: llmConfig.Temperature,
-		BaseURL:     llmConfig.BaseURL,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("create llm provider failed: %w", err)
+	// create the llm providers
+	if err = r.initProviders(llmConfig); err != nil {
+		return nil, err
 	}
-	r.provider = provider
+
+	concurrency := llmConfig.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	r.limiter = make(chan struct{}, concurrency)
 
 	return r, nil
 }
 
+// acquire waits for a generation slot, it returns false if ctx is cancelled.
+func (r *Repository) acquire(ctx context.Context) bool {
+	select {
+	case r.limiter <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (r *Repository) release() {
+	<-r.limiter
+}
+
+// SetReporter sets the reporter receiving the generation progress.
+func (r *Repository) SetReporter(reporter *progress.Reporter) {
+	r.reporter = reporter
+}
+
+// SetAuth sets the credential used to fetch a private repository.
+func (r *Repository) SetAuth(auth transport.AuthMethod) {
+	r.auth = auth
+}
+
+// SetCheckpoint sets the checkpoint used to skip documents generated by a previous run.
+func (r *Repository) SetCheckpoint(checkpoint DocumentCheckpoint) {
+	r.checkpoint = checkpoint
+}
+
 func (r *Repository) getStructedCodePath(raw string) string {
 	if len(raw) == 0 {
 		return ""
@@ -130,6 +183,7 @@ func (r *Repository) IndexCode() (bool, error) {
 	}
 	// save the code map service to file
 	if needSave {
+		previousCode, previousVector := r.StructedCodePath, r.StructedVectorPath
 
 		tmp := uuid.New().String()
 		r.StructedCodePath = tmp + ".code"
@@ -141,21 +195,109 @@ func (r *Repository) IndexCode() (bool, error) {
 			return false, err
 		}
 
+		// the loaded index is replaced by the new files
+		RemoveCodeIndex(previousCode, previousVector)
 	}
 
 	return true, nil
 }
 
-func (r *Repository) CreateDocuments() (*WikiDocument, error) {
+// UpdateCodeIndex indexes the files changed by a sync into new index files, the embeddings of the
+// other files are taken from the saved index. Without a saved index the repository is indexed from
+// scratch. The saved index files are left to the caller.
+func (r *Repository) UpdateCodeIndex(files *FileChanges) error {
+	codeIndexer, err := codemap.NewCodeMapService(r.Path)
+	if err != nil {
+		return fmt.Errorf("create code map service failed: %w", err)
+	}
+	if len(r.StructedCodePath) == 0 || len(r.StructedVectorPath) == 0 {
+		err = fmt.Errorf("repository has not been indexed")
+	} else {
+		err = codeIndexer.LoadFromFile(r.getStructedCodePath(r.StructedCodePath), r.getStructedVectorPath(r.StructedVectorPath))
+	}
+	if err != nil {
+		zap.L().Warn("load code index failed, index the repository from scratch", zap.Error(err))
+		r.StructedCodePath, r.StructedVectorPath = "", ""
+		_, err = r.IndexCode()
+		return err
+	}
+
+	changed := append(append([]string{}, files.Added...), files.Modified...)
+	if err = codeIndexer.UpdateFiles(r.Path, r.GitURL, changed, files.Deleted); err != nil {
+		return fmt.Errorf("update code index failed: %w", err)
+	}
 
-	documentResults, err := r.generateThinkCatalogue(r.provider)
+	tmp := uuid.New().String()
+	codePath, vectorPath := tmp+".code", tmp+".vector"
+	err = codeIndexer.SaveToFile(r.getStructedCodePath(codePath), r.getStructedVectorPath(vectorPath))
 	if err != nil {
-		zap.L().Warn("generate documents failed", zap.Error(err))
-		return nil, err
+		return fmt.Errorf("save code map service failed: %w", err)
+	}
+	r.StructedCodePath, r.StructedVectorPath = codePath, vectorPath
+	r.codeIndexer = codeIndexer
+	return nil
+}
+
+// RemoveCodeIndex deletes the files of a code index that was replaced or reset.
+func RemoveCodeIndex(codePath, vectorPath string) {
+	repoConfig := config.GetRepositoryConfig()
+	for _, file := range [][2]string{{repoConfig.Code, codePath}, {repoConfig.Vector, vectorPath}} {
+		if len(file[1]) == 0 {
+			continue
+		}
+		file := path.Join(file[0], file[1])
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			zap.L().Warn("remove code index failed", zap.String("path", file), zap.Error(err))
+		}
+	}
+}
+
+// LoadCodeIndex loads the saved code index without re-indexing the repository.
+func (r *Repository) LoadCodeIndex() error {
+	if len(r.StructedCodePath) == 0 || len(r.StructedVectorPath) == 0 {
+		return fmt.Errorf("repository has not been indexed")
+	}
+
+	codeIndexer, err := codemap.NewCodeMapService(r.Path)
+	if err != nil {
+		return fmt.Errorf("create code map service failed: %w", err)
+	}
+
+	err = codeIndexer.LoadFromFile(r.getStructedCodePath(r.StructedCodePath), r.getStructedVectorPath(r.StructedVectorPath))
+	if err != nil {
+		return fmt.Errorf("load code map service failed: %w", err)
+	}
+
+	r.codeIndexer = codeIndexer
+	return nil
+}
+
+func (r *Repository) CreateDocuments(ctx context.Context) (*WikiDocument, error) {
+
+	// reuse the catalogue of a previous run so the checkpoint keys stay valid
+	var documentResults = new(DocumentResultCalalogue)
+	if len(r.DocumentCatalogue) > 0 {
+		if err := json.Unmarshal([]byte(r.DocumentCatalogue), documentResults); err != nil {
+			zap.L().Warn("parse document catalogue failed", zap.Error(err))
+			return nil, err
+		}
+	} else {
+		var err error
+		documentResults, err = r.generateThinkCatalogue(ctx, r.stageProvider(progress.StageThink))
+		if err != nil {
+			zap.L().Warn("generate documents failed", zap.Error(err))
+			return nil, err
+		}
 	}
 
 	var doc = &WikiDocument{}
-	documentResults.Generate(r.provider, r, doc)
+	// a failed or cancelled task must not be saved as complete
+	if err := documentResults.Generate(ctx, r.stageProvider(progress.StageDocument), r, doc); err != nil {
+		return doc, err
+	}
+	if ctx.Err() != nil {
+		return doc, ctx.Err()
+	}
 
 	return doc, nil
 }
@@ -0,0 +1,162 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/progress"
+	"github.com/tmc/langchaingo/llms"
+)
+
+const (
+	maxHistoryCommits = 500 // commits walked from HEAD
+	maxHistoryGroups  = 12  // releases or months summarised
+	unreleasedTitle   = "Unreleased"
+)
+
+// CommitGroups walks the commit log from HEAD and groups the commits into releases.
+// Commits are grouped by tag; a repository without tags is grouped by month.
+func (r *Repository) CommitGroups() ([]*CommitGroup, error) {
+	if r.repo == nil {
+		// a plain directory has no history
+		return nil, nil
+	}
+
+	tags, err := r.tagsByCommit()
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("get repository head failed: %w", err)
+	}
+
+	iter, err := r.repo.Log(&git.LogOptions{From: head.Hash(), Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, fmt.Errorf("get commit log failed: %w", err)
+	}
+	defer iter.Close()
+
+	var (
+		groups  []*CommitGroup
+		current *CommitGroup
+		authors map[string]bool
+	)
+	for count := 0; count < maxHistoryCommits; count++ {
+		c, err := iter.Next()
+		if err != nil {
+			break
+		}
+
+		title := monthTitle(c)
+		if len(tags) > 0 {
+			if tag, ok := tags[c.Hash]; ok {
+				title = tag
+			} else if current != nil {
+				title = current.Title
+			} else {
+				title = unreleasedTitle
+			}
+		}
+
+		if current == nil || current.Title != title {
+			if len(groups) >= maxHistoryGroups {
+				break
+			}
+			current = &CommitGroup{
+				Title:    title,
+				CommitID: c.Hash.String(),
+				Date:     c.Committer.When,
+			}
+			authors = make(map[string]bool)
+			groups = append(groups, current)
+		}
+
+		current.Messages = append(current.Messages, firstLine(c.Message))
+		if !authors[c.Author.Name] {
+			authors[c.Author.Name] = true
+			current.Authors = append(current.Authors, c.Author.Name)
+		}
+	}
+
+	return groups, nil
+}
+
+// SummariseCommits asks the model to summarise the changes of a commit group.
+func (r *Repository) SummariseCommits(ctx context.Context, group *CommitGroup) (*CommitRecord, error) {
+	r.reporter.Stage(progress.StageHistory, group.Title)
+
+	var question strings.Builder
+	question.WriteString(fmt.Sprintf("Summarise what changed in %s of this repository for a changelog page. ", group.Title))
+	question.WriteString("Group the changes into features, fixes and other changes, and explain their impact on users. ")
+	question.WriteString("The commits are:\n")
+	for _, message := range group.Messages {
+		question.WriteString("- " + message + "\n")
+	}
+
+	content, err := formatPrompt(config.HistoryPrompt, map[string]any{
+		"catalogue":          r.fitPrompt(progress.StageHistory, r.StructedCatalogue, catalogueShare),
+		"question":           r.fitPrompt(progress.StageHistory, question.String(), changesShare),
+		"git_repository_url": r.GitURL,
+		"language":           r.Language,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	answer, _, err := r.generateWithTools(ctx, r.stageProvider(progress.StageHistory), progress.StageHistory, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, content),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CommitRecord{
+		Title:       group.Title,
+		Description: answer,
+		Date:        group.Date,
+		Author:      strings.Join(group.Authors, ", "),
+		CommitID:    group.CommitID,
+	}, nil
+}
+
+// tagsByCommit maps the tagged commits to their tag names.
+func (r *Repository) tagsByCommit() (map[plumbing.Hash]string, error) {
+	iter, err := r.repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("get tags failed: %w", err)
+	}
+
+	var tags = make(map[plumbing.Hash]string)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+		// annotated tags point to a tag object
+		if tag, err := r.repo.TagObject(hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				return nil
+			}
+			hash = commit.Hash
+		}
+		// keep the smallest name when a commit has several tags, for a stable result
+		name := ref.Name().Short()
+		if exists, ok := tags[hash]; !ok || name < exists {
+			tags[hash] = name
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func monthTitle(c *object.Commit) string {
+	return c.Committer.When.Format("2006-01")
+}
@@ -0,0 +1,164 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// historyCommit is a commit of a test repository and the tags pointing at it.
+type historyCommit struct {
+	message   string
+	author    string
+	when      time.Time
+	tags      []string
+	annotated bool
+}
+
+// newHistoryRepository commits the commits in order, oldest first.
+func newHistoryRepository(t *testing.T, commits []historyCommit) (*Repository, []plumbing.Hash) {
+	path := t.TempDir()
+	repo, err := git.PlainInit(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hashes []plumbing.Hash
+	for idx, commit := range commits {
+		file := fmt.Sprintf("file%d.txt", idx)
+		if err = os.WriteFile(filepath.Join(path, file), []byte(commit.message), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = worktree.Add(file); err != nil {
+			t.Fatal(err)
+		}
+		signature := &object.Signature{Name: commit.author, Email: "author@example.com", When: commit.when}
+		hash, err := worktree.Commit(commit.message+"\n\nbody", &git.CommitOptions{Author: signature, Committer: signature})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tag := range commit.tags {
+			var options *git.CreateTagOptions
+			if commit.annotated {
+				options = &git.CreateTagOptions{Tagger: signature, Message: tag}
+			}
+			if _, err = repo.CreateTag(tag, hash, options); err != nil {
+				t.Fatal(err)
+			}
+		}
+		hashes = append(hashes, hash)
+	}
+	return &Repository{Path: path, repo: repo}, hashes
+}
+
+func day(month time.Month, day int) time.Time {
+	return time.Date(2024, month, day, 10, 0, 0, 0, time.UTC)
+}
+
+func TestCommitGroups(t *testing.T) {
+	type group struct {
+		title    string
+		commit   int // index of the newest commit of the group
+		authors  []string
+		messages []string
+	}
+
+	tests := []struct {
+		name    string
+		commits []historyCommit
+		want    []group
+	}{
+		{
+			name: "months without tags",
+			commits: []historyCommit{
+				{message: "Add the library", author: "alice", when: day(1, 15)},
+				{message: "Fix the library", author: "bob", when: day(1, 20)},
+				{message: "Document the library", author: "alice", when: day(1, 25)},
+				{message: "Add the command line", author: "alice", when: day(2, 10)},
+			},
+			want: []group{
+				{"2024-02", 3, []string{"alice"}, []string{"Add the command line"}},
+				{"2024-01", 2, []string{"alice", "bob"}, []string{"Document the library", "Fix the library", "Add the library"}},
+			},
+		},
+		{
+			name: "releases by tag",
+			commits: []historyCommit{
+				{message: "Add the library", author: "alice", when: day(1, 15)},
+				{message: "Fix the library", author: "bob", when: day(1, 20), tags: []string{"v0.1.0"}, annotated: true},
+				{message: "Add the command line", author: "alice", when: day(2, 10), tags: []string{"v0.2.0"}},
+				{message: "Start the server", author: "carol", when: day(3, 1)},
+			},
+			want: []group{
+				{"Unreleased", 3, []string{"carol"}, []string{"Start the server"}},
+				{"v0.2.0", 2, []string{"alice"}, []string{"Add the command line"}},
+				{"v0.1.0", 1, []string{"bob", "alice"}, []string{"Fix the library", "Add the library"}},
+			},
+		},
+		{
+			name: "several tags on a commit",
+			commits: []historyCommit{
+				{message: "Add the library", author: "alice", when: day(1, 15), tags: []string{"v1.0.0", "latest"}},
+			},
+			want: []group{
+				{"latest", 0, []string{"alice"}, []string{"Add the library"}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, hashes := newHistoryRepository(t, test.commits)
+			groups, err := r.CommitGroups()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(groups) != len(test.want) {
+				t.Fatalf("got %d groups, want %d", len(groups), len(test.want))
+			}
+			for idx, want := range test.want {
+				got := groups[idx]
+				if got.Title != want.title || got.CommitID != hashes[want.commit].String() || !got.Date.Equal(test.commits[want.commit].when) {
+					t.Fatalf("group %d is %s at %s, want %s at commit %d", idx, got.Title, got.Date, want.title, want.commit)
+				}
+				if !reflect.DeepEqual(got.Authors, want.authors) || !reflect.DeepEqual(got.Messages, want.messages) {
+					t.Fatalf("group %s has authors %v and messages %q, want %v and %q", got.Title, got.Authors, got.Messages, want.authors, want.messages)
+				}
+			}
+		})
+	}
+}
+
+func TestCommitGroupsLimit(t *testing.T) {
+	var commits []historyCommit
+	for month := 0; month < maxHistoryGroups+3; month++ {
+		commits = append(commits, historyCommit{message: "Monthly release", author: "alice", when: day(1, 1).AddDate(0, month, 0)})
+	}
+	r, _ := newHistoryRepository(t, commits)
+
+	groups, err := r.CommitGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != maxHistoryGroups || groups[0].Title != "2025-03" {
+		t.Fatalf("got %d groups starting at %s, want the newest %d", len(groups), groups[0].Title, maxHistoryGroups)
+	}
+}
+
+func TestCommitGroupsOfPlainDirectory(t *testing.T) {
+	r := &Repository{Path: t.TempDir()}
+	groups, err := r.CommitGroups()
+	if err != nil || groups != nil {
+		t.Fatalf("got %v and %v, want no history", groups, err)
+	}
+}
@@ -3,22 +3,21 @@ package analyzer
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"strings"
 
 	"github.com/o0olele/opendeepwiki-go/internal/config"
 	"github.com/o0olele/opendeepwiki-go/internal/llm/chat"
+	"github.com/o0olele/opendeepwiki-go/internal/progress"
 	"github.com/o0olele/opendeepwiki-go/internal/utils"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/prompts"
 	"go.uber.org/zap"
 )
 
-func (r *Repository) GenerateReadme() (string, error) {
+func (r *Repository) GenerateReadme(ctx context.Context) (string, error) {
 	var prompt = prompts.PromptTemplate{
 		Template: config.GenerateReadmePrompt,
 		PartialVariables: map[string]any{
-			"catalogue":      CatalogueToString(r.Path, r.catalogs),
+			"catalogue":      r.fitPrompt(progress.StageReadme, CatalogueToString(r.Path, r.catalogs), catalogueShare),
 			"branch":         r.Branch,
 			"git_repository": r.GitURL,
 			"language":       r.Language,
@@ -35,7 +34,7 @@ func (r *Repository) GenerateReadme() (string, error) {
 		llms.TextParts(llms.ChatMessageTypeHuman, content),
 	}
 
-	response, err := r.provider.GetModel().GenerateContent(context.Background(), message,
+	response, err := r.stageProvider(progress.StageReadme).GetModel().GenerateContent(ctx, message,
 		llms.WithMaxTokens(8192),
 	)
 	if err != nil {
@@ -47,19 +46,19 @@ func (r *Repository) GenerateReadme() (string, error) {
 	return r.Readme, nil
 }
 
-func (r *Repository) GenerateStructedCatalogue() (string, error) {
-	return r.fileScanner.GetSimplifyCatalogueString(r.provider, r.Path, r.catalogs, r.Readme)
+func (r *Repository) GenerateStructedCatalogue(ctx context.Context) (string, error) {
+	return r.fileScanner.GetSimplifyCatalogueString(ctx, r.stageProvider(progress.StageCatalogue), r.stageWindow(progress.StageCatalogue), r.Path, r.catalogs, r.Readme)
 }
 
-func (r *Repository) GenerateOverview() (string, error) {
+func (r *Repository) GenerateOverview(ctx context.Context) (string, error) {
 	zap.L().Info("generating repository overview", zap.String("repository", r.StructedCatalogue))
 	var prompt = prompts.PromptTemplate{
 		Template: config.OverviewPrompt,
 		PartialVariables: map[string]any{
-			"catalogue":      r.StructedCatalogue,
+			"catalogue":      r.fitPrompt(progress.StageOverview, r.StructedCatalogue, catalogueShare),
 			"branch":         r.Branch,
 			"git_repository": r.GitURL,
-			"readme":         r.Readme,
+			"readme":         r.fitPrompt(progress.StageOverview, r.Readme, readmeShare),
 			"language":       r.Language,
 		},
 		TemplateFormat: prompts.TemplateFormatGoTemplate,
@@ -74,13 +73,10 @@ func (r *Repository) GenerateOverview() (string, error) {
 		llms.TextParts(llms.ChatMessageTypeHuman, content),
 	}
 
-	response, err := r.provider.GetModel().GenerateContent(context.Background(), message,
+	response, err := r.stageProvider(progress.StageOverview).GetModel().GenerateContent(ctx, message,
 		llms.WithMaxTokens(8192),
 		llms.WithTools(llmTools),
-		llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
-			fmt.Print(string(chunk))
-			return nil
-		}),
+		llms.WithStreamingFunc(r.streamingFunc(progress.StageOverview)),
 	)
 	if err != nil {
 		zap.L().Error("cannot get model response", zap.Error(err))
@@ -91,12 +87,12 @@ func (r *Repository) GenerateOverview() (string, error) {
 	return overview, nil
 }
 
-func (r *Repository) generateCatalogue(provider chat.Provider, think string) (*DocumentResultCalalogue, error) {
+func (r *Repository) generateCatalogue(ctx context.Context, provider chat.Provider, think string) (*DocumentResultCalalogue, error) {
 	var prompt = prompts.PromptTemplate{
 		Template: config.AnalyzeCatalogPrompt,
 		PartialVariables: map[string]any{
 			"think":           think,
-			"code_files":      r.StructedCatalogue,
+			"code_files":      r.fitPrompt(progress.StageThink, r.StructedCatalogue, catalogueShare),
 			"repository_name": r.Name,
 			"language":        r.Language,
 		},
@@ -112,30 +108,12 @@ func (r *Repository) generateCatalogue(provider chat.Provider, think string) (*D
 		llms.TextParts(llms.ChatMessageTypeHuman, content),
 	}
 
-	var str strings.Builder
-	for i := 0; i < 16; i++ {
-		response, err := provider.GetModel().GenerateContent(context.Background(), messages,
-			llms.WithTools(llmTools),
-			llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
-				fmt.Print(string(chunk))
-				return nil
-			}),
-		)
-		if err != nil {
-			zap.L().Warn("cannot get model response", zap.Error(err))
-			continue
-		}
-		choice := response.Choices[0]
-		if len(choice.ToolCalls) > 0 {
-			messages = r.updateMessageHistory(messages, choice)
-			messages = r.executeToolCalls(provider.GetModel(), messages, choice)
-		} else {
-			str.WriteString(choice.Content)
-			break
-		}
+	answer, _, err := r.generateWithTools(ctx, provider, progress.StageThink, messages)
+	if err != nil {
+		return nil, err
 	}
 
-	extract := utils.ExtractTagContent(str.String(), "documentation_structure")
+	extract := utils.ExtractTagContent(answer, "documentation_structure")
 	extract = utils.ExtractJSON(extract)
 
 	var result = new(DocumentResultCalalogue)
@@ -147,12 +125,12 @@ func (r *Repository) generateCatalogue(provider chat.Provider, think string) (*D
 	return result, nil
 }
 
-func (r *Repository) generateThinkCatalogue(provider chat.Provider) (*DocumentResultCalalogue, error) {
+func (r *Repository) generateThinkCatalogue(ctx context.Context, provider chat.Provider) (*DocumentResultCalalogue, error) {
 
 	var prompt = prompts.PromptTemplate{
 		Template: config.GenerateCatalogPrompt,
 		PartialVariables: map[string]any{
-			"code_files":         r.StructedCatalogue,
+			"code_files":         r.fitPrompt(progress.StageThink, r.StructedCatalogue, catalogueShare),
 			"git_repository_url": r.GitURL,
 			"repository_name":    r.Name,
 			"language":           r.Language,
@@ -169,12 +147,9 @@ func (r *Repository) generateThinkCatalogue(provider chat.Provider) (*DocumentRe
 		llms.TextParts(llms.ChatMessageTypeHuman, content),
 	}
 
-	response, err := provider.GetModel().GenerateContent(context.Background(), message,
+	response, err := provider.GetModel().GenerateContent(ctx, message,
 		llms.WithMaxTokens(8192),
-		llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
-			fmt.Print(string(chunk))
-			return nil
-		}),
+		llms.WithStreamingFunc(r.streamingFunc(progress.StageThink)),
 	)
 	if err != nil {
 		zap.L().Error("cannot get model response", zap.Error(err))
@@ -182,10 +157,49 @@ func (r *Repository) generateThinkCatalogue(provider chat.Provider) (*DocumentRe
 	}
 	choice := response.Choices[0]
 
-	return r.generateCatalogue(provider, choice.Content)
+	return r.generateCatalogue(ctx, provider, choice.Content)
+}
+
+// GenerateDocumentCatalogue generates the document catalogue in json, CreateDocuments uses it when set.
+func (r *Repository) GenerateDocumentCatalogue(ctx context.Context) (string, error) {
+	documentResults, err := r.generateThinkCatalogue(ctx, r.stageProvider(progress.StageThink))
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(documentResults)
+	if err != nil {
+		return "", err
+	}
+	r.DocumentCatalogue = string(data)
+	return r.DocumentCatalogue, nil
+}
+
+// checkpointCatalogueItem returns the document saved by a previous run or generates and saves it.
+func (r *Repository) checkpointCatalogueItem(ctx context.Context, provider chat.Provider, key string, catalogItem *DocumentResultCalalogueItem) (*WikiDocument, error) {
+	if r.checkpoint != nil {
+		if doc, ok := r.checkpoint.Load(key); ok {
+			return doc, nil
+		}
+	}
+
+	doc, err := r.generateCatalogueItem(ctx, provider, catalogItem)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.checkpoint != nil {
+		if err := r.checkpoint.Save(key, doc); err != nil {
+			zap.L().Warn("save document checkpoint failed", zap.String("title", catalogItem.Title), zap.Error(err))
+		}
+	}
+	return doc, nil
 }
 
-func (r *Repository) generateCatalogueItem(provider chat.Provider, catalogItem *DocumentResultCalalogueItem) (*WikiDocument, error) {
+func (r *Repository) generateCatalogueItem(ctx context.Context, provider chat.Provider, catalogItem *DocumentResultCalalogueItem) (*WikiDocument, error) {
+	zap.L().Info("Generating document", zap.String("repository", r.Name), zap.String("title", catalogItem.Title), zap.String("model", r.StageModel(progress.StageDocument)))
+	r.reporter.Item(progress.StageDocument, progress.KeyFromContext(ctx), catalogItem.Title)
+
 	var prompt = prompts.PromptTemplate{
 		Template: config.GenerateDocsPrompt,
 		PartialVariables: map[string]any{
@@ -193,7 +207,7 @@ func (r *Repository) generateCatalogueItem(provider chat.Provider, catalogItem *
 			"title":          catalogItem.Title,
 			"git_repository": r.GitURL,
 			"branch":         r.Branch,
-			"catalogue":      r.StructedCatalogue,
+			"catalogue":      r.fitPrompt(progress.StageDocument, r.StructedCatalogue, catalogueShare),
 			"language":       r.Language,
 		},
 		TemplateFormat: prompts.TemplateFormatGoTemplate,
@@ -208,34 +222,70 @@ func (r *Repository) generateCatalogueItem(provider chat.Provider, catalogItem *
 		llms.TextParts(llms.ChatMessageTypeHuman, content),
 	}
 
-	var str strings.Builder
-	for i := 0; i < 16; i++ {
-		response, err := provider.GetModel().GenerateContent(context.Background(), messages,
-			llms.WithTools(llmTools),
-			llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
-				fmt.Print(string(chunk))
-				return nil
-			}),
-		)
-		if err != nil {
-			zap.L().Error("cannot get model response", zap.Error(err))
-			continue
-		}
-		choice := response.Choices[0]
-		if len(choice.ToolCalls) > 0 {
-			messages = r.updateMessageHistory(messages, choice)
-			messages = r.executeToolCalls(provider.GetModel(), messages, choice)
-		} else {
-			str.WriteString(choice.Content)
-			break
-		}
+	answer, _, err := r.generateWithTools(ctx, provider, progress.StageDocument, messages)
+	if err != nil {
+		zap.L().Error("generate document failed", zap.String("title", catalogItem.Title), zap.Error(err))
+		return nil, err
 	}
 
 	var result = &WikiDocument{
-		Content: utils.ExtractTagContent(str.String(), "docs"),
+		Content: utils.ExtractTagContent(answer, "docs"),
 		Title:   catalogItem.Title,
 	}
 
 	return result, nil
 
 }
+
+// streamingFunc reports the streamed model output as progress of the stage.
+func (r *Repository) streamingFunc(stage string) func(ctx context.Context, chunk []byte) error {
+	return func(ctx context.Context, chunk []byte) error {
+		r.reporter.Token(stage, progress.KeyFromContext(ctx), chunk)
+		return nil
+	}
+}
+
+// GenerateDocumentUpdate asks the model which documents to add, update or remove for the given git changes.
+func (r *Repository) GenerateDocumentUpdate(ctx context.Context, gitUpdate, documentCatalogue string) (*DocumentUpdateCatalogue, error) {
+	var prompt = prompts.PromptTemplate{
+		Template: config.AnalyzeNewCatalogPrompt,
+		PartialVariables: map[string]any{
+			"catalogue":          r.fitPrompt(progress.StageThink, r.StructedCatalogue, catalogueShare),
+			"git_repository":     r.GitURL,
+			"git_commit":         r.fitPrompt(progress.StageThink, gitUpdate, changesShare),
+			"document_catalogue": r.fitPrompt(progress.StageThink, documentCatalogue, changesShare),
+			"language":           r.Language,
+		},
+		TemplateFormat: prompts.TemplateFormatGoTemplate,
+	}
+	content, err := prompt.Format(nil)
+	if err != nil {
+		zap.L().Error("cannot format prompt", zap.Error(err))
+		return nil, err
+	}
+
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, content),
+	}
+
+	answer, _, err := r.generateWithTools(ctx, r.stageProvider(progress.StageThink), progress.StageThink, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	extract := utils.ExtractTagContent(answer, "document_structure")
+	extract = utils.ExtractJSON(extract)
+
+	var result = new(DocumentUpdateCatalogue)
+	if err := json.Unmarshal([]byte(extract), result); err != nil {
+		zap.L().Error("cannot unmarshal extract", zap.Error(err))
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GenerateDocument generates the content of a single catalogue item, without its children.
+func (r *Repository) GenerateDocument(ctx context.Context, catalogItem *DocumentResultCalalogueItem) (*WikiDocument, error) {
+	return r.generateCatalogueItem(ctx, r.stageProvider(progress.StageDocument), catalogItem)
+}
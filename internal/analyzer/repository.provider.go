@@ -0,0 +1,273 @@
+package analyzer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/llm/chat"
+	"github.com/o0olele/opendeepwiki-go/internal/progress"
+	"go.uber.org/zap"
+)
+
+// defaultProviderName names the provider of the llm section in fallbacks and logs.
+const defaultProviderName = "default"
+
+// defaultFixtureDir is where the llm exchanges are recorded and replayed from.
+const defaultFixtureDir = "./testdata/llm"
+
+// llmStages are the stages that ask the llm, the stages llm.stages can route.
+var llmStages = []string{
+	progress.StageReadme, progress.StageCatalogue, progress.StageOverview, progress.StageThink,
+	progress.StageDocument, progress.StageHistory, progress.StageChat, progress.StageResearch,
+}
+
+// namedProviderConfig returns the configuration of a named provider, "default" is the provider of the llm section.
+func namedProviderConfig(llmConfig *config.LLMConfig, name string) (*chat.ProviderConfig, error) {
+	if name == defaultProviderName {
+		return &chat.ProviderConfig{
+			Type:          chat.ProviderType(llmConfig.ProviderType),
+			APIKey:        llmConfig.APIKey,
+			Model:         llmConfig.Model,
+			MaxTokens:     llmConfig.MaxTokens,
+			Temperature:   llmConfig.Temperature,
+			BaseURL:       llmConfig.BaseURL,
+			APIVersion:    llmConfig.APIVersion,
+			ContextWindow: llmConfig.ContextWindow,
+		}, nil
+	}
+
+	profile, ok := llmConfig.Providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown llm provider %s", name)
+	}
+	providerConfig := &chat.ProviderConfig{
+		Type:          chat.ProviderType(profile.ProviderType),
+		APIKey:        profile.APIKey,
+		Model:         profile.Model,
+		MaxTokens:     profile.MaxTokens,
+		Temperature:   profile.Temperature,
+		BaseURL:       profile.BaseURL,
+		APIVersion:    profile.APIVersion,
+		ContextWindow: profile.ContextWindow,
+	}
+	if providerConfig.MaxTokens == 0 {
+		providerConfig.MaxTokens = llmConfig.MaxTokens
+	}
+	return providerConfig, nil
+}
+
+// routeNames returns the provider a stage is routed to followed by its fallbacks.
+func routeNames(llmConfig *config.LLMConfig, name string) []string {
+	var names = []string{name}
+	for _, fallback := range llmConfig.Fallbacks {
+		if fallback != name {
+			names = append(names, fallback)
+		}
+	}
+	return names
+}
+
+// initWindows sets the context window of every stage, the smallest window of its providers,
+// so the prompts fit whichever provider answers. Replayed runs size the prompts the same way.
+func (r *Repository) initWindows(llmConfig *config.LLMConfig) error {
+	window := func(name string) (int, error) {
+		var smallest int
+		for _, providerName := range routeNames(llmConfig, name) {
+			providerConfig, err := namedProviderConfig(llmConfig, providerName)
+			if err != nil {
+				return 0, err
+			}
+			if n := chat.ContextWindow(providerConfig); smallest == 0 || n < smallest {
+				smallest = n
+			}
+		}
+		return smallest, nil
+	}
+
+	n, err := window(defaultProviderName)
+	if err != nil {
+		return err
+	}
+	r.stageWindows = map[string]int{"": n}
+	for stage, name := range llmConfig.Stages {
+		if len(name) == 0 || name == defaultProviderName {
+			continue
+		}
+		if _, ok := llmConfig.Providers[name]; !ok {
+			return fmt.Errorf("llm stage %s is routed to unknown provider %s", stage, name)
+		}
+		if n, err = window(name); err != nil {
+			return err
+		}
+		r.stageWindows[stage] = n
+	}
+	return nil
+}
+
+// initProviders creates the default provider and the providers of the routed stages,
+// every provider retries transient errors and falls back to the configured providers.
+func (r *Repository) initProviders(llmConfig *config.LLMConfig) error {
+	// a misspelled stage would silently use the default provider
+	for stage := range llmConfig.Stages {
+		if !slices.Contains(llmStages, stage) {
+			return fmt.Errorf("unknown llm stage %s, the stages are %s", stage, strings.Join(llmStages, ", "))
+		}
+	}
+	if err := r.initWindows(llmConfig); err != nil {
+		return err
+	}
+
+	fixtureDir := llmConfig.Replay.Dir
+	if len(fixtureDir) == 0 {
+		fixtureDir = defaultFixtureDir
+	}
+	switch llmConfig.Replay.Mode {
+	case "":
+	case chat.ReplayModeReplay:
+		// every stage answers from the fixtures, the requests decide the answers
+		r.provider = chat.NewReplayProvider(fixtureDir)
+		r.stageModels = map[string]string{"": chat.ReplayModeReplay + "/" + fixtureDir}
+		r.stageCacheKeys = map[string]string{"": r.stageModels[""]}
+		r.stageProviders = make(map[string]chat.Provider)
+		return nil
+	case chat.ReplayModeRecord:
+		zap.L().Info("Recording llm exchanges", zap.String("repository", r.Name), zap.String("dir", fixtureDir))
+	default:
+		return fmt.Errorf("unknown llm replay mode %s", llmConfig.Replay.Mode)
+	}
+
+	var (
+		named     = make(map[string]chat.Provider)
+		models    = make(map[string]string) // provider name to "name/model", usage is priced with it
+		endpoints = make(map[string]string) // provider name to the type, base url and model it calls
+	)
+	create := func(name string) (chat.Provider, error) {
+		if provider, ok := named[name]; ok {
+			return provider, nil
+		}
+
+		providerConfig, err := namedProviderConfig(llmConfig, name)
+		if err != nil {
+			return nil, err
+		}
+		provider, err := chat.NewProvider(providerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("create llm provider %s failed: %w", name, err)
+		}
+		// every provider of a route fits the requests into its own window
+		provider = chat.NewContextProvider(chat.ContextWindow(providerConfig), providerConfig.MaxTokens, provider)
+		named[name] = provider
+		models[name] = name + "/" + providerConfig.Model
+		endpoints[name] = fmt.Sprintf("%s %s %s", providerConfig.Type, providerConfig.BaseURL, providerConfig.Model)
+		return provider, nil
+	}
+	// the answers are cached per endpoint, a profile switched to another provider or server asks again
+	cacheKey := func(name string) string {
+		var keys []string
+		for _, providerName := range routeNames(llmConfig, name) {
+			keys = append(keys, endpoints[providerName])
+		}
+		return strings.Join(keys, ", ")
+	}
+
+	retry := chat.RetryConfig{
+		MaxAttempts:    llmConfig.Retry.MaxAttempts,
+		InitialBackoff: llmConfig.Retry.InitialBackoff,
+		MaxBackoff:     llmConfig.Retry.MaxBackoff,
+	}
+	route := func(name string) (chat.Provider, error) {
+		names := routeNames(llmConfig, name)
+		var (
+			providers = make([]chat.Provider, 0, len(names))
+			labels    = make([]string, 0, len(names))
+		)
+		for _, providerName := range names {
+			provider, err := create(providerName)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+			labels = append(labels, models[providerName])
+		}
+		provider := chat.NewFallbackProvider(labels, providers, retry)
+		if llmConfig.Replay.Mode == chat.ReplayModeRecord {
+			provider = chat.NewRecordProvider(fixtureDir, provider)
+		}
+		return provider, nil
+	}
+
+	provider, err := route(defaultProviderName)
+	if err != nil {
+		return err
+	}
+	r.provider = provider
+	r.stageModels = map[string]string{"": defaultProviderName + "/" + llmConfig.Model}
+	r.stageCacheKeys = map[string]string{"": cacheKey(defaultProviderName)}
+
+	r.stageProviders = make(map[string]chat.Provider)
+	for stage, name := range llmConfig.Stages {
+		if len(name) == 0 || name == defaultProviderName {
+			continue
+		}
+		profile, ok := llmConfig.Providers[name]
+		if !ok {
+			return fmt.Errorf("llm stage %s is routed to unknown provider %s", stage, name)
+		}
+
+		provider, err := route(name)
+		if err != nil {
+			return err
+		}
+		r.stageProviders[stage] = provider
+		r.stageModels[stage] = name + "/" + profile.Model
+		r.stageCacheKeys[stage] = cacheKey(name)
+		zap.L().Info("Routed llm stage", zap.String("repository", r.Name), zap.String("stage", stage), zap.String("provider", name), zap.String("model", profile.Model))
+	}
+	return nil
+}
+
+// SetCache answers repeated requests of every stage from the cache.
+func (r *Repository) SetCache(cache chat.Cache) {
+	r.provider = chat.NewCachedProvider(r.stageCacheKeys[""], cache, r.provider)
+	for stage, provider := range r.stageProviders {
+		r.stageProviders[stage] = chat.NewCachedProvider(r.stageCacheKeys[stage], cache, provider)
+	}
+}
+
+// SetMeter accounts the token usage of every request to the meter.
+func (r *Repository) SetMeter(meter chat.Meter) {
+	r.meter = meter
+}
+
+// stageProvider returns the provider a stage is routed to, the default provider otherwise.
+func (r *Repository) stageProvider(stage string) chat.Provider {
+	provider, ok := r.stageProviders[stage]
+	if !ok {
+		provider = r.provider
+	}
+	if r.meter != nil {
+		return chat.NewMeteredProvider(stage, r.StageModel(stage), r.meter, provider)
+	}
+	return provider
+}
+
+// stageWindow returns the context window of the providers of a stage.
+func (r *Repository) stageWindow(stage string) int {
+	if window, ok := r.stageWindows[stage]; ok {
+		return window
+	}
+	if window, ok := r.stageWindows[""]; ok {
+		return window
+	}
+	return chat.DefaultContextWindow
+}
+
+// StageModel describes the provider and model of a stage, e.g. "local/qwen3:4b".
+func (r *Repository) StageModel(stage string) string {
+	if model, ok := r.stageModels[stage]; ok {
+		return model
+	}
+	return r.stageModels[""]
+}
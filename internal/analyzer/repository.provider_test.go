@@ -0,0 +1,171 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/progress"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// newModelServer serves OpenAI chat completions and records the models requested from it.
+func newModelServer(t *testing.T) (*httptest.Server, func() []string) {
+	var (
+		mu     sync.Mutex
+		models []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		mu.Lock()
+		models = append(models, request.Model)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-1",
+			"object":  "chat.completion",
+			"model":   request.Model,
+			"choices": []map[string]any{{"index": 0, "message": map[string]any{"role": "assistant", "content": "answer"}, "finish_reason": "stop"}},
+			"usage":   map[string]any{"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return models
+	}
+}
+
+func TestStageProviders(t *testing.T) {
+	defaultServer, defaultModels := newModelServer(t)
+	localServer, localModels := newModelServer(t)
+
+	llmConfig := &config.LLMConfig{
+		ProviderType: "openai",
+		APIKey:       "unused",
+		Model:        "gpt-4o",
+		BaseURL:      defaultServer.URL,
+		MaxTokens:    1024,
+		Providers: map[string]config.LLMProviderConfig{
+			"local": {ProviderType: "openai", APIKey: "unused", Model: "qwen3:4b", BaseURL: localServer.URL},
+		},
+		Stages: map[string]string{
+			progress.StageThink:    "local",
+			progress.StageDocument: "local",
+			progress.StageChat:     "",
+		},
+	}
+	r := &Repository{}
+	if err := r.initProviders(llmConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		stage     string
+		wantModel string
+		wantLocal bool
+	}{
+		{progress.StageThink, "local/qwen3:4b", true},
+		{progress.StageDocument, "local/qwen3:4b", true},
+		{progress.StageChat, "default/gpt-4o", false},
+		{progress.StageOverview, "default/gpt-4o", false},
+	}
+	for _, test := range tests {
+		if got := r.StageModel(test.stage); got != test.wantModel {
+			t.Fatalf("stage %s: got model %s, want %s", test.stage, got, test.wantModel)
+		}
+
+		local, remote := len(localModels()), len(defaultModels())
+		messages := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hello")}
+		if _, err := r.stageProvider(test.stage).GetModel().GenerateContent(context.Background(), messages); err != nil {
+			t.Fatalf("stage %s: %v", test.stage, err)
+		}
+		gotLocal, gotDefault := len(localModels()) > local, len(defaultModels()) > remote
+		if gotLocal != test.wantLocal || gotDefault == test.wantLocal {
+			t.Fatalf("stage %s: got local %v and default %v, want local %v", test.stage, gotLocal, gotDefault, test.wantLocal)
+		}
+	}
+	if got := strings.Join(localModels(), ","); got != "qwen3:4b,qwen3:4b" {
+		t.Fatalf("local server got models %s, want qwen3:4b twice", got)
+	}
+}
+
+// mapCache is a chat.Cache in memory.
+type mapCache map[string][]byte
+
+func (c mapCache) Get(key string) ([]byte, bool) {
+	value, ok := c[key]
+	return value, ok
+}
+
+func (c mapCache) Put(key string, value []byte) error {
+	c[key] = value
+	return nil
+}
+
+func TestStageCacheKeys(t *testing.T) {
+	firstServer, firstModels := newModelServer(t)
+	secondServer, secondModels := newModelServer(t)
+	cache := make(mapCache)
+
+	// the profiles only differ in the server, the same model name runs on both
+	ask := func(baseURL string) {
+		r := &Repository{}
+		err := r.initProviders(&config.LLMConfig{ProviderType: "openai", APIKey: "unused", Model: "qwen3:4b", BaseURL: baseURL, MaxTokens: 1024})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.SetCache(cache)
+		messages := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hello")}
+		if _, err = r.stageProvider(progress.StageDocument).GetModel().GenerateContent(context.Background(), messages); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ask(firstServer.URL)
+	ask(firstServer.URL)
+	if got := len(firstModels()); got != 1 {
+		t.Fatalf("first server got %d requests, want the repeated one cached", got)
+	}
+	ask(secondServer.URL)
+	if got := len(secondModels()); got != 1 {
+		t.Fatalf("second server got %d requests, want the answer of the first server not reused", got)
+	}
+}
+
+func TestStageProvidersUnknownProvider(t *testing.T) {
+	llmConfig := &config.LLMConfig{
+		ProviderType: "openai",
+		APIKey:       "unused",
+		Model:        "gpt-4o",
+		Stages:       map[string]string{progress.StageDocument: "missing"},
+	}
+	err := (&Repository{}).initProviders(llmConfig)
+	if err == nil || !strings.Contains(err.Error(), "unknown provider missing") {
+		t.Fatalf("got %v, want the unknown provider", err)
+	}
+}
+
+func TestStageProvidersUnknownStage(t *testing.T) {
+	llmConfig := &config.LLMConfig{
+		ProviderType: "openai",
+		APIKey:       "unused",
+		Model:        "gpt-4o",
+		Providers:    map[string]config.LLMProviderConfig{"local": {ProviderType: "ollama", Model: "qwen3:4b"}},
+		Stages:       map[string]string{"documents": "local"},
+	}
+	err := (&Repository{}).initProviders(llmConfig)
+	if err == nil || !strings.Contains(err.Error(), "unknown llm stage documents") {
+		t.Fatalf("got %v, want the unknown stage", err)
+	}
+}
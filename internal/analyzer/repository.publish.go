@@ -0,0 +1,123 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/o0olele/opendeepwiki-go/internal/export"
+	"go.uber.org/zap"
+)
+
+// publishRemote is the remote of the temporary clone pointing to the upstream repository.
+const publishRemote = "upstream"
+
+// PublishOptions describes the branch the documentation is committed on.
+type PublishOptions struct {
+	Branch  string // new branch created from HEAD
+	Dir     string // directory of the documentation in the repository
+	Message string
+	Author  string
+	Email   string
+}
+
+// Publish commits the files under options.Dir on a new branch created from HEAD and pushes it
+// to the remote of the repository. The commit is made in a temporary clone sharing the objects
+// of the local clone, so the checked out tree used for analysis is never touched.
+func (r *Repository) Publish(ctx context.Context, files []export.File, options *PublishOptions) (string, error) {
+	if r.repo == nil {
+		return "", ErrNotGitRepository
+	}
+
+	head, err := r.repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("get repository head failed: %w", err)
+	}
+	origin, err := r.repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return "", fmt.Errorf("get repository remote failed: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "opendeepwiki-publish-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	clone, err := git.PlainCloneContext(ctx, tmpDir, false, &git.CloneOptions{
+		URL:        r.Path,
+		Shared:     true,
+		NoCheckout: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("clone repository failed: %w", err)
+	}
+
+	worktree, err := clone.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("get worktree failed: %w", err)
+	}
+	branch := plumbing.NewBranchReferenceName(options.Branch)
+	err = worktree.Checkout(&git.CheckoutOptions{
+		Branch: branch,
+		Hash:   head.Hash(),
+		Create: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("create branch failed: %w", err)
+	}
+
+	// replace the documentation of a previous publish
+	docDir := filepath.Join(tmpDir, filepath.FromSlash(options.Dir))
+	if err = os.RemoveAll(docDir); err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		target := filepath.Join(docDir, filepath.FromSlash(file.Path))
+		if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return "", err
+		}
+		if err = os.WriteFile(target, file.Content, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	if err = worktree.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		return "", fmt.Errorf("add documentation failed: %w", err)
+	}
+	commit, err := worktree.Commit(options.Message, &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  options.Author,
+			Email: options.Email,
+			When:  time.Now(),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("commit documentation failed: %w", err)
+	}
+
+	_, err = clone.CreateRemote(&gitconfig.RemoteConfig{
+		Name: publishRemote,
+		URLs: origin.Config().URLs,
+	})
+	if err != nil {
+		return "", err
+	}
+	err = clone.PushContext(ctx, &git.PushOptions{
+		RemoteName: publishRemote,
+		RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(branch + ":" + branch)},
+		Auth:       r.auth,
+	})
+	if err != nil {
+		return "", fmt.Errorf("push documentation failed: %w", err)
+	}
+
+	zap.L().Info("Published documentation", zap.String("git_url", r.GitURL), zap.String("branch", options.Branch), zap.String("commit", commit.String()))
+	return commit.String(), nil
+}
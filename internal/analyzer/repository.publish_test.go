@@ -0,0 +1,109 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/o0olele/opendeepwiki-go/internal/export"
+)
+
+func TestPublishPushesBranch(t *testing.T) {
+	dir := t.TempDir()
+	remotePath := filepath.Join(dir, "remote.git")
+	if _, err := git.PlainInit(remotePath, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// the local clone has one commit, pushed to the bare remote
+	localPath := filepath.Join(dir, "local")
+	local, err := git.PlainInit(localPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(localPath, "README.md"), []byte("# sample"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := local.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = worktree.Add("README.md"); err != nil {
+		t.Fatal(err)
+	}
+	head, err := worktree.Commit("initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "tester", Email: "tester@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = local.CreateRemote(&gitconfig.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{remotePath}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = local.Push(&git.PushOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Repository{Path: localPath, repo: local}
+	files := []export.File{
+		{Path: "index.md", Content: []byte("# Overview")},
+		{Path: "guide/setup.md", Content: []byte("# Setup")},
+	}
+	commit, err := r.Publish(context.Background(), files, &PublishOptions{
+		Branch:  "wiki",
+		Dir:     "docs/wiki",
+		Message: "docs: add generated wiki",
+		Author:  "OpenDeepWiki",
+		Email:   "wiki@example.com",
+	})
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	remote, err := git.PlainOpen(remotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := remote.Reference(plumbing.NewBranchReferenceName("wiki"), true)
+	if err != nil {
+		t.Fatalf("branch wiki not pushed: %v", err)
+	}
+	if ref.Hash().String() != commit {
+		t.Fatalf("branch wiki is at %s, want %s", ref.Hash(), commit)
+	}
+
+	pushed, err := remote.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pushed.NumParents() != 1 || pushed.ParentHashes[0] != head {
+		t.Fatalf("published commit parents %v, want %s", pushed.ParentHashes, head)
+	}
+	for path, want := range map[string]string{
+		"README.md":                "# sample",
+		"docs/wiki/index.md":       "# Overview",
+		"docs/wiki/guide/setup.md": "# Setup",
+	} {
+		file, err := pushed.File(path)
+		if err != nil {
+			t.Fatalf("file %s not published: %v", path, err)
+		}
+		if content, _ := file.Contents(); content != want {
+			t.Fatalf("file %s is %q, want %q", path, content, want)
+		}
+	}
+
+	// the checked out tree used for analysis is not touched
+	if _, err = os.Stat(filepath.Join(localPath, "docs")); !os.IsNotExist(err) {
+		t.Fatalf("publish changed the local worktree: %v", err)
+	}
+	if localHead, _ := local.Head(); localHead.Hash() != head {
+		t.Fatalf("publish moved the local head to %s", localHead.Hash())
+	}
+}
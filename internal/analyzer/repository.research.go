@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/progress"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/prompts"
+	"go.uber.org/zap"
+)
+
+// continueResearchPrompt asks the model for the next research iteration.
+const continueResearchPrompt = "Continue the research. Investigate the next steps you listed in the previous iteration, " +
+	"read the relevant files and present the new findings. Do not repeat earlier findings."
+
+// Research investigates a question over several iterations and returns the conclusion.
+// onIteration is called with the findings of each iteration as soon as it is done.
+func (r *Repository) Research(ctx context.Context, question string, rounds int, onIteration func(index int, content string) error) (string, error) {
+	variables := map[string]any{
+		"catalogue":          r.fitPrompt(progress.StageResearch, r.StructedCatalogue, catalogueShare),
+		"question":           question,
+		"git_repository_url": r.GitURL,
+		"language":           r.Language,
+	}
+
+	content, err := formatPrompt(config.FirstPrompt, variables)
+	if err != nil {
+		return "", err
+	}
+
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, content),
+	}
+
+	var findings []string
+	for i := 0; i < rounds; i++ {
+		if i > 0 {
+			messages = append(messages, llms.TextParts(llms.ChatMessageTypeHuman, continueResearchPrompt))
+		}
+
+		var answer string
+		answer, messages, err = r.generateWithTools(ctx, r.stageProvider(progress.StageResearch), progress.StageResearch, messages)
+		if err != nil {
+			return "", fmt.Errorf("research iteration %d failed: %w", i+1, err)
+		}
+		messages = append(messages, llms.TextParts(llms.ChatMessageTypeAI, answer))
+		findings = append(findings, answer)
+
+		if onIteration != nil {
+			if err := onIteration(i+1, answer); err != nil {
+				zap.L().Warn("save research iteration failed", zap.Int("index", i+1), zap.Error(err))
+			}
+		}
+	}
+
+	// conclude with a fresh conversation over the collected findings
+	content, err = formatPrompt(config.DeepFirstPrompt, variables)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(content)
+	sb.WriteString("\n\n<research_findings>\n")
+	for idx, finding := range findings {
+		sb.WriteString(fmt.Sprintf("<iteration index=\"%d\">\n%s\n</iteration>\n", idx+1, finding))
+	}
+	sb.WriteString("</research_findings>\n")
+
+	conclusion, _, err := r.generateWithTools(ctx, r.stageProvider(progress.StageResearch), progress.StageResearch, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, sb.String()),
+	})
+	if err != nil {
+		return "", fmt.Errorf("research conclusion failed: %w", err)
+	}
+
+	return conclusion, nil
+}
+
+func formatPrompt(template string, variables map[string]any) (string, error) {
+	var prompt = prompts.PromptTemplate{
+		Template:         template,
+		PartialVariables: variables,
+		TemplateFormat:   prompts.TemplateFormatGoTemplate,
+	}
+	content, err := prompt.Format(nil)
+	if err != nil {
+		zap.L().Error("cannot format prompt", zap.Error(err))
+		return "", err
+	}
+	return content, nil
+}
@@ -0,0 +1,217 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/tmc/langchaingo/llms"
+)
+
+var pagePattern = regexp.MustCompile(`page-[0-9.]+`)
+
+// documentModel answers each document prompt with the content of the page it asks for.
+type documentModel struct {
+	mu        sync.Mutex
+	failing   string        // page answered with an error
+	delay     time.Duration // time taken by each answer
+	generated []string
+
+	running, maxRunning int
+}
+
+func (m *documentModel) HandleResponse(response llms.ContentResponse) {}
+
+func (m *documentModel) GetModel() llms.Model { return m }
+
+func (m *documentModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (m *documentModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	page := pagePattern.FindString(messages[0].Parts[0].(llms.TextContent).Text)
+	if page == m.failing {
+		return nil, fmt.Errorf("%s is unavailable", page)
+	}
+
+	m.mu.Lock()
+	m.running++
+	m.maxRunning = max(m.maxRunning, m.running)
+	m.mu.Unlock()
+	time.Sleep(m.delay)
+
+	m.mu.Lock()
+	m.running--
+	m.generated = append(m.generated, page)
+	m.mu.Unlock()
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: "<docs>content of " + page + "</docs>"}}}, nil
+}
+
+// memoryCheckpoint keeps the checkpoints of a test in memory.
+type memoryCheckpoint struct {
+	mu        sync.Mutex
+	documents map[string]*WikiDocument
+}
+
+func (c *memoryCheckpoint) Load(key string) (*WikiDocument, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, ok := c.documents[key]
+	return doc, ok
+}
+
+func (c *memoryCheckpoint) Save(key string, doc *WikiDocument) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.documents[key] = doc
+	return nil
+}
+
+func (c *memoryCheckpoint) keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var keys []string
+	for key := range c.documents {
+		keys = append(keys, "page-"+key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// newDocumentRepository returns a repository generating the documents of catalogue with model.
+func newDocumentRepository(t *testing.T, catalogue *DocumentResultCalalogue, model *documentModel, checkpoint DocumentCheckpoint) *Repository {
+	config.LoadTemplates()
+	data, err := json.Marshal(catalogue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Repository{
+		Language:          "English",
+		DocumentCatalogue: string(data),
+		provider:          model,
+		checkpoint:        checkpoint,
+		limiter:           make(chan struct{}, 2),
+	}
+}
+
+// flatten lists the titles and contents of the documents in catalogue order.
+func flatten(doc *WikiDocument) []string {
+	var pages []string
+	for _, child := range doc.Children {
+		pages = append(pages, child.Title+": "+child.Content)
+		pages = append(pages, flatten(child)...)
+	}
+	return pages
+}
+
+func sampleCatalogue() *DocumentResultCalalogue {
+	return &DocumentResultCalalogue{Items: []DocumentResultCalalogueItem{
+		{Title: "Getting Started", Prompt: "page-1", Children: []DocumentResultCalalogueItem{
+			{Title: "Installation", Prompt: "page-1.1"},
+			{Title: "Configuration", Prompt: "page-1.2"},
+		}},
+		{Title: "Architecture", Prompt: "page-2"},
+		{Title: "Deployment", Prompt: "page-3"},
+	}}
+}
+
+func TestCreateDocumentsResumesFromCheckpoint(t *testing.T) {
+	checkpoint := &memoryCheckpoint{documents: make(map[string]*WikiDocument)}
+
+	// the first run stops at the failing page and keeps the finished ones
+	first := &documentModel{failing: "page-1.2"}
+	if _, err := newDocumentRepository(t, sampleCatalogue(), first, checkpoint).CreateDocuments(context.Background()); err == nil {
+		t.Fatal("got no error, want the failed page")
+	}
+	saved := checkpoint.keys()
+	slices.Sort(first.generated)
+	if !slices.Equal(saved, first.generated) {
+		t.Fatalf("checkpoints %v, want the generated pages %v", saved, first.generated)
+	}
+	if slices.Contains(saved, "page-1.2") || !slices.Contains(saved, "page-1") {
+		t.Fatalf("checkpoints %v, want page-1 without page-1.2", saved)
+	}
+
+	// the retry only generates the pages missing from the checkpoint
+	second := &documentModel{}
+	doc, err := newDocumentRepository(t, sampleCatalogue(), second, checkpoint).CreateDocuments(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range second.generated {
+		if slices.Contains(saved, page) {
+			t.Fatalf("%s generated again, it was saved by the first run", page)
+		}
+	}
+	if len(saved)+len(second.generated) != 5 {
+		t.Fatalf("generated %v after %v, want each of the 5 pages once", second.generated, saved)
+	}
+
+	want := []string{
+		"Getting Started: content of page-1",
+		"Installation: content of page-1.1",
+		"Configuration: content of page-1.2",
+		"Architecture: content of page-2",
+		"Deployment: content of page-3",
+	}
+	if got := flatten(doc); !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestCreateDocumentsConcurrently(t *testing.T) {
+	want := []string{
+		"Getting Started: content of page-1",
+		"Installation: content of page-1.1",
+		"Configuration: content of page-1.2",
+		"Architecture: content of page-2",
+		"Deployment: content of page-3",
+	}
+	for _, concurrency := range []int{1, 2, 3} {
+		t.Run(fmt.Sprint(concurrency), func(t *testing.T) {
+			model := &documentModel{delay: 20 * time.Millisecond}
+			r := newDocumentRepository(t, sampleCatalogue(), model, nil)
+			r.limiter = make(chan struct{}, concurrency)
+
+			doc, err := r.CreateDocuments(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the three top level pages are generated together, up to the limit
+			if model.maxRunning != concurrency {
+				t.Fatalf("got %d concurrent generations, want %d", model.maxRunning, concurrency)
+			}
+			if got := flatten(doc); !slices.Equal(got, want) {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCreateDocumentsFailsOnFirstFailure(t *testing.T) {
+	tests := []struct {
+		failing string
+		want    string
+	}{
+		{"page-1", "generate document 1 (Getting Started) failed"},
+		{"page-1.2", "generate document 1.2 (Configuration) failed"},
+		{"page-3", "generate document 3 (Deployment) failed"},
+	}
+	for _, test := range tests {
+		t.Run(test.failing, func(t *testing.T) {
+			model := &documentModel{failing: test.failing}
+			_, err := newDocumentRepository(t, sampleCatalogue(), model, nil).CreateDocuments(context.Background())
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("got %v, want %q", err, test.want)
+			}
+		})
+	}
+}
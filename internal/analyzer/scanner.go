@@ -206,7 +206,9 @@ func (fs *FileScanner) GetCatalogue(repoPath string) ([]PathInfo, error) {
 	return pathInfos, nil
 }
 
-func (fs *FileScanner) GetSimplifyCatalogueString(provider chat.Provider, repoPath string, catalogs []PathInfo, readme string) (string, error) {
+// GetSimplifyCatalogueString asks the model to pick the important paths of a large repository,
+// the tree and the readme are truncated to their share of the context window.
+func (fs *FileScanner) GetSimplifyCatalogueString(ctx context.Context, provider chat.Provider, contextWindow int, repoPath string, catalogs []PathInfo, readme string) (string, error) {
 
 	if len(catalogs) < 800 || !fs.options.EnableSmartFilter {
 		return CatalogueToString(repoPath, catalogs), nil
@@ -215,8 +217,8 @@ func (fs *FileScanner) GetSimplifyCatalogueString(provider chat.Provider, repoPa
 	var prompt = prompts.PromptTemplate{
 		Template: config.SimplifyDirsPrompt,
 		PartialVariables: map[string]any{
-			"code_files": CatalogueToString(repoPath, catalogs),
-			"readme":     readme,
+			"code_files": chat.TruncateText(CatalogueToString(repoPath, catalogs), contextWindow*catalogueShare/100),
+			"readme":     chat.TruncateText(readme, contextWindow*readmeShare/100),
 			"language":   fs.options.Language,
 		},
 		TemplateFormat: prompts.TemplateFormatGoTemplate,
@@ -231,7 +233,7 @@ func (fs *FileScanner) GetSimplifyCatalogueString(provider chat.Provider, repoPa
 		llms.TextParts(llms.ChatMessageTypeHuman, content),
 	}
 
-	response, err := provider.GetModel().GenerateContent(context.Background(), message,
+	response, err := provider.GetModel().GenerateContent(ctx, message,
 		llms.WithMaxTokens(16384),
 	)
 	if err != nil {
@@ -1,10 +1,15 @@
 package analyzer
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
-	"path"
+	"path/filepath"
+	"strings"
 
+	"github.com/o0olele/opendeepwiki-go/internal/llm/chat"
 	"github.com/tmc/langchaingo/jsonschema"
 	"github.com/tmc/langchaingo/llms"
 	"go.uber.org/zap"
@@ -12,6 +17,9 @@ import (
 
 var llmTools []llms.Tool
 
+// maxToolIterations is the maximum number of model round trips in a tool-calling loop.
+const maxToolIterations = 16
+
 func init() {
 
 	llmTools = append(llmTools, llms.Tool{
@@ -59,31 +67,80 @@ func init() {
 	})
 }
 
-func (r *Repository) readFiles(paths []string) string {
-	var (
-		total int
-		dic   = make(map[string]string)
-	)
-
-	for _, p := range paths {
-		item := path.Join(r.Path, p)
-		if info, err := os.Stat(item); err == nil {
-			total += int(info.Size())
-			if total > 81920 {
-				return "target files is too large."
-			}
-
-			content, err := ReadFile(item)
-			if err == nil {
-				dic[p] = content
-			}
+// readFiles returns the content of the files as json, the files share maxTokens and
+// a file larger than its share is truncated in the middle with a marker.
+func (r *Repository) readFiles(paths []string, maxTokens int) string {
+	var dic = make(map[string]string)
+	for idx, p := range paths {
+		item, err := r.resolvePath(p)
+		if err != nil {
+			zap.L().Warn("rejected file requested by the model", zap.String("repository", r.Name), zap.String("path", p), zap.Error(err))
+			dic[p] = "cannot read the file: " + err.Error()
+			continue
+		}
+		if _, err := os.Stat(item); err != nil {
+			continue
+		}
+		content, err := ReadFile(item)
+		if err != nil {
+			continue
 		}
+
+		// the room left by small files goes to the following ones
+		content = chat.TruncateText(content, maxTokens/(len(paths)-idx))
+		maxTokens -= chat.EstimateTokens(content)
+		dic[p] = content
 	}
 	s, _ := json.Marshal(dic)
 	return string(s)
 }
 
+// errOutsideRepository rejects a path that leaves the repository, the paths come from the model
+// and a chat question may ask it to read any file of the server.
+var errOutsideRepository = errors.New("path is outside the repository")
+
+// resolvePath returns the file of a path relative to the repository. Absolute paths, paths
+// leaving the repository and symlinks pointing outside of it are rejected.
+func (r *Repository) resolvePath(p string) (string, error) {
+	if filepath.IsAbs(p) || strings.HasPrefix(p, "/") {
+		return "", errOutsideRepository
+	}
+	root, err := filepath.Abs(r.Path)
+	if err != nil {
+		return "", err
+	}
+	item := filepath.Join(root, filepath.FromSlash(p))
+	if !withinPath(root, item) {
+		return "", errOutsideRepository
+	}
+
+	// a missing file is reported by the caller, an existing one must not link outside
+	resolved, err := filepath.EvalSymlinks(item)
+	if err != nil {
+		return item, nil
+	}
+	if realRoot, err := filepath.EvalSymlinks(root); err == nil {
+		root = realRoot
+	}
+	if !withinPath(root, resolved) {
+		return "", errOutsideRepository
+	}
+	return item, nil
+}
+
+// withinPath reports whether target is root or below it.
+func withinPath(root, target string) bool {
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
 func (r *Repository) searchCode(query string, minRelevance float64) string {
+	if r.codeIndexer == nil {
+		return "code index is not available"
+	}
 
 	results, err := r.codeIndexer.SearchCode(query, r.Description, 3)
 	if err != nil {
@@ -95,6 +152,38 @@ func (r *Repository) searchCode(query string, minRelevance float64) string {
 	return string(s)
 }
 
+// generateWithTools runs the tool-calling loop: the model may call readFiles/seachCode
+// until it answers without tool calls. It returns the final answer and the message history.
+func (r *Repository) generateWithTools(ctx context.Context, provider chat.Provider, stage string, messages []llms.MessageContent) (string, []llms.MessageContent, error) {
+	var str strings.Builder
+	for i := 0; i < maxToolIterations; i++ {
+		if ctx.Err() != nil {
+			return "", messages, ctx.Err()
+		}
+		response, err := provider.GetModel().GenerateContent(ctx, messages,
+			llms.WithTools(llmTools),
+			llms.WithStreamingFunc(r.streamingFunc(stage)),
+		)
+		// the provider already retried transient errors
+		if err != nil {
+			zap.L().Warn("cannot get model response", zap.Error(err))
+			return "", messages, err
+		}
+		choice := response.Choices[0]
+		if len(choice.ToolCalls) > 0 {
+			messages = r.updateMessageHistory(messages, choice)
+			messages = r.executeToolCalls(provider.GetModel(), stage, messages, choice)
+		} else {
+			str.WriteString(choice.Content)
+			break
+		}
+	}
+	if str.Len() == 0 {
+		return "", messages, fmt.Errorf("cannot get model answer")
+	}
+	return str.String(), messages, nil
+}
+
 func (r *Repository) updateMessageHistory(messageHistory []llms.MessageContent, choice *llms.ContentChoice) []llms.MessageContent {
 	assistantResponse := llms.TextParts(llms.ChatMessageTypeAI, choice.Content)
 	for _, tc := range choice.ToolCalls {
@@ -103,8 +192,10 @@ func (r *Repository) updateMessageHistory(messageHistory []llms.MessageContent,
 	return append(messageHistory, assistantResponse)
 }
 
-func (r *Repository) executeToolCalls(llm llms.Model, messageHistory []llms.MessageContent, choice *llms.ContentChoice) []llms.MessageContent {
+func (r *Repository) executeToolCalls(llm llms.Model, stage string, messageHistory []llms.MessageContent, choice *llms.ContentChoice) []llms.MessageContent {
 
+	// the langchaingo openai client returns streamed fragments as separate calls,
+	// the deepseek, vllm and llamacpp providers assemble them already
 	for idx, toolCall := range choice.ToolCalls {
 		if len(toolCall.ID) <= 0 {
 			continue
@@ -139,7 +230,7 @@ func (r *Repository) executeToolCalls(llm llms.Model, messageHistory []llms.Mess
 					llms.ToolCallResponse{
 						ToolCallID: toolCall.ID,
 						Name:       toolCall.FunctionCall.Name,
-						Content:    r.readFiles(args.FilePaths),
+						Content:    r.readFiles(args.FilePaths, r.readBudget(stage)),
 					},
 				},
 			}
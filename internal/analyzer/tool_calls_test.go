@@ -0,0 +1,116 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/o0olele/opendeepwiki-go/internal/llm/chat"
+)
+
+func TestReadFilesStaysInRepository(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "repo")
+	outside := filepath.Join(dir, "secret.txt")
+	if err := os.MkdirAll(filepath.Join(root, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "src", "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(outside, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "src", "main.go"), filepath.Join(root, "inside.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Repository{Path: root}
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{"src/main.go", true},
+		{"./src/../src/main.go", true},
+		{"inside.go", true},
+		{"../secret.txt", false},
+		{"src/../../secret.txt", false},
+		{outside, false},
+		{"/etc/passwd", false},
+		{"link.txt", false},
+	}
+	for _, test := range tests {
+		if _, err := r.resolvePath(test.path); (err == nil) != test.ok {
+			t.Errorf("resolvePath(%q) error = %v, want ok %v", test.path, err, test.ok)
+		}
+	}
+
+	var files map[string]string
+	if err := json.Unmarshal([]byte(r.readFiles([]string{"src/main.go", "../secret.txt", "link.txt"}, 1000)), &files); err != nil {
+		t.Fatal(err)
+	}
+	if files["src/main.go"] != "package main" {
+		t.Errorf("src/main.go = %q", files["src/main.go"])
+	}
+	for _, p := range []string{"../secret.txt", "link.txt"} {
+		if files[p] == "secret" {
+			t.Errorf("%s was read outside the repository", p)
+		}
+	}
+}
+
+func TestReadFilesSharesTokens(t *testing.T) {
+	root := t.TempDir()
+	large := func(name string) string {
+		var b strings.Builder
+		for i := 0; i < 500; i++ {
+			fmt.Fprintf(&b, "func %s%d() int { return %d }\n", name, i, i)
+		}
+		return b.String()
+	}
+	files := map[string]string{
+		"small.go":  "package small",
+		"first.go":  large("first"),
+		"second.go": large("second"),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const maxTokens = 1000
+	r := &Repository{Path: root}
+	var read map[string]string
+	if err := json.Unmarshal([]byte(r.readFiles([]string{"small.go", "first.go", "second.go"}, maxTokens)), &read); err != nil {
+		t.Fatal(err)
+	}
+
+	if read["small.go"] != files["small.go"] {
+		t.Fatalf("small.go = %q, want it whole", read["small.go"])
+	}
+	var total int
+	for _, name := range []string{"first.go", "second.go"} {
+		content := read[name]
+		if !strings.Contains(content, "tokens truncated to fit the context window") {
+			t.Fatalf("%s has no truncation marker", name)
+		}
+		if !strings.HasPrefix(content, files[name][:100]) || !strings.HasSuffix(content, files[name][len(files[name])-20:]) {
+			t.Fatalf("%s does not keep its beginning and end", name)
+		}
+		// an even split would leave a third of the tokens, the small file leaves more
+		if tokens := chat.EstimateTokens(content); tokens <= maxTokens/3 {
+			t.Fatalf("%s got %d tokens, want the room left by small.go", name, tokens)
+		}
+		total += chat.EstimateTokens(content)
+	}
+	if total += chat.EstimateTokens(read["small.go"]); total > maxTokens {
+		t.Fatalf("files take %d tokens, want at most %d", total, maxTokens)
+	}
+}
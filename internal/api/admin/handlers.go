@@ -0,0 +1,259 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/o0olele/opendeepwiki-go/internal/api/middleware"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/llm/chat"
+	"github.com/o0olele/opendeepwiki-go/internal/services"
+)
+
+// defaultMaxTokens is used when a profile does not set max_tokens.
+const defaultMaxTokens = 8192
+
+// AdminHandler LLM settings handler.
+type AdminHandler struct {
+	settingsDao *dao.LLMSettingsDAO
+}
+
+// NewAdminHandler Create a new admin handler.
+func NewAdminHandler() *AdminHandler {
+	return &AdminHandler{
+		settingsDao: dao.NewLLMSettingsDAO(),
+	}
+}
+
+// ListLLMSettings List the LLM settings profiles.
+func (h *AdminHandler) ListLLMSettings(c *gin.Context) {
+	list, err := h.settingsDao.ListSettings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var result = make([]*LLMSettings, 0, len(list))
+	for _, settings := range list {
+		response, ok := newResponse(c, settings)
+		if !ok {
+			return
+		}
+		result = append(result, response)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// CreateLLMSettings Create a LLM settings profile, it becomes live if is_default is set.
+func (h *AdminHandler) CreateLLMSettings(c *gin.Context) {
+	var req LLMSettingsRequest
+	if !bindSettingsRequest(c, &req) {
+		return
+	}
+
+	settings := &models.LLMSettings{}
+	if !applyRequest(c, settings, &req) {
+		return
+	}
+	settings.IsDefault = false
+	if err := h.settingsDao.CreateSettings(settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create settings: " + err.Error()})
+		return
+	}
+
+	if req.IsDefault && !h.switchDefault(c, settings) {
+		return
+	}
+	if response, ok := newResponse(c, settings); ok {
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// UpdateLLMSettings Update a LLM settings profile, changes to the default profile are applied immediately.
+func (h *AdminHandler) UpdateLLMSettings(c *gin.Context) {
+	settings, ok := h.getSettings(c)
+	if !ok {
+		return
+	}
+
+	var req LLMSettingsRequest
+	if !bindSettingsRequest(c, &req) {
+		return
+	}
+
+	isDefault := settings.IsDefault
+	if !applyRequest(c, settings, &req) {
+		return
+	}
+	settings.IsDefault = isDefault
+	if err := h.settingsDao.UpdateSettings(settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings: " + err.Error()})
+		return
+	}
+
+	if settings.IsDefault {
+		if err := services.ApplyLLMSettings(settings); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply settings: " + err.Error()})
+			return
+		}
+	} else if req.IsDefault && !h.switchDefault(c, settings) {
+		return
+	}
+	if response, ok := newResponse(c, settings); ok {
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// DeleteLLMSettings Delete a LLM settings profile, the default profile cannot be deleted.
+func (h *AdminHandler) DeleteLLMSettings(c *gin.Context) {
+	settings, ok := h.getSettings(c)
+	if !ok {
+		return
+	}
+
+	if settings.IsDefault {
+		c.JSON(http.StatusConflict, gin.H{"error": "The default settings cannot be deleted, switch to another profile first"})
+		return
+	}
+
+	if err := h.settingsDao.DeleteSettings(settings.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete settings: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Settings deleted"})
+}
+
+// TestLLMSettings Make a tiny completion with a profile to check the provider, key and model.
+func (h *AdminHandler) TestLLMSettings(c *gin.Context) {
+	settings, ok := h.getSettings(c)
+	if !ok {
+		return
+	}
+
+	start := time.Now()
+	reply, err := services.TestLLMSettings(c.Request.Context(), settings)
+	latency := time.Since(start).Milliseconds()
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"ok":         false,
+			"error":      err.Error(),
+			"latency_ms": latency,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":         true,
+		"reply":      reply,
+		"latency_ms": latency,
+	})
+}
+
+// SetDefaultLLMSettings Switch the live LLM settings without restarting, running tasks finish with the previous ones.
+func (h *AdminHandler) SetDefaultLLMSettings(c *gin.Context) {
+	settings, ok := h.getSettings(c)
+	if !ok {
+		return
+	}
+
+	if !h.switchDefault(c, settings) {
+		return
+	}
+	if response, ok := newResponse(c, settings); ok {
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// switchDefault makes the profile the default and live one, it writes the error response on failure.
+func (h *AdminHandler) switchDefault(c *gin.Context, settings *models.LLMSettings) bool {
+	if err := h.settingsDao.SetDefaultSettings(settings.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to switch settings: " + err.Error()})
+		return false
+	}
+	settings.IsDefault = true
+	if err := services.ApplyLLMSettings(settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply settings: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// newResponse describes the profile with its api key masked, it writes the error response on failure.
+func newResponse(c *gin.Context, settings *models.LLMSettings) (*LLMSettings, bool) {
+	apiKey, err := services.LLMSettingsKey(settings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return NewLLMSettings(settings, apiKey), true
+}
+
+// getSettings loads the profile of the id path parameter, it writes the error response on failure.
+func (h *AdminHandler) getSettings(c *gin.Context) (*models.LLMSettings, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid settings id"})
+		return nil, false
+	}
+
+	settings, err := h.settingsDao.GetSettingsByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "settings not found"})
+		return nil, false
+	}
+	return settings, true
+}
+
+func bindSettingsRequest(c *gin.Context, req *LLMSettingsRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return false
+	}
+	if !chat.IsSupported(chat.ProviderType(req.ProviderType)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported provider type"})
+		return false
+	}
+	if req.MaxTokens < 0 || req.ContextWindow < 0 || req.Temperature < 0 || req.Temperature > 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid max_tokens, context_window or temperature"})
+		return false
+	}
+	return true
+}
+
+// applyRequest copies the request into the profile, it writes the error response on failure.
+func applyRequest(c *gin.Context, settings *models.LLMSettings, req *LLMSettingsRequest) bool {
+	settings.ProviderType = req.ProviderType
+	settings.ModelLLM = req.Model
+	settings.BaseURL = req.BaseURL
+	settings.APIVersion = req.APIVersion
+	settings.MaxTokens = req.MaxTokens
+	settings.Temperature = req.Temperature
+	settings.ContextWindow = req.ContextWindow
+	if settings.MaxTokens == 0 {
+		settings.MaxTokens = defaultMaxTokens
+	}
+	// the listed key is masked, an empty key keeps the stored one
+	if len(req.APIKey) > 0 {
+		if err := services.SetLLMSettingsKey(settings, req.APIKey); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid api key: " + err.Error()})
+			return false
+		}
+	}
+	return true
+}
+
+// RegisterRoutes Register admin routes.
+func RegisterRoutes(router *gin.RouterGroup) {
+	handler := NewAdminHandler()
+
+	group := router.Group("/admin", middleware.RequireAdminToken)
+	group.GET("/llm", handler.ListLLMSettings)
+	group.POST("/llm", handler.CreateLLMSettings)
+	group.PUT("/llm/:id", handler.UpdateLLMSettings)
+	group.DELETE("/llm/:id", handler.DeleteLLMSettings)
+	group.POST("/llm/:id/test", handler.TestLLMSettings)
+	group.POST("/llm/:id/default", handler.SetDefaultLLMSettings)
+}
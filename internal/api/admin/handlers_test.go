@@ -0,0 +1,209 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/services"
+)
+
+const testAdminToken = "admin-token"
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "opendeepwiki-admin-")
+	if err != nil {
+		panic(err)
+	}
+	config.GetSecurityConfig().SecretKey = "test-secret"
+	if err = database.InitDB(filepath.Join(dir, "test.db")); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func newTestRouter(t *testing.T) *gin.Engine {
+	security := config.GetSecurityConfig()
+	previous := security.AdminToken
+	security.AdminToken = testAdminToken
+	t.Cleanup(func() { security.AdminToken = previous })
+
+	// switching profiles changes the live llm configuration
+	llm := *config.GetLLMConfig()
+	t.Cleanup(func() { config.SetLLMConfig(llm) })
+
+	router := gin.New()
+	RegisterRoutes(router.Group("/api"))
+	return router
+}
+
+// send sends the body as json with the admin token and returns the status and the raw response.
+func send(router *gin.Engine, method, path string, body any) (int, []byte) {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	request := httptest.NewRequest(method, path, bytes.NewReader(data))
+	request.Header.Set("Authorization", "Bearer "+testAdminToken)
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder.Code, recorder.Body.Bytes()
+}
+
+// createSettings creates a profile through the api and removes it after the test.
+func createSettings(t *testing.T, router *gin.Engine, req LLMSettingsRequest) *LLMSettings {
+	code, body := send(router, http.MethodPost, "/api/admin/llm", req)
+	if code != http.StatusCreated {
+		t.Fatalf("create: got %d %s, want 201", code, body)
+	}
+	var settings LLMSettings
+	if err := json.Unmarshal(body, &settings); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Delete(&models.LLMSettings{}, settings.ID)
+	})
+	return &settings
+}
+
+func TestAdminTokenRequired(t *testing.T) {
+	router := newTestRouter(t)
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"missing token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer other", http.StatusUnauthorized},
+		{"valid token", "Bearer " + testAdminToken, http.StatusOK},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/api/admin/llm", nil)
+		if len(test.header) > 0 {
+			request.Header.Set("Authorization", test.header)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, recorder.Code, test.status)
+		}
+	}
+
+	// a rejected request changes nothing
+	request := httptest.NewRequest(http.MethodPost, "/api/admin/llm", strings.NewReader(`{"provider_type":"openai","model":"gpt-4o"}`))
+	request.Header.Set("Authorization", "Bearer other")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("create with a wrong token: status %d, want 401", recorder.Code)
+	}
+	var count int64
+	database.GetDB().Model(&models.LLMSettings{}).Where("model_llm = ?", "gpt-4o").Count(&count)
+	if count != 0 {
+		t.Fatalf("got %d profiles created with a wrong token", count)
+	}
+}
+
+func TestCreateAndUpdateLLMSettings(t *testing.T) {
+	router := newTestRouter(t)
+	const apiKey = "sk-test-1234567890"
+
+	created := createSettings(t, router, LLMSettingsRequest{ProviderType: "openai", APIKey: apiKey, Model: "gpt-4o-mini"})
+	if created.APIKey != "sk-****7890" {
+		t.Fatalf("got key %q, want it masked", created.APIKey)
+	}
+	if created.MaxTokens != defaultMaxTokens || created.IsDefault {
+		t.Fatalf("got max tokens %d and default %v", created.MaxTokens, created.IsDefault)
+	}
+
+	// the key is saved encrypted
+	settingsDao := dao.NewLLMSettingsDAO()
+	settings, err := settingsDao.GetSettingsByID(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(settings.APIKey, apiKey) {
+		t.Fatalf("key is saved in plain text: %q", settings.APIKey)
+	}
+
+	// an empty key keeps the saved one
+	path := fmt.Sprintf("/api/admin/llm/%d", created.ID)
+	code, body := send(router, http.MethodPut, path, LLMSettingsRequest{ProviderType: "openai", Model: "gpt-4o"})
+	if code != http.StatusOK {
+		t.Fatalf("update: got %d %s, want 200", code, body)
+	}
+	settings, err = settingsDao.GetSettingsByID(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := services.LLMSettingsKey(settings); err != nil || key != apiKey || settings.ModelLLM != "gpt-4o" {
+		t.Fatalf("got model %s and key %q (%v), want gpt-4o with the old key", settings.ModelLLM, key, err)
+	}
+
+	// the list masks every key
+	code, body = send(router, http.MethodGet, "/api/admin/llm", nil)
+	if code != http.StatusOK {
+		t.Fatalf("list: got %d %s, want 200", code, body)
+	}
+	if strings.Contains(string(body), apiKey) || !strings.Contains(string(body), "sk-****7890") {
+		t.Fatalf("list does not mask the key: %s", body)
+	}
+
+	code, body = send(router, http.MethodPost, "/api/admin/llm", LLMSettingsRequest{ProviderType: "unknown", Model: "gpt-4o"})
+	if code != http.StatusBadRequest {
+		t.Fatalf("create with an unknown provider: got %d %s, want 400", code, body)
+	}
+}
+
+func TestSetDefaultLLMSettings(t *testing.T) {
+	router := newTestRouter(t)
+	first := createSettings(t, router, LLMSettingsRequest{ProviderType: "openai", APIKey: "sk-first-key-0001", Model: "first-model"})
+	second := createSettings(t, router, LLMSettingsRequest{ProviderType: "ollama", APIKey: "sk-second-key-0002", Model: "second-model"})
+
+	keys := map[uint]string{first.ID: "sk-first-key-0001", second.ID: "sk-second-key-0002"}
+	for _, settings := range []*LLMSettings{first, second} {
+		code, body := send(router, http.MethodPost, fmt.Sprintf("/api/admin/llm/%d/default", settings.ID), nil)
+		if code != http.StatusOK {
+			t.Fatalf("default: got %d %s, want 200", code, body)
+		}
+
+		// the profile is the only default one and the live configuration
+		var defaults []*models.LLMSettings
+		database.GetDB().Where("is_default = ?", true).Find(&defaults)
+		if len(defaults) != 1 || defaults[0].ID != settings.ID {
+			t.Fatalf("got %d default profiles, want only %d", len(defaults), settings.ID)
+		}
+		llm := config.GetLLMConfig()
+		if llm.Model != settings.Model || llm.ProviderType != settings.ProviderType {
+			t.Fatalf("live model is %s/%s, want %s/%s", llm.ProviderType, llm.Model, settings.ProviderType, settings.Model)
+		}
+		if llm.APIKey != keys[settings.ID] {
+			t.Fatalf("live key is %q, want the decrypted %q", llm.APIKey, keys[settings.ID])
+		}
+	}
+
+	// the default profile cannot be deleted
+	if code, body := send(router, http.MethodDelete, fmt.Sprintf("/api/admin/llm/%d", second.ID), nil); code != http.StatusConflict {
+		t.Fatalf("delete the default: got %d %s, want 409", code, body)
+	}
+	if code, body := send(router, http.MethodDelete, fmt.Sprintf("/api/admin/llm/%d", first.ID), nil); code != http.StatusOK {
+		t.Fatalf("delete: got %d %s, want 200", code, body)
+	}
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"strings"
+
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+)
+
+// LLMSettings is a settings profile, the api key is masked.
+type LLMSettings struct {
+	ID            uint    `json:"id"`
+	ProviderType  string  `json:"provider_type"`
+	APIKey        string  `json:"api_key"`
+	Model         string  `json:"model"`
+	BaseURL       string  `json:"base_url"`
+	APIVersion    string  `json:"api_version"`
+	MaxTokens     int     `json:"max_tokens"`
+	Temperature   float64 `json:"temperature"`
+	ContextWindow int     `json:"context_window"`
+	IsDefault     bool    `json:"is_default"`
+}
+
+// LLMSettingsRequest creates or updates a settings profile, an empty api key keeps the current one on update.
+type LLMSettingsRequest struct {
+	ProviderType  string  `json:"provider_type" binding:"required"`
+	APIKey        string  `json:"api_key"`
+	Model         string  `json:"model" binding:"required"`
+	BaseURL       string  `json:"base_url"`
+	APIVersion    string  `json:"api_version"` // azure only
+	MaxTokens     int     `json:"max_tokens"`
+	Temperature   float64 `json:"temperature"`
+	ContextWindow int     `json:"context_window"` // 0 looks the model up
+	IsDefault     bool    `json:"is_default"`
+}
+
+// NewLLMSettings describes a profile, apiKey is its decrypted key.
+func NewLLMSettings(settings *models.LLMSettings, apiKey string) *LLMSettings {
+	return &LLMSettings{
+		ID:            settings.ID,
+		ProviderType:  settings.ProviderType,
+		APIKey:        maskKey(apiKey),
+		Model:         settings.ModelLLM,
+		BaseURL:       settings.BaseURL,
+		APIVersion:    settings.APIVersion,
+		MaxTokens:     settings.MaxTokens,
+		Temperature:   settings.Temperature,
+		ContextWindow: settings.ContextWindow,
+		IsDefault:     settings.IsDefault,
+	}
+}
+
+// maskKey keeps the prefix and the last characters of a key so profiles can be told apart.
+func maskKey(key string) string {
+	if len(key) == 0 {
+		return ""
+	}
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:3] + strings.Repeat("*", 4) + key[len(key)-4:]
+}
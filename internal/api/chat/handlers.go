@@ -0,0 +1,82 @@
+package chat
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/o0olele/opendeepwiki-go/internal/analyzer"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"go.uber.org/zap"
+)
+
+// ChatHandler Repository Q&A handler.
+type ChatHandler struct {
+	repoDao *dao.RepositoryDAO
+}
+
+// NewChatHandler Create a new chat handler.
+func NewChatHandler() *ChatHandler {
+	return &ChatHandler{
+		repoDao: dao.NewRepositoryDAO(),
+	}
+}
+
+// Chat Answer a question about a documented repository.
+func (h *ChatHandler) Chat(c *gin.Context) {
+	var req ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	repo, err := h.repoDao.GetRepositoryByID(req.RepoId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Repository not found",
+		})
+		return
+	}
+
+	if repo.Status != models.RepositoryStatusCompleted {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Repository is not ready yet",
+		})
+		return
+	}
+
+	r, err := analyzer.NewRepositoryFromModel(repo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to open repository: " + err.Error(),
+		})
+		return
+	}
+
+	if err = r.LoadCodeIndex(); err != nil {
+		// search code is unavailable, the model can still read files
+		zap.L().Warn("load code index failed", zap.Uint("repository_id", repo.ID), zap.Error(err))
+	}
+
+	answer, err := r.Chat(c.Request.Context(), req.Question, req.History)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to answer: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, &ChatResponse{
+		Answer: answer.Answer,
+		Files:  answer.Files,
+	})
+}
+
+// RegisterRoutes Register chat routes.
+func RegisterRoutes(router *gin.RouterGroup) {
+	handler := NewChatHandler()
+
+	router.POST("/chat", handler.Chat)
+}
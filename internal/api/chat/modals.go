@@ -0,0 +1,14 @@
+package chat
+
+import "github.com/o0olele/opendeepwiki-go/internal/analyzer"
+
+type ChatRequest struct {
+	RepoId   uint                   `json:"id" binding:"required"`
+	Question string                 `json:"question" binding:"required"`
+	History  []analyzer.ChatMessage `json:"history"`
+}
+
+type ChatResponse struct {
+	Answer string   `json:"answer"`
+	Files  []string `json:"files"`
+}
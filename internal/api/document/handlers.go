@@ -1,23 +1,29 @@
 package document
 
 import (
+	"bytes"
+	"fmt"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/export"
 )
 
 // DocumentHandler Document handler.
 type DocumentHandler struct {
-	docDao  *dao.DocumentDao
-	repoDao *dao.RepositoryDAO
+	docDao    *dao.DocumentDao
+	repoDao   *dao.RepositoryDAO
+	recordDao *dao.DocumentCommitRecordDao
 }
 
 // NewDocumentHandler Create a new document handler.
 func NewDocumentHandler() *DocumentHandler {
 	return &DocumentHandler{
-		docDao:  dao.NewDocumentDao(),
-		repoDao: dao.NewRepositoryDAO(),
+		docDao:    dao.NewDocumentDao(),
+		repoDao:   dao.NewRepositoryDAO(),
+		recordDao: dao.NewDocumentCommitRecordDao(),
 	}
 }
 
@@ -84,6 +90,75 @@ func (h *DocumentHandler) GetDetail(c *gin.Context) {
 	return
 }
 
+// GetHistory Get the changelog of a repository, newest first.
+func (h *DocumentHandler) GetHistory(c *gin.Context) {
+	repoId, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid repository id"})
+		return
+	}
+
+	records, err := h.recordDao.GetRecordsByRepoId(uint(repoId), models.DocumentRecordHistory)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	var list = make([]*History, 0, len(records))
+	for _, record := range records {
+		list = append(list, &History{
+			Title:   record.Title,
+			Content: record.CommitMessage,
+			Author:  record.Author,
+			Date:    record.Date,
+		})
+	}
+
+	c.JSON(200, list)
+}
+
+// Export Download the wiki of a repository as a zip of Markdown or HTML files, format defaults to markdown.
+func (h *DocumentHandler) Export(c *gin.Context) {
+	repoId, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid repository id"})
+		return
+	}
+
+	format := c.DefaultQuery("format", export.FormatMarkdown)
+	if format != export.FormatMarkdown && format != export.FormatHTML {
+		c.JSON(400, gin.H{"error": "format must be markdown or html"})
+		return
+	}
+
+	repo, err := h.repoDao.GetRepositoryByID(uint(repoId))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "repository not found"})
+		return
+	}
+
+	docs, err := h.docDao.GetDocumentByRepoId(repo.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	files, err := export.Build(repo.Name, repo.Overview, docs, format)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	var buffer bytes.Buffer
+	if err = export.WriteZip(&buffer, repo.Name, files); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.zip"`, repo.Name, format))
+	c.Data(200, "application/zip", buffer.Bytes())
+}
+
 // RegisterRoutes Register repository routes.
 func RegisterRoutes(router *gin.RouterGroup) {
 	handler := NewDocumentHandler()
@@ -91,4 +166,6 @@ func RegisterRoutes(router *gin.RouterGroup) {
 	group := router.Group("/doc")
 	group.GET("/:id", handler.GetOverview)
 	group.GET("/detail", handler.GetDetail)
+	group.GET("/:id/history", handler.GetHistory)
+	group.GET("/:id/export", handler.Export)
 }
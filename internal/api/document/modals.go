@@ -1,6 +1,11 @@
 package document
 
-import "github.com/o0olele/opendeepwiki-go/internal/database/models"
+import (
+	"sort"
+	"time"
+
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+)
 
 type Document struct {
 	ID      uint   `json:"id"`
@@ -8,6 +13,13 @@ type Document struct {
 	Title   string `json:"title"`
 }
 
+type History struct {
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+}
+
 type Overview struct {
 	ID       uint       `json:"id"`
 	Content  string     `json:"content"`
@@ -36,7 +48,15 @@ func NewOverview(id uint, content string, docs []*models.Document) *Overview {
 		catalogMap[doc.Index] = catalog
 	}
 
-	for _, catalog := range catalogMap {
+	// walk in index order so siblings keep the catalogue order
+	var indexes = make([]int, 0, len(catalogMap))
+	for index := range catalogMap {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	for _, index := range indexes {
+		catalog := catalogMap[index]
 		if catalog.ParentId == 0 {
 			continue
 		}
@@ -47,7 +67,8 @@ func NewOverview(id uint, content string, docs []*models.Document) *Overview {
 		parentCatalog.Children = append(parentCatalog.Children, catalog)
 	}
 
-	for _, catalog := range catalogMap {
+	for _, index := range indexes {
+		catalog := catalogMap[index]
 		if catalog.ParentId == 0 {
 			tmp.Catalogs = append(tmp.Catalogs, catalog)
 		}
@@ -0,0 +1,180 @@
+package hooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/services"
+	"go.uber.org/zap"
+)
+
+// maxPayloadSize is the size of a webhook payload.
+const maxPayloadSize = 25 << 20
+
+// WebhookHandler Push webhook handler.
+type WebhookHandler struct {
+	taskDao *dao.RepositoryTaskDAO
+}
+
+// NewWebhookHandler Create a new webhook handler.
+func NewWebhookHandler() *WebhookHandler {
+	return &WebhookHandler{
+		taskDao: dao.NewRepositoryTaskDAO(),
+	}
+}
+
+// GitHub Handle a GitHub push event, signed with X-Hub-Signature-256.
+func (h *WebhookHandler) GitHub(c *gin.Context) {
+	h.handle(c, "push", c.GetHeader("X-GitHub-Event"), func(secret string, body []byte) bool {
+		signature := c.GetHeader("X-Hub-Signature-256")
+		if !strings.HasPrefix(signature, "sha256=") {
+			return false
+		}
+		return verifySignature(secret, body, strings.TrimPrefix(signature, "sha256="))
+	})
+}
+
+// GitLab Handle a GitLab push event, authenticated with X-Gitlab-Token.
+func (h *WebhookHandler) GitLab(c *gin.Context) {
+	h.handle(c, "Push Hook", c.GetHeader("X-Gitlab-Event"), func(secret string, body []byte) bool {
+		token := c.GetHeader("X-Gitlab-Token")
+		return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+	})
+}
+
+// Gitea Handle a Gitea push event, signed with X-Gitea-Signature.
+func (h *WebhookHandler) Gitea(c *gin.Context) {
+	h.handle(c, "push", c.GetHeader("X-Gitea-Event"), func(secret string, body []byte) bool {
+		return verifySignature(secret, body, c.GetHeader("X-Gitea-Signature"))
+	})
+}
+
+// handle verifies the request and refreshes the completed tasks of the pushed branch.
+func (h *WebhookHandler) handle(c *gin.Context, pushEvent, event string, verify func(secret string, body []byte) bool) {
+	secret := config.GetSecurityConfig().WebhookSecret
+	if len(secret) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Webhooks are disabled"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPayloadSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if !verify(secret, body) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	}
+
+	// ping and other events are acknowledged so the provider marks the hook as working
+	if event != pushEvent {
+		c.JSON(http.StatusOK, gin.H{"message": "Event ignored", "event": event})
+		return
+	}
+
+	var payload PushEvent
+	if err = json.Unmarshal(body, &payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload: " + err.Error()})
+		return
+	}
+
+	branch := payload.Branch()
+	if len(branch) == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Only branch pushes are handled", "ref": payload.Ref})
+		return
+	}
+
+	urls := payload.URLs()
+	tasks, err := h.taskDao.ListRepositoryTasksByURLPaths(models.RepositorySourceGit, urlPaths(urls))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var (
+		taskIds     = make([]uint, 0)
+		deferredIds = make([]uint, 0)
+	)
+	for _, task := range tasks {
+		if !urls[normalizeGitURL(task.GitURL)] || !matchRef(task.Ref, branch, payload.DefaultBranch()) {
+			continue
+		}
+
+		// tasks still being generated or synced are synced again once they complete
+		deferred, err := services.RefreshOrDefer(task)
+		if err != nil {
+			zap.L().Info("Skip webhook refresh", zap.Uint("task_id", task.ID), zap.Error(err))
+			continue
+		}
+		if deferred {
+			deferredIds = append(deferredIds, task.ID)
+		} else {
+			taskIds = append(taskIds, task.ID)
+		}
+	}
+
+	if len(taskIds) == 0 && len(deferredIds) == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "No repository to refresh", "task_ids": taskIds})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":           "Repository submitted for sync",
+		"task_ids":          taskIds,
+		"deferred_task_ids": deferredIds, // running tasks, synced once they complete
+	})
+}
+
+// urlPaths returns the paths of the normalized urls, the host is left out as a task url may carry a port or
+// use the scp-like syntax.
+func urlPaths(urls map[string]bool) []string {
+	paths := make([]string, 0, len(urls))
+	for u := range urls {
+		if slash := strings.Index(u, "/"); slash >= 0 && slash < len(u)-1 {
+			paths = append(paths, u[slash+1:])
+		}
+	}
+	return paths
+}
+
+// matchRef reports whether a task documents the pushed branch, tasks without a ref follow the default branch.
+// A push of an unknown default branch matches none of them.
+func matchRef(ref, branch, defaultBranch string) bool {
+	if len(ref) == 0 {
+		return len(defaultBranch) > 0 && branch == defaultBranch
+	}
+	return ref == branch
+}
+
+// verifySignature checks a hex encoded HMAC-SHA256 of the body.
+func verifySignature(secret string, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// RegisterRoutes Register webhook routes.
+func RegisterRoutes(router *gin.RouterGroup) {
+	handler := NewWebhookHandler()
+
+	group := router.Group("/hooks")
+	group.POST("/github", handler.GitHub)
+	group.POST("/gitlab", handler.GitLab)
+	group.POST("/gitea", handler.Gitea)
+}
@@ -0,0 +1,86 @@
+package hooks
+
+import (
+	"net/url"
+	"strings"
+)
+
+// PushEvent is the part of a GitHub, GitLab or Gitea push payload used to find the repository.
+type PushEvent struct {
+	Ref string `json:"ref"`
+
+	// GitHub and Gitea
+	Repository struct {
+		CloneURL      string `json:"clone_url"`
+		SSHURL        string `json:"ssh_url"`
+		HTMLURL       string `json:"html_url"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"repository"`
+
+	// GitLab
+	Project struct {
+		GitHTTPURL    string `json:"git_http_url"`
+		GitSSHURL     string `json:"git_ssh_url"`
+		WebURL        string `json:"web_url"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"project"`
+}
+
+// URLs returns the normalized urls the repository may have been submitted with.
+func (e *PushEvent) URLs() map[string]bool {
+	var urls = make(map[string]bool)
+	for _, u := range []string{
+		e.Repository.CloneURL, e.Repository.SSHURL, e.Repository.HTMLURL,
+		e.Project.GitHTTPURL, e.Project.GitSSHURL, e.Project.WebURL,
+	} {
+		if normalized := normalizeGitURL(u); len(normalized) > 0 {
+			urls[normalized] = true
+		}
+	}
+	return urls
+}
+
+// Branch returns the pushed branch, it is empty for tags.
+func (e *PushEvent) Branch() string {
+	if !strings.HasPrefix(e.Ref, "refs/heads/") {
+		return ""
+	}
+	return strings.TrimPrefix(e.Ref, "refs/heads/")
+}
+
+func (e *PushEvent) DefaultBranch() string {
+	if len(e.Repository.DefaultBranch) > 0 {
+		return e.Repository.DefaultBranch
+	}
+	return e.Project.DefaultBranch
+}
+
+// normalizeGitURL reduces https, ssh and scp-like urls of a repository to host/path,
+// e.g. git@github.com:owner/repo.git and https://github.com/owner/repo both become github.com/owner/repo.
+func normalizeGitURL(gitURL string) string {
+	gitURL = strings.TrimSpace(gitURL)
+	if len(gitURL) == 0 {
+		return ""
+	}
+
+	var host, path string
+	if strings.Contains(gitURL, "://") {
+		u, err := url.Parse(gitURL)
+		if err != nil {
+			return ""
+		}
+		host, path = u.Hostname(), u.Path
+	} else {
+		// scp-like syntax, user@host:path
+		at := strings.Index(gitURL, "@")
+		colon := strings.Index(gitURL, ":")
+		if colon < 0 || colon < at {
+			return ""
+		}
+		host, path = gitURL[at+1:colon], gitURL[colon+1:]
+	}
+
+	path = strings.Trim(path, "/")
+	path = strings.TrimSuffix(path, ".git")
+	return strings.ToLower(host + "/" + path)
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+)
+
+// RequireAdminToken checks the bearer token, the endpoints are disabled without security.admin_token.
+func RequireAdminToken(c *gin.Context) {
+	token := config.GetSecurityConfig().AdminToken
+	if len(token) == 0 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin endpoints are disabled, configure security.admin_token"})
+		return
+	}
+
+	bearer := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin token"})
+		return
+	}
+}
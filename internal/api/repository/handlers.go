@@ -1,24 +1,44 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/o0olele/opendeepwiki-go/internal/analyzer"
+	"github.com/o0olele/opendeepwiki-go/internal/api/middleware"
+	"github.com/o0olele/opendeepwiki-go/internal/config"
 	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
 	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/progress"
+	"github.com/o0olele/opendeepwiki-go/internal/services"
+	"github.com/o0olele/opendeepwiki-go/internal/utils"
 )
 
 // RepositoryHandler Warehouse handler.
 type RepositoryHandler struct {
-	taskDao *dao.RepositoryTaskDAO
-	repoDao *dao.RepositoryDAO
+	taskDao       *dao.RepositoryTaskDAO
+	repoDao       *dao.RepositoryDAO
+	docDao        *dao.DocumentDao
+	checkpointDao *dao.DocumentCheckpointDao
+	usageDao      *dao.LLMUsageDao
 }
 
 // NewRepositoryHandler Create a new warehouse handler.
 func NewRepositoryHandler() *RepositoryHandler {
 	return &RepositoryHandler{
-		taskDao: dao.NewRepositoryTaskDAO(),
-		repoDao: dao.NewRepositoryDAO(),
+		taskDao:       dao.NewRepositoryTaskDAO(),
+		repoDao:       dao.NewRepositoryDAO(),
+		docDao:        dao.NewDocumentDao(),
+		checkpointDao: dao.NewDocumentCheckpointDao(),
+		usageDao:      dao.NewLLMUsageDao(),
 	}
 }
 
@@ -26,8 +46,10 @@ func NewRepositoryHandler() *RepositoryHandler {
 func (h *RepositoryHandler) CreateRepository(c *gin.Context) {
 	// 解析请求
 	var req struct {
-		GitURL   string `json:"git_url" binding:"required"`
-		Language string `json:"language" binding:"required"`
+		GitURL     string      `json:"git_url" binding:"required"`
+		Ref        string      `json:"ref"` // branch, tag or commit, empty for the default branch
+		Language   string      `json:"language" binding:"required"`
+		Credential *Credential `json:"credential"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -54,23 +76,67 @@ func (h *RepositoryHandler) CreateRepository(c *gin.Context) {
 	}
 
 	// 检查是否已存在相同的仓库任务
-	if task, err := h.taskDao.GetRepositoryTaskByGitURL(req.GitURL); err == nil {
+	existing, err := h.taskDao.GetRepositoryTaskByGitURL(req.GitURL, req.Ref)
+	if err == nil && !isStoppedStatus(existing.Status) {
 		// 已存在进行中的任务
 		c.JSON(http.StatusOK, gin.H{
 			"existing": true,
 			"message":  "Repository already being processed",
-			"task_id":  task.ID,
-			"status":   task.StatusString(),
+			"task_id":  existing.ID,
+			"status":   existing.StatusString(),
 		})
 		return
 	}
 
-	task, err := h.taskDao.CreateRepositoryTask(req.GitURL, req.Language)
+	// 私有仓库的凭据加密保存，未能关联到任务时删除
+	var credentialID uint
+	if req.Credential != nil {
+		credentialID, err = createCredential(req.Credential)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid credential: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	// 失败或取消的任务重新排队
+	if existing != nil {
+		if credentialID != 0 {
+			if err = h.taskDao.UpdateRepositoryTaskCredential(existing.ID, credentialID); err != nil {
+				services.DeleteGitCredential(credentialID)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to update task credential: " + err.Error(),
+				})
+				return
+			}
+			// the replaced credential is not used by any task
+			services.DeleteGitCredential(existing.CredentialID)
+		}
+		if err = services.RequeueTask(existing.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update task: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"existing": true,
+			"message":  "Repository resubmitted for processing",
+			"task_id":  existing.ID,
+		})
+		return
+	}
+
+	// tasks are scheduled fairly between the client addresses, the submitter cannot choose its owner
+	task, err := h.taskDao.CreateRepositoryTask(req.GitURL, req.Ref, req.Language, c.ClientIP(), credentialID)
 	if err != nil {
+		services.DeleteGitCredential(credentialID)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to create task: " + err.Error(),
 		})
+		return
 	}
+	services.TaskQueued(task.ID)
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"message": "Repository submitted for processing",
@@ -109,6 +175,7 @@ func (h *RepositoryHandler) GetRepositoryList(c *gin.Context) {
 		list = append(list, &Repository{
 			RepoId:      repo.ID,
 			URL:         repo.GitURL,
+			Branch:      repo.Branch,
 			Name:        repo.Name,
 			Description: repo.Description,
 			Status:      repo.WebStatus(),
@@ -139,20 +206,486 @@ func (h *RepositoryHandler) GetRepositoryById(c *gin.Context) {
 	c.JSON(http.StatusOK, &Repository{
 		RepoId:      repo.ID,
 		URL:         repo.GitURL,
+		Branch:      repo.Branch,
 		Name:        repo.Name,
 		Description: repo.Description,
 		Status:      repo.WebStatus(),
 	})
 }
 
+// Events Stream the progress of a repository task as Server-Sent Events, id is the task id.
+func (h *RepositoryHandler) Events(c *gin.Context) {
+	taskId, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+
+	// subscribe before reading the task, a status published in between is then buffered
+	events, unsubscribe := progress.Subscribe(uint(taskId))
+	defer unsubscribe()
+
+	task, err := h.taskDao.GetRepositoryTaskByID(uint(taskId))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// send the current status first, the task may already be finished
+	c.SSEvent(progress.EventStatus, &progress.Event{
+		TaskID:  task.ID,
+		Type:    progress.EventStatus,
+		Message: task.StatusString(),
+		Time:    time.Now(),
+	})
+	if isFinishedStatus(task.Status) {
+		return
+	}
+
+	var keepAlive = time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-events:
+			c.SSEvent(event.Type, event)
+			if event.Type == progress.EventStatus &&
+				(event.Message == models.GetStatusString(models.RepositoryStatusCompleted) ||
+					event.Message == models.GetStatusString(models.RepositoryStatusFailed) ||
+					event.Message == models.GetStatusString(models.RepositoryStatusCancelled)) {
+				return false
+			}
+			return true
+		case <-keepAlive.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// SyncRepository Pull the repository and update the documents affected by new commits, id is the task id.
+func (h *RepositoryHandler) SyncRepository(c *gin.Context) {
+	taskId, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+
+	task, err := h.taskDao.GetRepositoryTaskByID(uint(taskId))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return
+	}
+
+	err = services.RefreshTask(task)
+	switch {
+	case errors.Is(err, services.ErrLocalRepository):
+		// imported directories have no remote to pull from
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Imported repositories cannot be synced, regenerate them instead",
+		})
+		return
+	case errors.Is(err, services.ErrTaskNotCompleted):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Only completed repositories and stopped syncs can be synced",
+			"status": task.StatusString(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update task: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Repository submitted for sync",
+		"task_id": task.ID,
+	})
+}
+
+// CancelRepository Cancel a queued or running task, id is the task id.
+func (h *RepositoryHandler) CancelRepository(c *gin.Context) {
+	task, ok := h.getTask(c)
+	if !ok {
+		return
+	}
+
+	if isFinishedStatus(task.Status) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Task is already finished",
+			"status": task.StatusString(),
+		})
+		return
+	}
+
+	// a queued task is skipped by the workers once it is marked as cancelled
+	if err := h.taskDao.UpdateRepositoryTaskStatus(task.ID, models.RepositoryStatusCancelled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update task: " + err.Error(),
+		})
+		return
+	}
+	// a running task publishes its status once it stopped
+	running := services.CancelTask(task.ID)
+	if !running {
+		progress.NewReporter(task.ID).Status(models.GetStatusString(models.RepositoryStatusCancelled))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Task cancelled",
+		"task_id": task.ID,
+		"running": running,
+	})
+}
+
+// RetryRepository Requeue a failed or cancelled task, generated content is kept and a stopped sync syncs again, id is the task id.
+func (h *RepositoryHandler) RetryRepository(c *gin.Context) {
+	task, ok := h.getTask(c)
+	if !ok {
+		return
+	}
+
+	if !isStoppedStatus(task.Status) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Only failed or cancelled tasks can be retried",
+			"status": task.StatusString(),
+		})
+		return
+	}
+
+	if err := services.RequeueTask(task.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update task: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Repository resubmitted for processing",
+		"task_id": task.ID,
+	})
+}
+
+// RegenerateRepository Drop the generated content and documents and analyze the repository again, id is the task id.
+func (h *RepositoryHandler) RegenerateRepository(c *gin.Context) {
+	task, ok := h.getTask(c)
+	if !ok {
+		return
+	}
+
+	if !isFinishedStatus(task.Status) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Task is still being processed",
+			"status": task.StatusString(),
+		})
+		return
+	}
+
+	// the repository row only exists once the clone succeeded
+	if repo, err := h.repoDao.GetRepositoryByGitURLAndBranch(task.GitURL, task.Ref); err == nil {
+		if err = h.repoDao.ResetRepositoryContent(repo.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to reset repository: " + err.Error(),
+			})
+			return
+		}
+		analyzer.RemoveCodeIndex(repo.StructedCodePath, repo.StructedVectorPath)
+		if err = h.docDao.DeleteDocumentsByRepoId(repo.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to delete documents: " + err.Error(),
+			})
+			return
+		}
+		if err = h.checkpointDao.DeleteCheckpointsByRepoId(repo.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to delete document checkpoints: " + err.Error(),
+			})
+			return
+		}
+		// a regeneration asks the model again instead of repeating the cached answers
+		if _, err = services.InvalidateLLMCache(repo.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to invalidate llm cache: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	if err := services.RestartTask(task.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update task: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Repository submitted for regeneration",
+		"task_id": task.ID,
+	})
+}
+
+// PublishRepository Commit the generated wiki as Markdown files on a new branch and push it, id is the task id.
+func (h *RepositoryHandler) PublishRepository(c *gin.Context) {
+	task, ok := h.getTask(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Branch  string `json:"branch"`
+		Dir     string `json:"dir"`
+		Message string `json:"message"`
+		Author  string `json:"author"`
+		Email   string `json:"email"`
+	}
+	// every field is optional
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if task.Status != models.RepositoryStatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Only completed repositories can be published",
+			"status": task.StatusString(),
+		})
+		return
+	}
+	if task.Source == models.RepositorySourceLocal {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Imported repositories have no remote to push to",
+		})
+		return
+	}
+
+	options := &analyzer.PublishOptions{
+		Branch:  req.Branch,
+		Dir:     req.Dir,
+		Message: req.Message,
+		Author:  req.Author,
+		Email:   req.Email,
+	}
+	if len(options.Branch) == 0 {
+		options.Branch = "opendeepwiki/wiki-" + time.Now().Format("20060102150405")
+	}
+	if len(options.Dir) == 0 {
+		options.Dir = "docs/wiki"
+	}
+	if len(options.Message) == 0 {
+		options.Message = "docs: add generated wiki"
+	}
+	if len(options.Author) == 0 {
+		options.Author = "OpenDeepWiki"
+	}
+	if len(options.Email) == 0 {
+		options.Email = "opendeepwiki@localhost"
+	}
+	if plumbing.NewBranchReferenceName(options.Branch).Validate() != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid branch name",
+		})
+		return
+	}
+	if !isValidPublishDir(options.Dir) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid documentation directory",
+		})
+		return
+	}
+
+	record, err := services.PublishDocuments(c.Request.Context(), task, options)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to publish documents: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Documents published",
+		"branch":  options.Branch,
+		"commit":  record.CommitID,
+	})
+}
+
+// ScheduleRepository Set how often the remote is checked for new commits, an empty schedule disables it.
+// The schedule is an interval such as 6h or a cron expression such as "0 3 * * *".
+func (h *RepositoryHandler) ScheduleRepository(c *gin.Context) {
+	task, ok := h.getTask(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Schedule string `json:"schedule"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if task.Source == models.RepositorySourceLocal {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Imported repositories have no remote to check",
+		})
+		return
+	}
+
+	schedule := strings.TrimSpace(req.Schedule)
+	if len(schedule) > 0 {
+		if _, err := utils.ParseSchedule(schedule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid schedule: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	// the repository is created once the task cloned it
+	repo, err := h.repoDao.GetRepositoryByGitURLAndBranch(task.GitURL, task.Ref)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Repository is not cloned yet",
+			"status": task.StatusString(),
+		})
+		return
+	}
+
+	if err = h.repoDao.UpdateRepositorySchedule(repo.ID, schedule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update schedule: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Refresh schedule updated",
+		"task_id":  task.ID,
+		"schedule": schedule,
+	})
+}
+
+// GetRepositoryUsage Report the llm tokens and cost spent on the repository, in total, per stage and per model, id is the task id.
+func (h *RepositoryHandler) GetRepositoryUsage(c *gin.Context) {
+	task, ok := h.getTask(c)
+	if !ok {
+		return
+	}
+
+	repo, err := h.repoDao.GetRepositoryByGitURLAndBranch(task.GitURL, task.Ref)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Repository is not cloned yet",
+			"status": task.StatusString(),
+		})
+		return
+	}
+
+	budget := config.GetLLMConfig().Budget
+	var report = gin.H{
+		"task_id": task.ID,
+		"repo_id": repo.ID,
+		"budget": gin.H{
+			"max_tokens": budget.MaxTokens,
+			"max_cost":   budget.MaxCost,
+		},
+	}
+	for name, groups := range map[string][]string{"total": nil, "stages": {"stage"}, "models": {"model_llm"}} {
+		summaries, err := h.usageDao.SummarizeUsage(repo.ID, groups...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to summarize usage: " + err.Error(),
+			})
+			return
+		}
+		if groups == nil {
+			report[name] = summaries[0]
+		} else {
+			report[name] = summaries
+		}
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ClearRepositoryCache Drop the cached llm responses of the repository, id is the task id.
+func (h *RepositoryHandler) ClearRepositoryCache(c *gin.Context) {
+	task, ok := h.getTask(c)
+	if !ok {
+		return
+	}
+
+	repo, err := h.repoDao.GetRepositoryByGitURLAndBranch(task.GitURL, task.Ref)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Repository is not cloned yet",
+			"status": task.StatusString(),
+		})
+		return
+	}
+
+	count, err := services.InvalidateLLMCache(repo.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to invalidate llm cache: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "LLM cache cleared",
+		"task_id": task.ID,
+		"entries": count,
+	})
+}
+
+// getTask loads the task of the id path parameter, it writes the error response on failure.
+func (h *RepositoryHandler) getTask(c *gin.Context) (*models.RepositoryTask, bool) {
+	taskId, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return nil, false
+	}
+
+	task, err := h.taskDao.GetRepositoryTaskByID(uint(taskId))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return nil, false
+	}
+	return task, true
+}
+
 // RegisterRoutes Register repository routes.
 func RegisterRoutes(router *gin.RouterGroup) {
 	handler := NewRepositoryHandler()
 
 	group := router.Group("/repo")
 	group.POST("/create", handler.CreateRepository)
+	group.POST("/import", handler.ImportRepository)
+	group.POST("/upload", handler.UploadRepository)
 	group.GET("/list", handler.GetRepositoryList)
 	group.GET("/status", handler.GetRepositoryById)
+	// the :id of the routes below is the task id returned by create, not the repository id of status
+	group.GET("/:id/events", handler.Events)
+	group.POST("/:id/sync", handler.SyncRepository)
+	group.POST("/:id/cancel", handler.CancelRepository)
+	group.POST("/:id/retry", handler.RetryRepository)
+	group.POST("/:id/regenerate", handler.RegenerateRepository)
+	// publishing pushes to the remote with the stored credential
+	group.POST("/:id/publish", middleware.RequireAdminToken, handler.PublishRepository)
+	group.PUT("/:id/schedule", handler.ScheduleRepository)
+	group.DELETE("/:id/cache", handler.ClearRepositoryCache)
+	group.GET("/:id/usage", handler.GetRepositoryUsage)
 }
 
 // isValidGitURL Verify that the Git URL format is correct.
@@ -161,7 +694,36 @@ func isValidGitURL(url string) bool {
 	return len(url) > 8 && (url[:8] == "https://" ||
 		url[:7] == "http://" ||
 		url[:6] == "git://" ||
-		url[:4] == "ssh:")
+		url[:4] == "ssh:" ||
+		url[:4] == "git@")
+}
+
+// createCredential stores the credential encrypted and returns its id.
+func createCredential(credential *Credential) (uint, error) {
+	switch credential.Type {
+	case models.GitCredentialToken:
+		return services.CreateGitCredential(credential.Type, credential.Username, credential.Token, "")
+	case models.GitCredentialSSH:
+		return services.CreateGitCredential(credential.Type, credential.Username, credential.PrivateKey, credential.Passphrase)
+	default:
+		return 0, fmt.Errorf("unsupported credential type: %s", credential.Type)
+	}
+}
+
+func isFinishedStatus(status int) bool {
+	return status == models.RepositoryStatusCompleted || isStoppedStatus(status)
+}
+
+// isStoppedStatus reports whether the task ended without completing.
+func isStoppedStatus(status int) bool {
+	return status == models.RepositoryStatusFailed || status == models.RepositoryStatusCancelled
+}
+
+// isValidPublishDir reports whether the directory is a relative path inside the repository.
+func isValidPublishDir(dir string) bool {
+	cleaned := path.Clean(dir)
+	return !path.IsAbs(cleaned) && cleaned != "." && cleaned != ".git" &&
+		!strings.HasPrefix(cleaned, "../") && cleaned != ".." && !strings.HasPrefix(cleaned, ".git/")
 }
 
 func isValidLanguage(language string) bool {
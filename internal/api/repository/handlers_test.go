@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "opendeepwiki-repository-")
+	if err != nil {
+		panic(err)
+	}
+	repoConfig := config.GetRepositoryConfig()
+	repoConfig.Code = filepath.Join(dir, "code")
+	repoConfig.Vector = filepath.Join(dir, "vector")
+	if err = database.InitDB(filepath.Join(dir, "test.db")); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func newTestRouter() *gin.Engine {
+	router := gin.New()
+	RegisterRoutes(router.Group("/api"))
+	return router
+}
+
+// newTestTask creates a task of gitURL with the given status.
+func newTestTask(t *testing.T, gitURL string, status int) *models.RepositoryTask {
+	taskDao := dao.NewRepositoryTaskDAO()
+	task, err := taskDao.CreateRepositoryTask(gitURL, "", models.LanguageEnglish, "tester", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Delete(&models.RepositoryTask{}, task.ID)
+	})
+	if err = taskDao.UpdateRepositoryTaskStatus(task.ID, status); err != nil {
+		t.Fatal(err)
+	}
+	task.Status = status
+	return task
+}
+
+// post sends a request without a body and returns the status and the decoded response.
+func post(router *gin.Engine, path string) (int, map[string]any) {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, nil))
+	var response map[string]any
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+	return recorder.Code, response
+}
+
+func taskStatus(t *testing.T, taskID uint) int {
+	task, err := dao.NewRepositoryTaskDAO().GetRepositoryTaskByID(taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return task.Status
+}
+
+func TestTaskActions(t *testing.T) {
+	router := newTestRouter()
+
+	tests := []struct {
+		name       string
+		action     string
+		status     int
+		wantCode   int
+		wantStatus int
+	}{
+		{"cancel a queued task", "cancel", models.RepositoryStatusPending, http.StatusOK, models.RepositoryStatusCancelled},
+		{"cancel an analyzed task", "cancel", models.RepositoryStatusAnalyzed, http.StatusOK, models.RepositoryStatusCancelled},
+		{"cancel a completed task", "cancel", models.RepositoryStatusCompleted, http.StatusConflict, models.RepositoryStatusCompleted},
+		{"cancel a cancelled task", "cancel", models.RepositoryStatusCancelled, http.StatusConflict, models.RepositoryStatusCancelled},
+		{"retry a failed task", "retry", models.RepositoryStatusFailed, http.StatusAccepted, models.RepositoryStatusPending},
+		{"retry a cancelled task", "retry", models.RepositoryStatusCancelled, http.StatusAccepted, models.RepositoryStatusPending},
+		{"retry a completed task", "retry", models.RepositoryStatusCompleted, http.StatusConflict, models.RepositoryStatusCompleted},
+		{"retry a running task", "retry", models.RepositoryStatusCloned, http.StatusConflict, models.RepositoryStatusCloned},
+		{"regenerate a completed task", "regenerate", models.RepositoryStatusCompleted, http.StatusAccepted, models.RepositoryStatusPending},
+		{"regenerate a failed task", "regenerate", models.RepositoryStatusFailed, http.StatusAccepted, models.RepositoryStatusPending},
+		{"regenerate a running task", "regenerate", models.RepositoryStatusAnalyzed, http.StatusConflict, models.RepositoryStatusAnalyzed},
+	}
+	for idx, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task := newTestTask(t, fmt.Sprintf("https://github.com/example/actions-%d.git", idx), test.status)
+
+			code, response := post(router, fmt.Sprintf("/api/repo/%d/%s", task.ID, test.action))
+			if code != test.wantCode {
+				t.Fatalf("got %d %v, want %d", code, response, test.wantCode)
+			}
+			if status := taskStatus(t, task.ID); status != test.wantStatus {
+				t.Fatalf("task is %s, want %s", models.GetStatusString(status), models.GetStatusString(test.wantStatus))
+			}
+		})
+	}
+}
+
+func TestTaskActionsOfUnknownTask(t *testing.T) {
+	router := newTestRouter()
+	for _, action := range []string{"cancel", "retry", "regenerate"} {
+		if code, _ := post(router, "/api/repo/999999/"+action); code != http.StatusNotFound {
+			t.Errorf("%s of an unknown task: got %d, want 404", action, code)
+		}
+		if code, _ := post(router, "/api/repo/abc/"+action); code != http.StatusBadRequest {
+			t.Errorf("%s of an invalid id: got %d, want 400", action, code)
+		}
+	}
+}
+
+func TestRegenerateDropsContent(t *testing.T) {
+	const gitURL = "https://github.com/example/regenerate.git"
+	task := newTestTask(t, gitURL, models.RepositoryStatusCompleted)
+
+	repoDao := dao.NewRepositoryDAO()
+	repo, err := repoDao.CreateRepository(gitURL, "", "regenerate", t.TempDir(), models.RepositoryStatusCompleted, models.LanguageEnglish)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docDao := dao.NewDocumentDao()
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Where("repo_id = ?", repo.ID).Delete(&models.Document{})
+		database.GetDB().Unscoped().Delete(&models.Repository{}, repo.ID)
+	})
+	repoDao.UpdateRepositoryOverview(repo.ID, "overview")
+	repoDao.UpdateRepositoryCommit(repo.ID, "0123456789abcdef")
+	if err = docDao.CreateDocuments([]*models.Document{{RepoId: repo.ID, Index: 1, Title: "Overview", Content: "old"}}); err != nil {
+		t.Fatal(err)
+	}
+	repoConfig := config.GetRepositoryConfig()
+	index := []string{filepath.Join(repoConfig.Code, "regenerate.code"), filepath.Join(repoConfig.Vector, "regenerate.vector")}
+	for _, file := range index {
+		if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(file, []byte("index"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repoDao.UpdateRepositoryCodePath(repo.ID, "regenerate.code")
+	repoDao.UpdateRepositoryVectorPath(repo.ID, "regenerate.vector")
+
+	if code, response := post(newTestRouter(), fmt.Sprintf("/api/repo/%d/regenerate", task.ID)); code != http.StatusAccepted {
+		t.Fatalf("got %d %v, want 202", code, response)
+	}
+
+	repo, err = repoDao.GetRepositoryByID(repo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.Overview) > 0 || len(repo.CommitID) > 0 || len(repo.StructedCodePath) > 0 {
+		t.Fatalf("repository keeps overview %q, commit %q and code index %q", repo.Overview, repo.CommitID, repo.StructedCodePath)
+	}
+	for _, file := range index {
+		if _, err = os.Stat(file); !os.IsNotExist(err) {
+			t.Fatalf("code index %s is left on disk: %v", file, err)
+		}
+	}
+	docs, err := docDao.GetDocumentByRepoId(repo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 0 {
+		t.Fatalf("%d documents left, want none", len(docs))
+	}
+}
+
+func TestGetRepositoryUsage(t *testing.T) {
+	router := newTestRouter()
+	const gitURL = "https://github.com/example/usage.git"
+	task := newTestTask(t, gitURL, models.RepositoryStatusCompleted)
+
+	// the repository row only exists once the clone succeeded
+	path := fmt.Sprintf("/api/repo/%d/usage", task.ID)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("usage before the clone: got %d %s, want 409", recorder.Code, recorder.Body)
+	}
+
+	repo, err := dao.NewRepositoryDAO().CreateRepository(gitURL, "", "usage", t.TempDir(), models.RepositoryStatusCompleted, models.LanguageEnglish)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Where("repo_id = ?", repo.ID).Delete(&models.LLMUsage{})
+		database.GetDB().Unscoped().Delete(&models.Repository{}, repo.ID)
+	})
+
+	type report struct {
+		Total  dao.LLMUsageSummary    `json:"total"`
+		Stages []*dao.LLMUsageSummary `json:"stages"`
+		Models []*dao.LLMUsageSummary `json:"models"`
+	}
+	getUsage := func() *report {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("usage: got %d %s, want 200", recorder.Code, recorder.Body)
+		}
+		var response report
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return &response
+	}
+
+	// a repository without requests reports zeros
+	usage := getUsage()
+	if usage.Total.Requests != 0 || usage.Total.PromptTokens != 0 || len(usage.Stages) != 0 || len(usage.Models) != 0 {
+		t.Fatalf("got usage %+v of a repository without requests", usage)
+	}
+
+	usageDao := dao.NewLLMUsageDao()
+	for _, usage := range []*models.LLMUsage{
+		{TaskId: task.ID, RepoId: repo.ID, Stage: "document", ModelLLM: "default/gpt-4o", PromptTokens: 100, CompletionTokens: 10, Cost: 0.5},
+		{TaskId: task.ID, RepoId: repo.ID, Stage: "document", ModelLLM: "local/qwen3:4b", PromptTokens: 200, CompletionTokens: 20},
+		{TaskId: task.ID, RepoId: repo.ID, Stage: "overview", ModelLLM: "default/gpt-4o", PromptTokens: 300, CompletionTokens: 30, Cost: 1.5},
+		// another repository is left out
+		{TaskId: task.ID, RepoId: repo.ID + 1000, Stage: "overview", ModelLLM: "default/gpt-4o", PromptTokens: 999, CompletionTokens: 999, Cost: 9},
+	} {
+		if err = usageDao.CreateUsage(usage); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Where("repo_id = ?", repo.ID+1000).Delete(&models.LLMUsage{})
+	})
+
+	usage = getUsage()
+	if want := (dao.LLMUsageSummary{Requests: 3, PromptTokens: 600, CompletionTokens: 60, Cost: 2}); usage.Total != want {
+		t.Fatalf("got total %+v, want %+v", usage.Total, want)
+	}
+	wantStages := []dao.LLMUsageSummary{
+		{Stage: "document", Requests: 2, PromptTokens: 300, CompletionTokens: 30, Cost: 0.5},
+		{Stage: "overview", Requests: 1, PromptTokens: 300, CompletionTokens: 30, Cost: 1.5},
+	}
+	if len(usage.Stages) != len(wantStages) {
+		t.Fatalf("got %d stages, want %d", len(usage.Stages), len(wantStages))
+	}
+	for i, want := range wantStages {
+		if *usage.Stages[i] != want {
+			t.Fatalf("got stage %+v, want %+v", usage.Stages[i], want)
+		}
+	}
+	wantModels := []dao.LLMUsageSummary{
+		{ModelLLM: "default/gpt-4o", Requests: 2, PromptTokens: 400, CompletionTokens: 40, Cost: 2},
+		{ModelLLM: "local/qwen3:4b", Requests: 1, PromptTokens: 200, CompletionTokens: 20},
+	}
+	if len(usage.Models) != len(wantModels) {
+		t.Fatalf("got %d models, want %d", len(usage.Models), len(wantModels))
+	}
+	for i, want := range wantModels {
+		if *usage.Models[i] != want {
+			t.Fatalf("got model %+v, want %+v", usage.Models[i], want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/services"
+	"github.com/o0olele/opendeepwiki-go/internal/utils"
+)
+
+const (
+	maxUploadSize    = 512 << 20 // size of an uploaded archive
+	maxExtractedSize = 2 << 30   // size of the extracted files of an archive
+)
+
+// ImportRepository Document a directory of the server, it must be under one of the configured import roots.
+func (h *RepositoryHandler) ImportRepository(c *gin.Context) {
+	var req struct {
+		Path     string `json:"path" binding:"required"`
+		Language string `json:"language" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if !isValidLanguage(req.Language) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid language",
+		})
+		return
+	}
+
+	path, err := resolveImportPath(req.Path)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid path: " + err.Error(),
+		})
+		return
+	}
+	if !isImportAllowed(path) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Path is not under an import root",
+		})
+		return
+	}
+
+	// an imported directory is documented once, failed imports are queued again
+	if existing, err := h.taskDao.GetRepositoryTaskByGitURL(path, ""); err == nil {
+		if !isStoppedStatus(existing.Status) {
+			c.JSON(http.StatusOK, gin.H{
+				"existing": true,
+				"message":  "Repository already being processed",
+				"task_id":  existing.ID,
+				"status":   existing.StatusString(),
+			})
+			return
+		}
+		if err = services.RequeueTask(existing.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update task: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"existing": true,
+			"message":  "Repository resubmitted for processing",
+			"task_id":  existing.ID,
+		})
+		return
+	}
+
+	h.createLocalTask(c, path, req.Language)
+}
+
+// UploadRepository Document an uploaded .zip, .tar.gz or .tgz archive, it is extracted under the repository directory.
+func (h *RepositoryHandler) UploadRepository(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+	if !utils.IsArchive(file.Filename) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Only .zip, .tar.gz and .tgz archives are supported",
+		})
+		return
+	}
+
+	language := c.PostForm("language")
+	if !isValidLanguage(language) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid language",
+		})
+		return
+	}
+
+	// every upload gets its own directory, the archive name is kept as the repository name
+	name := filepath.Base(file.Filename)
+	for _, ext := range []string{".zip", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			name = name[:len(name)-len(ext)]
+			break
+		}
+	}
+	// "..zip" would extract into the shared uploads directory
+	if len(name) == 0 || name == "." || name == ".." {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid archive name",
+		})
+		return
+	}
+	uploadDir := filepath.Join(config.GetRepositoryConfig().Dir, "uploads", uuid.New().String())
+	dest, err := filepath.Abs(filepath.Join(uploadDir, name))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to save archive: " + err.Error(),
+		})
+		return
+	}
+
+	archive := filepath.Join(uploadDir, "upload.archive")
+	if err = c.SaveUploadedFile(file, archive); err != nil {
+		os.RemoveAll(uploadDir)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to save archive: " + err.Error(),
+		})
+		return
+	}
+
+	err = utils.ExtractArchive(archive, file.Filename, dest, maxExtractedSize)
+	os.Remove(archive)
+	if err != nil {
+		os.RemoveAll(uploadDir)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to extract archive: " + err.Error(),
+		})
+		return
+	}
+
+	h.createLocalTask(c, utils.ArchiveRoot(dest), language)
+}
+
+func (h *RepositoryHandler) createLocalTask(c *gin.Context, path, language string) {
+	// tasks are scheduled fairly between the client addresses
+	task, err := h.taskDao.CreateLocalRepositoryTask(path, language, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create task: " + err.Error(),
+		})
+		return
+	}
+	services.TaskQueued(task.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Repository submitted for processing",
+		"task_id": task.ID,
+	})
+}
+
+// resolveImportPath returns the absolute path of a directory with symlinks resolved.
+func resolveImportPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", os.ErrInvalid
+	}
+	return path, nil
+}
+
+// isImportAllowed reports whether the path is under one of the configured import roots.
+func isImportAllowed(path string) bool {
+	for _, root := range config.GetRepositoryConfig().ImportRoots {
+		root, err := resolveImportPath(root)
+		if err != nil {
+			continue
+		}
+		if path == root || strings.HasPrefix(path, root+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,7 +3,17 @@ package repository
 type Repository struct {
 	Name        string `json:"name"`
 	URL         string `json:"url"`
+	Branch      string `json:"branch"`
 	Description string `json:"description"`
 	RepoId      uint   `json:"id"`
 	Status      string `json:"status"`
 }
+
+// Credential Credential of a private repository, token for https urls or private_key for ssh urls.
+type Credential struct {
+	Type       string `json:"type" binding:"required"` // token or ssh
+	Username   string `json:"username"`
+	Token      string `json:"token"`
+	PrivateKey string `json:"private_key"`
+	Passphrase string `json:"passphrase"`
+}
@@ -0,0 +1,191 @@
+package research
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/services"
+)
+
+const (
+	defaultRounds = 3
+	maxRounds     = 10
+)
+
+// ResearchHandler Deep research handler.
+type ResearchHandler struct {
+	researchDao *dao.ResearchDAO
+	repoDao     *dao.RepositoryDAO
+	taskQueue   *services.TaskQueue
+}
+
+// NewResearchHandler Create a new research handler, new research tasks wake the consumer of taskQueue.
+func NewResearchHandler(taskQueue *services.TaskQueue) *ResearchHandler {
+	return &ResearchHandler{
+		researchDao: dao.NewResearchDAO(),
+		repoDao:     dao.NewRepositoryDAO(),
+		taskQueue:   taskQueue,
+	}
+}
+
+// CreateResearch Submit a research question, it is processed in the background.
+func (h *ResearchHandler) CreateResearch(c *gin.Context) {
+	var req struct {
+		RepoId   uint   `json:"id" binding:"required"`
+		Question string `json:"question" binding:"required"`
+		Rounds   int    `json:"rounds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if req.Rounds <= 0 {
+		req.Rounds = defaultRounds
+	}
+	if req.Rounds > maxRounds {
+		req.Rounds = maxRounds
+	}
+
+	repo, err := h.repoDao.GetRepositoryByID(req.RepoId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Repository not found",
+		})
+		return
+	}
+
+	if repo.Status != models.RepositoryStatusCompleted {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Repository is not ready yet",
+		})
+		return
+	}
+
+	task, err := h.researchDao.CreateResearchTask(repo.ID, req.Question, req.Rounds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create research: " + err.Error(),
+		})
+		return
+	}
+	h.taskQueue.ResearchQueued()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":     "Research submitted for processing",
+		"research_id": task.ID,
+	})
+}
+
+// GetResearch Get the status, iterations and conclusion of a research task.
+func (h *ResearchHandler) GetResearch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid research id"})
+		return
+	}
+
+	task, err := h.researchDao.GetResearchTaskByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "research not found"})
+		return
+	}
+
+	iterations, err := h.researchDao.ListResearchIterations(task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var result = &Research{
+		ID:           task.ID,
+		RepositoryID: task.RepositoryID,
+		Question:     task.Question,
+		Rounds:       task.Rounds,
+		Status:       task.StatusString(),
+		Conclusion:   task.Conclusion,
+		Errors:       task.Errors,
+		Iterations:   make([]*Iteration, 0, len(iterations)),
+	}
+	for _, iteration := range iterations {
+		result.Iterations = append(result.Iterations, &Iteration{
+			Index:   iteration.Index,
+			Content: iteration.Content,
+		})
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetIteration Get a single iteration of a research task.
+func (h *ResearchHandler) GetIteration(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid research id"})
+		return
+	}
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid index"})
+		return
+	}
+
+	iteration, err := h.researchDao.GetResearchIteration(uint(id), index)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "iteration not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &Iteration{
+		Index:   iteration.Index,
+		Content: iteration.Content,
+	})
+}
+
+// CancelResearch Cancel a pending or running research task.
+func (h *ResearchHandler) CancelResearch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid research id"})
+		return
+	}
+
+	task, err := h.researchDao.GetResearchTaskByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "research not found"})
+		return
+	}
+	if task.Status != models.ResearchStatusPending && task.Status != models.ResearchStatusRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "research is not pending or running"})
+		return
+	}
+
+	// a running research is marked cancelled by the queue once it stopped
+	if !services.CancelResearch(task.ID) {
+		if err = h.researchDao.UpdateResearchTaskStatus(task.ID, models.ResearchStatusCancelled); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Research cancelled",
+		"research_id": task.ID,
+	})
+}
+
+// RegisterRoutes Register research routes.
+func RegisterRoutes(router *gin.RouterGroup, taskQueue *services.TaskQueue) {
+	handler := NewResearchHandler(taskQueue)
+
+	group := router.Group("/research")
+	group.POST("/create", handler.CreateResearch)
+	group.GET("/:id", handler.GetResearch)
+	group.GET("/:id/iterations/:index", handler.GetIteration)
+	group.POST("/:id/cancel", handler.CancelResearch)
+}
@@ -0,0 +1,17 @@
+package research
+
+type Research struct {
+	ID           uint         `json:"id"`
+	RepositoryID uint         `json:"repository_id"`
+	Question     string       `json:"question"`
+	Rounds       int          `json:"rounds"`
+	Status       string       `json:"status"`
+	Conclusion   string       `json:"conclusion"`
+	Errors       string       `json:"errors,omitempty"`
+	Iterations   []*Iteration `json:"iterations"`
+}
+
+type Iteration struct {
+	Index   int    `json:"index"`
+	Content string `json:"content"`
+}
@@ -2,12 +2,17 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/o0olele/opendeepwiki-go/internal/api/admin"
+	"github.com/o0olele/opendeepwiki-go/internal/api/chat"
 	"github.com/o0olele/opendeepwiki-go/internal/api/document"
+	"github.com/o0olele/opendeepwiki-go/internal/api/hooks"
 	"github.com/o0olele/opendeepwiki-go/internal/api/repository"
+	"github.com/o0olele/opendeepwiki-go/internal/api/research"
+	"github.com/o0olele/opendeepwiki-go/internal/services"
 )
 
-// RegisterRoutes registers all API routes
-func RegisterRoutes(router *gin.Engine) {
+// RegisterRoutes registers all API routes, the research routes queue their tasks on taskQueue
+func RegisterRoutes(router *gin.Engine, taskQueue *services.TaskQueue) {
 	// API group
 	apiGroup := router.Group("/api")
 
@@ -16,4 +21,8 @@ func RegisterRoutes(router *gin.Engine) {
 
 	// Register other routes here
 	document.RegisterRoutes(apiGroup)
+	chat.RegisterRoutes(apiGroup)
+	research.RegisterRoutes(apiGroup, taskQueue)
+	hooks.RegisterRoutes(apiGroup)
+	admin.RegisterRoutes(apiGroup)
 }
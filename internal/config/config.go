@@ -4,18 +4,81 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // LLMConfig 语言模型配置
 type LLMConfig struct {
-	ProviderType string  `yaml:"provider_type"` // openai, google, deepseek, ollama, llamacpp, vllm
+	ProviderType string  `yaml:"provider_type"` // openai, google, deepseek, ollama, llamacpp, vllm, anthropic, azure
 	APIKey       string  `yaml:"api_key"`
 	Model        string  `yaml:"model"`
 	BaseURL      string  `yaml:"base_url"`
+	APIVersion   string  `yaml:"api_version"` // azure only
 	MaxTokens    int     `yaml:"max_tokens"`
 	Temperature  float64 `yaml:"temperature"`
+	// tokens of prompt and answer the model accepts, 0 looks the model up or assumes 32768
+	ContextWindow int `yaml:"context_window"`
+	Concurrency   int `yaml:"concurrency"` // documents generated concurrently for one repository
+	// named providers that stages can be routed to
+	Providers map[string]LLMProviderConfig `yaml:"providers,omitempty"`
+	// stage (readme, catalogue, overview, think, document, history, chat, research) to provider name,
+	// stages not listed use the provider above
+	Stages map[string]string `yaml:"stages,omitempty"`
+	// providers tried in order when a provider keeps failing, "default" is the provider above
+	Fallbacks []string        `yaml:"fallbacks,omitempty"`
+	Retry     LLMRetryConfig  `yaml:"retry,omitempty"`
+	Replay    LLMReplayConfig `yaml:"replay,omitempty"`
+	Cache     LLMCacheConfig  `yaml:"cache,omitempty"`
+	// model (e.g. gpt-4o-mini, or default/gpt-4o-mini for one provider) to price per million tokens
+	Pricing map[string]LLMPrice `yaml:"pricing,omitempty"`
+	Budget  LLMBudgetConfig     `yaml:"budget,omitempty"`
+}
+
+// LLMPrice price per million tokens
+type LLMPrice struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+// LLMBudgetConfig aborts a task run that spends more, 0 means unlimited
+type LLMBudgetConfig struct {
+	MaxTokens int     `yaml:"max_tokens"`
+	MaxCost   float64 `yaml:"max_cost"` // in the currency of the pricing
+}
+
+// LLMCacheConfig caches the responses of the wiki generation per repository
+type LLMCacheConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl"` // default 168h
+}
+
+// LLMReplayConfig records the llm exchanges to fixtures or replays them without a model,
+// can be set by OPENDEEPWIKI_LLM_REPLAY and OPENDEEPWIKI_LLM_FIXTURES
+type LLMReplayConfig struct {
+	Mode string `yaml:"mode"` // record or replay, empty calls the providers
+	Dir  string `yaml:"dir"`  // fixture directory, default ./testdata/llm
+}
+
+// LLMRetryConfig retries of rate limits, server errors and timeouts
+type LLMRetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts"`    // attempts per provider, default 3
+	InitialBackoff time.Duration `yaml:"initial_backoff"` // doubled on every retry, default 2s
+	MaxBackoff     time.Duration `yaml:"max_backoff"`     // a longer Retry-After fails over, default 1m
+}
+
+// LLMProviderConfig a named provider of the llm stage routing
+type LLMProviderConfig struct {
+	ProviderType  string  `yaml:"provider_type"`
+	APIKey        string  `yaml:"api_key"`
+	Model         string  `yaml:"model"`
+	BaseURL       string  `yaml:"base_url"`
+	APIVersion    string  `yaml:"api_version"`
+	MaxTokens     int     `yaml:"max_tokens"`
+	Temperature   float64 `yaml:"temperature"`
+	ContextWindow int     `yaml:"context_window"`
 }
 
 type EmbeddingConfig struct {
@@ -26,16 +89,33 @@ type EmbeddingConfig struct {
 	BaseURL      string `yaml:"base_url"`
 }
 
+// TaskConfig task queue configuration
+type TaskConfig struct {
+	Workers     int  `yaml:"workers"`       // number of tasks processed concurrently
+	MaxPerOwner int  `yaml:"max_per_owner"` // maximum running tasks per client address, 0 means unlimited
+	Changelog   bool `yaml:"changelog"`     // summarise the commit history into changelog pages after each run
+}
+
+// SecurityConfig secrets used to protect stored data
+type SecurityConfig struct {
+	SecretKey     string `yaml:"secret_key"`     // encrypts the repository credentials, can be set by OPENDEEPWIKI_SECRET_KEY
+	KnownHosts    string `yaml:"known_hosts"`    // host keys of the ssh servers, default ~/.ssh/known_hosts or SSH_KNOWN_HOSTS
+	WebhookSecret string `yaml:"webhook_secret"` // verifies the push webhooks, empty disables them, can be set by OPENDEEPWIKI_WEBHOOK_SECRET
+	AdminToken    string `yaml:"admin_token"`    // bearer token of the /api/admin endpoints and of publishing, can be set by OPENDEEPWIKI_ADMIN_TOKEN
+}
+
 type RepositoryConfig struct {
-	Dir    string `yaml:"dir"`
-	Code   string `yaml:"code"`
-	Vector string `yaml:"vector"`
+	Dir         string   `yaml:"dir"`
+	Code        string   `yaml:"code"`
+	Vector      string   `yaml:"vector"`
+	ImportRoots []string `yaml:"import_roots"` // server directories that can be imported, empty disables local imports
 }
 
 // Config holds all configuration for the application
 type Config struct {
 	Server struct {
-		Address string `yaml:"address"`
+		Address        string   `yaml:"address"`
+		TrustedProxies []string `yaml:"trusted_proxies"` // proxies whose X-Forwarded-For is used as the client address
 	} `yaml:"server"`
 	Repository RepositoryConfig `yaml:"repository"`
 	Database   struct {
@@ -43,10 +123,15 @@ type Config struct {
 	} `yaml:"database"`
 	LLM       LLMConfig       `yaml:"llm"`
 	Embedding EmbeddingConfig `yaml:"embedding"`
+	Task      TaskConfig      `yaml:"task"`
+	Security  SecurityConfig  `yaml:"security"`
 }
 
 var cfg Config
 
+// llmMu guards cfg.LLM, the llm settings can be switched while the server runs.
+var llmMu sync.RWMutex
+
 // LoadConfig loads configuration from a YAML file
 func LoadConfig() *Config {
 	LoadTemplates()
@@ -69,6 +154,12 @@ func LoadConfig() *Config {
 	config.LLM.Model = "gpt-4"
 	config.LLM.MaxTokens = 8192
 	config.LLM.Temperature = 0.5
+	config.LLM.Concurrency = 1
+
+	// 默认任务队列配置
+	config.Task.Workers = 2
+	// the round-robin of the scheduler keeps the owners fair, a cap would leave workers idle
+	config.Task.MaxPerOwner = 0
 
 	// Try to read the config file
 	data, err := os.ReadFile(configPath)
@@ -87,6 +178,22 @@ func LoadConfig() *Config {
 		}
 	}
 
+	if envKey := os.Getenv("OPENDEEPWIKI_SECRET_KEY"); envKey != "" {
+		config.Security.SecretKey = envKey
+	}
+	if envSecret := os.Getenv("OPENDEEPWIKI_WEBHOOK_SECRET"); envSecret != "" {
+		config.Security.WebhookSecret = envSecret
+	}
+	if envToken := os.Getenv("OPENDEEPWIKI_ADMIN_TOKEN"); envToken != "" {
+		config.Security.AdminToken = envToken
+	}
+	if envMode := os.Getenv("OPENDEEPWIKI_LLM_REPLAY"); envMode != "" {
+		config.LLM.Replay.Mode = envMode
+	}
+	if envDir := os.Getenv("OPENDEEPWIKI_LLM_FIXTURES"); envDir != "" {
+		config.LLM.Replay.Dir = envDir
+	}
+
 	// Create repository directory if it doesn't exist
 	if _, err := os.Stat(config.Repository.Dir); os.IsNotExist(err) {
 		err := os.MkdirAll(config.Repository.Dir, 0755)
@@ -127,8 +234,19 @@ func createDefaultConfig(path string, config *Config) {
 	}
 }
 
+// GetLLMConfig returns a copy of the current llm configuration.
 func GetLLMConfig() *LLMConfig {
-	return &cfg.LLM
+	llmMu.RLock()
+	defer llmMu.RUnlock()
+	llm := cfg.LLM
+	return &llm
+}
+
+// SetLLMConfig replaces the llm configuration, providers created afterwards use it.
+func SetLLMConfig(llm LLMConfig) {
+	llmMu.Lock()
+	defer llmMu.Unlock()
+	cfg.LLM = llm
 }
 
 func GetEmbeddingConfig() *EmbeddingConfig {
@@ -138,3 +256,11 @@ func GetEmbeddingConfig() *EmbeddingConfig {
 func GetRepositoryConfig() *RepositoryConfig {
 	return &cfg.Repository
 }
+
+func GetTaskConfig() *TaskConfig {
+	return &cfg.Task
+}
+
+func GetSecurityConfig() *SecurityConfig {
+	return &cfg.Security
+}
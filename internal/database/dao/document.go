@@ -39,3 +39,29 @@ func (d *DocumentDao) GetDocumentByRepoIdAndIndex(repoId uint, index int) (*mode
 	}
 	return &document, nil
 }
+
+func (d *DocumentDao) UpdateDocument(document *models.Document) error {
+	return d.db.Save(document).Error
+}
+
+func (d *DocumentDao) DeleteDocumentsByRepoIdAndIndexes(repoId uint, indexes []int) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+	return d.db.Unscoped().Where("repo_id = ? AND `index` IN ?", repoId, indexes).Delete(&models.Document{}).Error
+}
+
+func (d *DocumentDao) DeleteDocumentsByRepoId(repoId uint) error {
+	return d.db.Unscoped().Where("repo_id = ?", repoId).Delete(&models.Document{}).Error
+}
+
+// ReplaceDocuments replaces the documents of the repository at once, the old ones are kept when the save fails.
+func (d *DocumentDao) ReplaceDocuments(repoId uint, documents []*models.Document) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("repo_id = ?", repoId).Delete(&models.Document{}).Error
+		if err != nil || len(documents) == 0 {
+			return err
+		}
+		return tx.Create(documents).Error
+	})
+}
@@ -0,0 +1,39 @@
+package dao
+
+import (
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"gorm.io/gorm"
+)
+
+type DocumentCheckpointDao struct {
+	db *gorm.DB
+}
+
+func NewDocumentCheckpointDao() *DocumentCheckpointDao {
+	return &DocumentCheckpointDao{db: database.GetDB()}
+}
+
+// SaveCheckpoint creates the checkpoint, or replaces the checkpoint of the same repository and key.
+func (d *DocumentCheckpointDao) SaveCheckpoint(checkpoint *models.DocumentCheckpoint) error {
+	var existing models.DocumentCheckpoint
+	result := d.db.Where("repo_id = ? AND `key` = ?", checkpoint.RepoId, checkpoint.Key).First(&existing)
+	if result.Error == nil {
+		checkpoint.ID = existing.ID
+		checkpoint.CreatedAt = existing.CreatedAt
+	}
+	return d.db.Save(checkpoint).Error
+}
+
+func (d *DocumentCheckpointDao) GetCheckpointsByRepoId(repoId uint) ([]*models.DocumentCheckpoint, error) {
+	var checkpoints []*models.DocumentCheckpoint
+	result := d.db.Where("repo_id = ?", repoId).Find(&checkpoints)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return checkpoints, nil
+}
+
+func (d *DocumentCheckpointDao) DeleteCheckpointsByRepoId(repoId uint) error {
+	return d.db.Unscoped().Where("repo_id = ?", repoId).Delete(&models.DocumentCheckpoint{}).Error
+}
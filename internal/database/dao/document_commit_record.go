@@ -0,0 +1,41 @@
+package dao
+
+import (
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"gorm.io/gorm"
+)
+
+type DocumentCommitRecordDao struct {
+	db *gorm.DB
+}
+
+func NewDocumentCommitRecordDao() *DocumentCommitRecordDao {
+	return &DocumentCommitRecordDao{db: database.GetDB()}
+}
+
+// SaveRecord creates the record, or replaces the record of the same repository, type and title.
+func (d *DocumentCommitRecordDao) SaveRecord(record *models.DocumentCommitRecord) error {
+	var existing models.DocumentCommitRecord
+	result := d.db.Where("repo_id = ? AND type = ? AND title = ?", record.RepoId, record.Type, record.Title).First(&existing)
+	if result.Error == nil {
+		record.ID = existing.ID
+		record.CreatedAt = existing.CreatedAt
+	}
+	return d.db.Save(record).Error
+}
+
+// CreateRecord adds a record, publish records are kept for every publish.
+func (d *DocumentCommitRecordDao) CreateRecord(record *models.DocumentCommitRecord) error {
+	return d.db.Create(record).Error
+}
+
+// GetRecordsByRepoId lists the records of a repository with the given type, newest first.
+func (d *DocumentCommitRecordDao) GetRecordsByRepoId(repoId uint, recordType int) ([]*models.DocumentCommitRecord, error) {
+	var records []*models.DocumentCommitRecord
+	result := d.db.Where("repo_id = ? AND type = ?", repoId, recordType).Order("date DESC").Find(&records)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return records, nil
+}
@@ -0,0 +1,34 @@
+package dao
+
+import (
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"gorm.io/gorm"
+)
+
+type GitCredentialDAO struct {
+	db *gorm.DB
+}
+
+func NewGitCredentialDAO() *GitCredentialDAO {
+	return &GitCredentialDAO{db: database.GetDB()}
+}
+
+// CreateCredential saves the credential, the secrets must already be encrypted.
+func (dao *GitCredentialDAO) CreateCredential(credential *models.GitCredential) error {
+	return dao.db.Create(credential).Error
+}
+
+// DeleteCredential removes the credential and its encrypted secrets.
+func (dao *GitCredentialDAO) DeleteCredential(id uint) error {
+	return dao.db.Unscoped().Delete(&models.GitCredential{}, id).Error
+}
+
+func (dao *GitCredentialDAO) GetCredentialByID(id uint) (*models.GitCredential, error) {
+	var credential = new(models.GitCredential)
+	result := dao.db.First(credential, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return credential, nil
+}
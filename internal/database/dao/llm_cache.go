@@ -0,0 +1,54 @@
+package dao
+
+import (
+	"time"
+
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"gorm.io/gorm"
+)
+
+type LLMCacheDao struct {
+	db *gorm.DB
+}
+
+func NewLLMCacheDao() *LLMCacheDao {
+	return &LLMCacheDao{db: database.GetDB()}
+}
+
+// GetCache returns the entry of the repository and key if it has not expired.
+// Misses are frequent, Find does not log them as errors like First.
+func (d *LLMCacheDao) GetCache(repoId uint, key string) (*models.LLMCache, error) {
+	var entry = new(models.LLMCache)
+	result := d.db.Where("repo_id = ? AND `key` = ? AND expires_at > ?", repoId, key, time.Now()).Limit(1).Find(entry)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return entry, nil
+}
+
+// SaveCache creates the entry, or replaces the entry of the same repository and key.
+func (d *LLMCacheDao) SaveCache(entry *models.LLMCache) error {
+	var existing models.LLMCache
+	result := d.db.Unscoped().Where("repo_id = ? AND `key` = ?", entry.RepoId, entry.Key).Limit(1).Find(&existing)
+	if result.Error == nil && result.RowsAffected > 0 {
+		entry.ID = existing.ID
+		entry.CreatedAt = existing.CreatedAt
+	}
+	return d.db.Save(entry).Error
+}
+
+// DeleteCacheByRepoId removes the cached responses of a repository, it returns the number of entries.
+func (d *LLMCacheDao) DeleteCacheByRepoId(repoId uint) (int64, error) {
+	result := d.db.Unscoped().Where("repo_id = ?", repoId).Delete(&models.LLMCache{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteExpiredCache removes the expired entries of all repositories.
+func (d *LLMCacheDao) DeleteExpiredCache() (int64, error) {
+	result := d.db.Unscoped().Where("expires_at <= ?", time.Now()).Delete(&models.LLMCache{})
+	return result.RowsAffected, result.Error
+}
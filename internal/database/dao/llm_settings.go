@@ -0,0 +1,56 @@
+package dao
+
+import (
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"gorm.io/gorm"
+)
+
+type LLMSettingsDAO struct {
+	db *gorm.DB
+}
+
+func NewLLMSettingsDAO() *LLMSettingsDAO {
+	return &LLMSettingsDAO{db: database.GetDB()}
+}
+
+func (dao *LLMSettingsDAO) ListSettings() ([]*models.LLMSettings, error) {
+	var settings []*models.LLMSettings
+	result := dao.db.Order("id").Find(&settings)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return settings, nil
+}
+
+func (dao *LLMSettingsDAO) GetSettingsByID(id uint) (*models.LLMSettings, error) {
+	var settings = new(models.LLMSettings)
+	result := dao.db.First(settings, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return settings, nil
+}
+
+func (dao *LLMSettingsDAO) CreateSettings(settings *models.LLMSettings) error {
+	return dao.db.Create(settings).Error
+}
+
+func (dao *LLMSettingsDAO) UpdateSettings(settings *models.LLMSettings) error {
+	return dao.db.Save(settings).Error
+}
+
+func (dao *LLMSettingsDAO) DeleteSettings(id uint) error {
+	return dao.db.Delete(&models.LLMSettings{}, id).Error
+}
+
+// SetDefaultSettings makes the settings the only default ones.
+func (dao *LLMSettingsDAO) SetDefaultSettings(id uint) error {
+	return dao.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.LLMSettings{}).Where("is_default = ? AND id <> ?", true, id).Update("is_default", false).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.LLMSettings{}).Where("id = ?", id).Update("is_default", true).Error
+	})
+}
@@ -0,0 +1,47 @@
+package dao
+
+import (
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// LLMUsageSummary sums the usage of a group of requests.
+type LLMUsageSummary struct {
+	Stage            string  `json:"stage,omitempty"`
+	ModelLLM         string  `json:"model,omitempty"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+type LLMUsageDao struct {
+	db *gorm.DB
+}
+
+func NewLLMUsageDao() *LLMUsageDao {
+	return &LLMUsageDao{db: database.GetDB()}
+}
+
+func (d *LLMUsageDao) CreateUsage(usage *models.LLMUsage) error {
+	return d.db.Create(usage).Error
+}
+
+// SummarizeUsage sums the usage of a repository, grouped by the given columns (stage, model_llm).
+func (d *LLMUsageDao) SummarizeUsage(repoId uint, groups ...string) ([]*LLMUsageSummary, error) {
+	columns := "COUNT(*) AS requests, COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, " +
+		"COALESCE(SUM(completion_tokens), 0) AS completion_tokens, COALESCE(SUM(cost), 0) AS cost"
+	query := d.db.Model(&models.LLMUsage{}).Where("repo_id = ?", repoId)
+	for _, group := range groups {
+		columns = group + ", " + columns
+		query = query.Group(group).Order(group)
+	}
+
+	var summaries []*LLMUsageSummary
+	result := query.Select(columns).Scan(&summaries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return summaries, nil
+}
@@ -1,6 +1,8 @@
 package dao
 
 import (
+	"time"
+
 	"github.com/o0olele/opendeepwiki-go/internal/database"
 	"github.com/o0olele/opendeepwiki-go/internal/database/models"
 	"go.uber.org/zap"
@@ -20,9 +22,10 @@ func NewRepositoryDAO() *RepositoryDAO {
 }
 
 // CreateRepository Create a new repository record.
-func (dao *RepositoryDAO) CreateRepository(gitURL, name, path string, status int, language string) (*models.Repository, error) {
+func (dao *RepositoryDAO) CreateRepository(gitURL, branch, name, path string, status int, language string) (*models.Repository, error) {
 	repo := &models.Repository{
 		GitURL:   gitURL,
+		Branch:   branch,
 		Name:     name,
 		Path:     path,
 		Status:   status,
@@ -59,6 +62,17 @@ func (dao *RepositoryDAO) GetRepositoryByGitURL(gitURL string) (*models.Reposito
 	return repo, nil
 }
 
+// GetRepositoryByGitURLAndBranch Get a repository by Git URL and the checked out ref.
+func (dao *RepositoryDAO) GetRepositoryByGitURLAndBranch(gitURL, branch string) (*models.Repository, error) {
+	var repo = new(models.Repository)
+	result := dao.db.Where("git_url = ? AND branch = ?", gitURL, branch).First(repo)
+	if result.Error != nil {
+		zap.L().Error("Failed to get repository by gitURL and branch: %v", zap.Error(result.Error))
+		return nil, result.Error
+	}
+	return repo, nil
+}
+
 // ListRepositories List all repositories.
 func (dao *RepositoryDAO) ListRepositories(limit, offset int) ([]models.Repository, error) {
 	var repos []models.Repository
@@ -98,12 +112,12 @@ func (dao *RepositoryDAO) UpdateRepositoryReadme(id uint, readme string) error {
 }
 
 func (dao *RepositoryDAO) UpdateRepositoryCatalogue(id uint, catalogue string) error {
-	result := dao.db.Model(&models.Repository{}).Where("id =?", id).Update("structured_catalogue", catalogue)
+	result := dao.db.Model(&models.Repository{}).Where("id =?", id).Update("structed_catalogue", catalogue)
 	return result.Error
 }
 
 func (dao *RepositoryDAO) UpdateRepositoryCodePath(id uint, codePath string) error {
-	result := dao.db.Model(&models.Repository{}).Where("id =?", id).Update("structured_code_path", codePath)
+	result := dao.db.Model(&models.Repository{}).Where("id =?", id).Update("structed_code_path", codePath)
 	return result.Error
 }
 
@@ -113,6 +127,55 @@ func (dao *RepositoryDAO) UpdateRepositoryOverview(id uint, overview string) err
 }
 
 func (dao *RepositoryDAO) UpdateRepositoryVectorPath(id uint, vectorPath string) error {
-	result := dao.db.Model(&models.Repository{}).Where("id =?", id).Update("structured_vector_path", vectorPath)
+	result := dao.db.Model(&models.Repository{}).Where("id =?", id).Update("structed_vector_path", vectorPath)
+	return result.Error
+}
+
+func (dao *RepositoryDAO) UpdateRepositoryCommit(id uint, commitID string) error {
+	result := dao.db.Model(&models.Repository{}).Where("id =?", id).Update("commit_id", commitID)
+	return result.Error
+}
+
+func (dao *RepositoryDAO) UpdateRepositoryDocumentCatalogue(id uint, catalogue string) error {
+	result := dao.db.Model(&models.Repository{}).Where("id =?", id).Update("document_catalogue", catalogue)
+	return result.Error
+}
+
+// ListScheduledRepositories List repositories with a refresh schedule.
+func (dao *RepositoryDAO) ListScheduledRepositories() ([]*models.Repository, error) {
+	var repos []*models.Repository
+	result := dao.db.Where("refresh_schedule <> ''").Find(&repos)
+	if result.Error != nil {
+		zap.L().Error("Failed to list scheduled repositories: %v", zap.Error(result.Error))
+		return nil, result.Error
+	}
+	return repos, nil
+}
+
+// UpdateRepositorySchedule sets the refresh schedule, the next check is counted from now.
+func (dao *RepositoryDAO) UpdateRepositorySchedule(id uint, schedule string) error {
+	result := dao.db.Model(&models.Repository{}).Where("id =?", id).Updates(map[string]interface{}{
+		"refresh_schedule": schedule,
+		"refreshed_at":     time.Now(),
+	})
+	return result.Error
+}
+
+func (dao *RepositoryDAO) UpdateRepositoryRefreshedAt(id uint, refreshedAt time.Time) error {
+	result := dao.db.Model(&models.Repository{}).Where("id =?", id).Update("refreshed_at", refreshedAt)
+	return result.Error
+}
+
+// ResetRepositoryContent clears the generated content so the next analysis starts from scratch.
+func (dao *RepositoryDAO) ResetRepositoryContent(id uint) error {
+	result := dao.db.Model(&models.Repository{}).Where("id =?", id).Updates(map[string]interface{}{
+		"readme":               "",
+		"structed_catalogue":   "",
+		"overview":             "",
+		"structed_code_path":   "",
+		"structed_vector_path": "",
+		"commit_id":            "",
+		"document_catalogue":   "",
+	})
 	return result.Error
 }
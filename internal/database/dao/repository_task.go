@@ -1,6 +1,8 @@
 package dao
 
 import (
+	"strings"
+
 	"github.com/o0olele/opendeepwiki-go/internal/database"
 	"github.com/o0olele/opendeepwiki-go/internal/database/models"
 	"go.uber.org/zap"
@@ -20,10 +22,33 @@ func NewRepositoryTaskDAO() *RepositoryTaskDAO {
 }
 
 // CreateRepositoryTask Create a new repository task record.
-func (dao *RepositoryTaskDAO) CreateRepositoryTask(gitURL string, language string) (*models.RepositoryTask, error) {
+func (dao *RepositoryTaskDAO) CreateRepositoryTask(gitURL string, ref string, language string, owner string, credentialID uint) (*models.RepositoryTask, error) {
+	task := &models.RepositoryTask{
+		GitURL:       gitURL,
+		Ref:          ref,
+		Language:     language,
+		Owner:        owner,
+		CredentialID: credentialID,
+		Source:       models.RepositorySourceGit,
+		Status:       models.RepositoryStatusPending,
+	}
+
+	result := dao.db.Create(task)
+	if result.Error != nil {
+		zap.L().Error("Failed to create repository task: %v", zap.Error(result.Error))
+		return nil, result.Error
+	}
+
+	return task, nil
+}
+
+// CreateLocalRepositoryTask Create a task documenting a local directory.
+func (dao *RepositoryTaskDAO) CreateLocalRepositoryTask(path string, language string, owner string) (*models.RepositoryTask, error) {
 	task := &models.RepositoryTask{
-		GitURL:   gitURL,
+		GitURL:   path,
 		Language: language,
+		Owner:    owner,
+		Source:   models.RepositorySourceLocal,
 		Status:   models.RepositoryStatusPending,
 	}
 
@@ -47,10 +72,10 @@ func (dao *RepositoryTaskDAO) GetRepositoryTaskByID(id uint) (*models.Repository
 	return task, nil
 }
 
-// GetRepositoryTaskByGitURL Get a repository task by Git URL.
-func (dao *RepositoryTaskDAO) GetRepositoryTaskByGitURL(gitURL string) (*models.RepositoryTask, error) {
+// GetRepositoryTaskByGitURL Get a repository task by Git URL and ref.
+func (dao *RepositoryTaskDAO) GetRepositoryTaskByGitURL(gitURL string, ref string) (*models.RepositoryTask, error) {
 	var task = new(models.RepositoryTask)
-	result := dao.db.Where("git_url =?", gitURL).First(task)
+	result := dao.db.Where("git_url =? AND ref = ?", gitURL, ref).First(task)
 	if result.Error != nil {
 		zap.L().Error("Failed to get repository task by gitURL: %v", zap.Error(result.Error))
 		return nil, result.Error
@@ -69,6 +94,25 @@ func (dao *RepositoryTaskDAO) ListRepositoryTasksByStatus(status int, limit, off
 	return tasks, nil
 }
 
+// ListRepositoryTasksByURLPaths List the repository tasks of the source whose git url contains one of the paths,
+// ignoring case. The match is loose, callers compare the urls of the returned tasks.
+func (dao *RepositoryTaskDAO) ListRepositoryTasksByURLPaths(source string, paths []string) ([]*models.RepositoryTask, error) {
+	var tasks []*models.RepositoryTask
+	if len(paths) == 0 {
+		return tasks, nil
+	}
+	conditions := dao.db.Where("LOWER(git_url) LIKE ?", "%"+strings.ToLower(paths[0])+"%")
+	for _, path := range paths[1:] {
+		conditions = conditions.Or("LOWER(git_url) LIKE ?", "%"+strings.ToLower(path)+"%")
+	}
+	result := dao.db.Where("source = ?", source).Where(conditions).Find(&tasks)
+	if result.Error != nil {
+		zap.L().Error("Failed to list repository tasks by url paths: %v", zap.Error(result.Error))
+		return nil, result.Error
+	}
+	return tasks, nil
+}
+
 // UpdateRepositoryTask Update a repository task.
 func (dao *RepositoryTaskDAO) UpdateRepositoryTask(task *models.RepositoryTask) error {
 	result := dao.db.Save(task)
@@ -89,6 +133,7 @@ func (dao *RepositoryTaskDAO) DeleteRepositoryTask(id uint) error {
 	return nil
 }
 
+// UpdateRepositoryTaskStatus updates the status, the sync phase is set apart with UpdateRepositoryTaskSyncing.
 func (dao *RepositoryTaskDAO) UpdateRepositoryTaskStatus(id uint, status int) error {
 	result := dao.db.Model(&models.RepositoryTask{}).Where("id = ?", id).Update("status", status)
 	if result.Error != nil {
@@ -98,3 +143,67 @@ func (dao *RepositoryTaskDAO) UpdateRepositoryTaskStatus(id uint, status int) er
 	zap.L().Info("Update repository task status success", zap.Uint("task_id", id), zap.Int("status", status))
 	return nil
 }
+
+// UpdateProcessedTaskStatus updates the status of a task being processed. A task cancelled in the
+// meantime keeps its status, it reports whether the status was updated.
+func (dao *RepositoryTaskDAO) UpdateProcessedTaskStatus(id uint, status int) (bool, error) {
+	query := dao.db.Model(&models.RepositoryTask{}).Where("id = ?", id)
+	if status != models.RepositoryStatusCancelled {
+		query = query.Where("status <> ?", models.RepositoryStatusCancelled)
+	}
+	result := query.Update("status", status)
+	if result.Error != nil {
+		zap.L().Error("Failed to update repository task status: %v", zap.Error(result.Error))
+		return false, result.Error
+	}
+	zap.L().Info("Update repository task status success", zap.Uint("task_id", id), zap.Int("status", status), zap.Bool("updated", result.RowsAffected > 0))
+	return result.RowsAffected > 0, nil
+}
+
+// UpdateRepositoryTaskSyncing marks whether a task syncs its wiki, a sync that stops is retried as a sync.
+func (dao *RepositoryTaskDAO) UpdateRepositoryTaskSyncing(id uint, syncing bool) error {
+	result := dao.db.Model(&models.RepositoryTask{}).Where("id = ?", id).Update("syncing", syncing)
+	if result.Error != nil {
+		zap.L().Error("Failed to update repository task syncing: %v", zap.Error(result.Error))
+		return result.Error
+	}
+	return nil
+}
+
+func (dao *RepositoryTaskDAO) UpdateRepositoryTaskErrors(id uint, errors string) error {
+	result := dao.db.Model(&models.RepositoryTask{}).Where("id = ?", id).Update("errors", errors)
+	if result.Error != nil {
+		zap.L().Error("Failed to update repository task errors: %v", zap.Error(result.Error))
+		return result.Error
+	}
+	return nil
+}
+
+// SetRepositoryTaskRefreshPending marks a running task to be synced again once it completes.
+func (dao *RepositoryTaskDAO) SetRepositoryTaskRefreshPending(id uint, pending bool) error {
+	result := dao.db.Model(&models.RepositoryTask{}).Where("id = ?", id).Update("refresh_pending", pending)
+	if result.Error != nil {
+		zap.L().Error("Failed to update repository task refresh pending: %v", zap.Error(result.Error))
+		return result.Error
+	}
+	return nil
+}
+
+// TakeRepositoryTaskRefreshPending clears the pending refresh of a task, it reports whether one was pending.
+func (dao *RepositoryTaskDAO) TakeRepositoryTaskRefreshPending(id uint) (bool, error) {
+	result := dao.db.Model(&models.RepositoryTask{}).Where("id = ? AND refresh_pending = ?", id, true).Update("refresh_pending", false)
+	if result.Error != nil {
+		zap.L().Error("Failed to take repository task refresh pending: %v", zap.Error(result.Error))
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (dao *RepositoryTaskDAO) UpdateRepositoryTaskCredential(id uint, credentialID uint) error {
+	result := dao.db.Model(&models.RepositoryTask{}).Where("id = ?", id).Update("credential_id", credentialID)
+	if result.Error != nil {
+		zap.L().Error("Failed to update repository task credential: %v", zap.Error(result.Error))
+		return result.Error
+	}
+	return nil
+}
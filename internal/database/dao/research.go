@@ -0,0 +1,113 @@
+package dao
+
+import (
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ResearchDAO Research task data access object.
+type ResearchDAO struct {
+	db *gorm.DB
+}
+
+// NewResearchDAO Create a new research task data access object.
+func NewResearchDAO() *ResearchDAO {
+	return &ResearchDAO{
+		db: database.GetDB(),
+	}
+}
+
+// CreateResearchTask Create a new research task record.
+func (dao *ResearchDAO) CreateResearchTask(repoId uint, question string, rounds int) (*models.ResearchTask, error) {
+	task := &models.ResearchTask{
+		RepositoryID: repoId,
+		Question:     question,
+		Rounds:       rounds,
+		Status:       models.ResearchStatusPending,
+	}
+
+	result := dao.db.Create(task)
+	if result.Error != nil {
+		zap.L().Error("Failed to create research task: %v", zap.Error(result.Error))
+		return nil, result.Error
+	}
+
+	return task, nil
+}
+
+// GetResearchTaskByID Get a research task by ID.
+func (dao *ResearchDAO) GetResearchTaskByID(id uint) (*models.ResearchTask, error) {
+	var task = new(models.ResearchTask)
+	result := dao.db.First(task, id)
+	if result.Error != nil {
+		zap.L().Error("Failed to get research task by ID: %v", zap.Error(result.Error))
+		return nil, result.Error
+	}
+	return task, nil
+}
+
+// ListResearchTasksByStatus List research tasks by status.
+func (dao *ResearchDAO) ListResearchTasksByStatus(status int, limit, offset int) ([]*models.ResearchTask, error) {
+	var tasks []*models.ResearchTask
+	result := dao.db.Where("status =?", status).Limit(limit).Offset(offset).Find(&tasks)
+	if result.Error != nil {
+		zap.L().Error("Failed to list research tasks by status: %v", zap.Error(result.Error))
+		return nil, result.Error
+	}
+	return tasks, nil
+}
+
+func (dao *ResearchDAO) UpdateResearchTaskStatus(id uint, status int) error {
+	result := dao.db.Model(&models.ResearchTask{}).Where("id = ?", id).Update("status", status)
+	if result.Error != nil {
+		zap.L().Error("Failed to update research task status: %v", zap.Error(result.Error))
+		return result.Error
+	}
+	return nil
+}
+
+func (dao *ResearchDAO) UpdateResearchTaskConclusion(id uint, conclusion string) error {
+	result := dao.db.Model(&models.ResearchTask{}).Where("id = ?", id).Update("conclusion", conclusion)
+	return result.Error
+}
+
+func (dao *ResearchDAO) UpdateResearchTaskErrors(id uint, errors string) error {
+	result := dao.db.Model(&models.ResearchTask{}).Where("id = ?", id).Update("errors", errors)
+	return result.Error
+}
+
+// CreateResearchIteration Save the findings of one research iteration.
+func (dao *ResearchDAO) CreateResearchIteration(researchId uint, index int, content string) error {
+	return dao.db.Create(&models.ResearchIteration{
+		ResearchID: researchId,
+		Index:      index,
+		Content:    content,
+	}).Error
+}
+
+// DeleteResearchIterations Delete the iterations of a research task, a restarted research starts over.
+func (dao *ResearchDAO) DeleteResearchIterations(researchId uint) error {
+	return dao.db.Where("research_id = ?", researchId).Delete(&models.ResearchIteration{}).Error
+}
+
+// ListResearchIterations List the iterations of a research task in order.
+func (dao *ResearchDAO) ListResearchIterations(researchId uint) ([]*models.ResearchIteration, error) {
+	var iterations []*models.ResearchIteration
+	result := dao.db.Where("research_id = ?", researchId).Order("`index`").Find(&iterations)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return iterations, nil
+}
+
+// GetResearchIteration Get a single iteration of a research task.
+func (dao *ResearchDAO) GetResearchIteration(researchId uint, index int) (*models.ResearchIteration, error) {
+	var iteration models.ResearchIteration
+	result := dao.db.Where("research_id = ? AND `index` = ?", researchId, index).First(&iteration)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &iteration, nil
+}
@@ -67,12 +67,36 @@ func InitDB(dbPath string) error {
 // migrateModels migrate the database models
 func migrateModels() error {
 	zap.L().Info("migrating database models...")
-	return db.AutoMigrate(
+	err := db.AutoMigrate(
 		&models.Repository{},
 		&models.RepositoryTask{},
 		&models.Document{},
 		&models.LLMSettings{},
+		&models.ResearchTask{},
+		&models.ResearchIteration{},
+		&models.DocumentCommitRecord{},
+		&models.DocumentCheckpoint{},
+		&models.GitCredential{},
+		&models.LLMCache{},
+		&models.LLMUsage{},
 	)
+	if err != nil {
+		return err
+	}
+
+	// the git url alone was unique before several refs of a repository could be documented
+	migrator := db.Migrator()
+	if migrator.HasIndex(&models.RepositoryTask{}, "idx_repository_tasks_git_url") {
+		if err = migrator.DropIndex(&models.RepositoryTask{}, "idx_repository_tasks_git_url"); err != nil {
+			return err
+		}
+	}
+	if migrator.HasIndex(&models.Repository{}, "idx_repositories_git_url") {
+		if err = migrator.DropIndex(&models.Repository{}, "idx_repositories_git_url"); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // initDefaultLLMSettings initialize default LLM settings
@@ -84,13 +108,15 @@ func initDefaultLLMSettings() error {
 	if count == 0 {
 		llm := config.GetLLMConfig()
 		defaultSettings := models.LLMSettings{
-			ProviderType: llm.ProviderType,
-			ModelLLM:     llm.Model,
-			MaxTokens:    llm.MaxTokens,
-			Temperature:  llm.Temperature,
-			IsDefault:    true,
-			APIKey:       llm.APIKey,
-			BaseURL:      llm.BaseURL,
+			ProviderType:  llm.ProviderType,
+			ModelLLM:      llm.Model,
+			MaxTokens:     llm.MaxTokens,
+			Temperature:   llm.Temperature,
+			IsDefault:     true,
+			APIKey:        llm.APIKey,
+			BaseURL:       llm.BaseURL,
+			APIVersion:    llm.APIVersion,
+			ContextWindow: llm.ContextWindow,
 		}
 
 		return db.Create(&defaultSettings).Error
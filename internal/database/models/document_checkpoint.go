@@ -0,0 +1,14 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// DocumentCheckpoint A document generated by an unfinished task, removed once the documents are saved.
+type DocumentCheckpoint struct {
+	gorm.Model
+	RepoId  uint   `gorm:"index" json:"repo_id"`
+	Key     string `json:"key"` // position in the document catalogue, e.g. "2.1"
+	Title   string `json:"title"`
+	Content string `gorm:"type:text" json:"content"`
+}
@@ -1,14 +1,25 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// Document commit record types.
+const (
+	DocumentRecordHistory = iota // summary of the repository commits, shown as the changelog
+	DocumentRecordPublish        // the wiki was committed to a branch of the repository
+)
+
 // DocumentCommitRecord Document commit record model.
 type DocumentCommitRecord struct {
 	gorm.Model
-	WarehouseId   string `json:"warehouse_id"`
-	Title         string `json:"title"`
-	CommitMessage string `json:"commit_message"`
-	Author        string `json:"author"`
+	RepoId        uint      `gorm:"index" json:"repo_id"`
+	Type          int       `gorm:"default:0" json:"type"`           // DocumentRecordHistory or DocumentRecordPublish
+	Title         string    `json:"title"`                           // release tag, time window or published branch
+	CommitMessage string    `gorm:"type:text" json:"commit_message"` // summary of the commits
+	Author        string    `json:"author"`
+	CommitID      string    `json:"commit_id"` // newest commit of the group
+	Date          time.Time `json:"date"`      // date of the newest commit
 }
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// GitCredential Credential used to clone and pull a private repository, secrets are stored encrypted.
+type GitCredential struct {
+	gorm.Model
+	Type       string `json:"type"` // token or ssh
+	Username   string `json:"username"`
+	Secret     string `gorm:"type:text" json:"-"` // encrypted token or ssh private key
+	Passphrase string `json:"-"`                  // encrypted ssh key passphrase
+}
+
+const (
+	GitCredentialToken = "token"
+	GitCredentialSSH   = "ssh"
+)
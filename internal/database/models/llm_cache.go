@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LLMCache A cached llm response of a repository, expired entries are removed by the task queue.
+type LLMCache struct {
+	gorm.Model
+	RepoId    uint      `gorm:"uniqueIndex:idx_llm_cache_key" json:"repo_id"`
+	Key       string    `gorm:"uniqueIndex:idx_llm_cache_key" json:"key"` // hash of the model, messages and tools
+	Response  string    `gorm:"type:text" json:"response"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+}
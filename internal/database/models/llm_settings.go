@@ -7,11 +7,14 @@ import (
 // LLMSettings 语言模型设置
 type LLMSettings struct {
 	gorm.Model
-	ProviderType string  `json:"provider_type"` // openai, google, deepseek, ollama, llamacpp, vllm
+	ProviderType string  `json:"provider_type"` // openai, google, deepseek, ollama, llamacpp, vllm, anthropic, azure
 	APIKey       string  `json:"api_key"`
 	ModelLLM     string  `json:"model_llm"`
 	BaseURL      string  `json:"base_url"`
+	APIVersion   string  `json:"api_version"` // azure only
 	MaxTokens    int     `json:"max_tokens"`
 	Temperature  float64 `json:"temperature"`
-	IsDefault    bool    `json:"is_default"` // 是否为默认设置
+	// 上下文窗口，0 表示按模型推断
+	ContextWindow int  `json:"context_window"`
+	IsDefault     bool `json:"is_default"` // 是否为默认设置
 }
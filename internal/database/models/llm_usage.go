@@ -0,0 +1,18 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// LLMUsage The token usage and cost of a llm request of a repository task.
+type LLMUsage struct {
+	gorm.Model
+	TaskId           uint    `gorm:"index" json:"task_id"`
+	RepoId           uint    `gorm:"index" json:"repo_id"`
+	Stage            string  `json:"stage"` // readme, catalogue, overview, think, document, history
+	ModelLLM         string  `json:"model"` // provider and model, e.g. default/gpt-4o-mini
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Estimated        bool    `json:"estimated"` // counted with tiktoken, the provider reported no usage
+	Cost             float64 `json:"cost"`      // 0 if the model has no pricing
+}
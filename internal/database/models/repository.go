@@ -1,28 +1,36 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 // Repository Repository model.
 type Repository struct {
 	gorm.Model
-	GitURL             string `gorm:"uniqueIndex" json:"git_url"`
+	GitURL             string `gorm:"uniqueIndex:idx_repository_git_url_branch" json:"git_url"`
 	Name               string `json:"name"`
 	Path               string `json:"path"` // local path to the repository database, default is {repoDir}/{name}
 	Description        string `json:"description"`
 	Status             int    `json:"status"`
-	Branch             string `json:"branch"` // default branch name, default is maste
+	Branch             string `gorm:"uniqueIndex:idx_repository_git_url_branch" json:"branch"` // branch, tag or commit checked out, empty for the default branch
 	Overview           string `json:"overview"`
 	Readme             string `json:"readme"` // readme file content, default is README.md
 	StructedCatalogue  string `json:"structured_catalogue"`
 	StructedCodePath   string `json:"structured_code_path"`   // path to the structured code database, default is {repoDir}/{name}.db
 	StructedVectorPath string `json:"structured_vector_path"` // path to the structured vector database, default is {repoDir}/{name}.db
 	Language           string `json:"language"`
+	CommitID           string `json:"commit_id"` // last documented commit
+	// generated document catalogue, used to resume an interrupted generation
+	DocumentCatalogue string `gorm:"type:text" json:"document_catalogue"`
+	// interval such as 6h or cron expression to check the remote for new commits, empty disables it
+	RefreshSchedule string    `json:"refresh_schedule"`
+	RefreshedAt     time.Time `json:"refreshed_at"` // last scheduled check of the remote
 }
 
 func (r *Repository) StatusString() string {
-	return getStatusString(r.Status)
+	return GetStatusString(r.Status)
 }
 
 func (r *Repository) WebStatus() string {
@@ -31,14 +39,15 @@ func (r *Repository) WebStatus() string {
 		return "pending"
 	case RepositoryStatusCompleted:
 		return "success"
-	case RepositoryStatusFailed:
+	case RepositoryStatusFailed, RepositoryStatusCancelled:
 		return "failed"
 	default:
 		return "pending"
 	}
 }
 
-func getStatusString(status int) string {
+// GetStatusString returns the display name of a repository status.
+func GetStatusString(status int) string {
 	switch status {
 	case RepositoryStatusPending:
 		return "Pending"
@@ -50,6 +59,10 @@ func getStatusString(status int) string {
 		return "Completed"
 	case RepositoryStatusFailed:
 		return "Failed"
+	case RepositoryStatusUpdating:
+		return "Updating"
+	case RepositoryStatusCancelled:
+		return "Cancelled"
 	default:
 		return "Unknown"
 	}
@@ -61,4 +74,6 @@ const (
 	RepositoryStatusAnalyzed
 	RepositoryStatusCompleted
 	RepositoryStatusFailed
+	RepositoryStatusUpdating // completed task waiting for an incremental update
+	RepositoryStatusCancelled
 )
@@ -7,16 +7,30 @@ import (
 type RepositoryTask struct {
 	gorm.Model
 	RepositoryID uint   `gorm:"index" json:"repository_id"`
-	GitURL       string `gorm:"uniqueIndex" json:"git_url"`
+	GitURL       string `gorm:"uniqueIndex:idx_task_git_url_ref" json:"git_url"`
+	// branch, tag or commit, empty for the default branch
+	Ref          string `gorm:"uniqueIndex:idx_task_git_url_ref;default:''" json:"ref"`
 	Status       int    `json:"status"` // 0: Pending, 1: Cloning, 2: Analyzing, 3: Completed
 	Errors       string `json:"errors"`
 	Language     string `json:"language"`
+	Owner        string `json:"owner"`                       // client address of the submitter
+	CredentialID uint   `json:"credential_id"`               // credential of a private repository, 0 for public ones
+	Source       string `gorm:"default:'git'" json:"source"` // git, or local for imported directories and archives
+	// a push arrived while the task was running, it is synced again once completed
+	RefreshPending bool `json:"refresh_pending"`
+	// the task stopped while it was synced, a retry syncs again instead of analyzing from scratch
+	Syncing bool `json:"syncing"`
 }
 
 func (t *RepositoryTask) StatusString() string {
-	return getStatusString(t.Status)
+	return GetStatusString(t.Status)
 }
 
+const (
+	RepositorySourceGit   = "git"
+	RepositorySourceLocal = "local" // GitURL is the local directory
+)
+
 const (
 	LanguageEnglish = "english"
 	LanguageChinese = "简体中文"
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// ResearchTask Deep research task model.
+type ResearchTask struct {
+	gorm.Model
+	RepositoryID uint   `gorm:"index" json:"repository_id"`
+	Question     string `json:"question"`
+	Rounds       int    `json:"rounds"` // number of research iterations before the conclusion
+	Status       int    `json:"status"` // 0: Pending, 1: Running, 2: Completed, 3: Failed, 4: Cancelled
+	Conclusion   string `gorm:"type:text" json:"conclusion"`
+	Errors       string `json:"errors"`
+}
+
+// ResearchIteration Intermediate findings of a research task.
+type ResearchIteration struct {
+	gorm.Model
+	ResearchID uint   `gorm:"index" json:"research_id"`
+	Index      int    `json:"index"`
+	Content    string `gorm:"type:text" json:"content"`
+}
+
+func (t *ResearchTask) StatusString() string {
+	switch t.Status {
+	case ResearchStatusPending:
+		return "pending"
+	case ResearchStatusRunning:
+		return "running"
+	case ResearchStatusCompleted:
+		return "success"
+	case ResearchStatusFailed:
+		return "failed"
+	case ResearchStatusCancelled:
+		return "cancelled"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	ResearchStatusPending = iota
+	ResearchStatusRunning
+	ResearchStatusCompleted
+	ResearchStatusFailed
+	ResearchStatusCancelled
+)
@@ -0,0 +1,203 @@
+package export
+
+import (
+	"archive/zip"
+	"fmt"
+	"html/template"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"gitlab.com/golang-commonmark/markdown"
+)
+
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// File is a file of an exported wiki, the path is relative to the wiki root.
+type File struct {
+	Path    string
+	Content []byte
+}
+
+// page is a document placed in the exported directory tree.
+type page struct {
+	doc      *models.Document
+	path     string // without extension
+	children []*page
+}
+
+// Build exports the overview and the documents of a repository as Markdown or HTML files.
+// Documents with children become a directory with an index page, the wiki root has an index
+// page with the overview and the table of contents.
+func Build(name, overview string, docs []*models.Document, format string) ([]File, error) {
+	if format != FormatMarkdown && format != FormatHTML {
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	pages := buildPages(docs)
+
+	var files []File
+	var walk func(list []*page)
+	walk = func(list []*page) {
+		for _, p := range list {
+			files = append(files, renderPage(p.path, p.doc.Title, p.doc.Content, format))
+			walk(p.children)
+		}
+	}
+	walk(pages)
+
+	toc := tableOfContents(pages, format)
+	files = append([]File{renderPage("index", name, overview+"\n\n## Contents\n\n"+toc, format)}, files...)
+	return files, nil
+}
+
+// WriteZip writes the files into a zip archive under the root directory.
+func WriteZip(w io.Writer, root string, files []File) error {
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		writer, err := archive.Create(path.Join(root, file.Path))
+		if err != nil {
+			return err
+		}
+		if _, err = writer.Write(file.Content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// buildPages rebuilds the catalogue hierarchy from the parent indexes, siblings keep the catalogue order.
+func buildPages(docs []*models.Document) []*page {
+	sorted := make([]*models.Document, len(docs))
+	copy(sorted, docs)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Index < sorted[j].Index
+	})
+
+	var pageMap = make(map[int]*page)
+	for _, doc := range sorted {
+		pageMap[doc.Index] = &page{doc: doc}
+	}
+
+	var roots []*page
+	for _, doc := range sorted {
+		p := pageMap[doc.Index]
+		if parent, ok := pageMap[int(doc.ParentId)]; ok && doc.ParentId != 0 {
+			parent.children = append(parent.children, p)
+			continue
+		}
+		roots = append(roots, p)
+	}
+
+	// the generated tree has an untitled root document
+	if len(roots) == 1 && roots[0].doc.Title == "" {
+		roots = roots[0].children
+	}
+
+	assignPaths(roots, "")
+	return roots
+}
+
+func assignPaths(pages []*page, dir string) {
+	for idx, p := range pages {
+		name := fmt.Sprintf("%02d-%s", idx+1, slug(p.doc.Title))
+		if len(p.children) == 0 {
+			p.path = path.Join(dir, name)
+			continue
+		}
+		p.path = path.Join(dir, name, "index")
+		assignPaths(p.children, path.Join(dir, name))
+	}
+}
+
+func tableOfContents(pages []*page, format string) string {
+	var builder strings.Builder
+	var walk func(list []*page, depth int)
+	walk = func(list []*page, depth int) {
+		for _, p := range list {
+			fmt.Fprintf(&builder, "%s- [%s](%s)\n", strings.Repeat("  ", depth), p.doc.Title, p.path+extension(format))
+			walk(p.children, depth+1)
+		}
+	}
+	walk(pages, 0)
+	return builder.String()
+}
+
+// slug keeps letters and digits of any language, other characters become dashes.
+func slug(title string) string {
+	var builder strings.Builder
+	var dash bool
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			builder.WriteRune(r)
+			dash = false
+		} else if !dash && builder.Len() > 0 {
+			builder.WriteRune('-')
+			dash = true
+		}
+	}
+
+	result := strings.TrimSuffix(builder.String(), "-")
+	if len(result) == 0 {
+		return "document"
+	}
+	return result
+}
+
+func extension(format string) string {
+	if format == FormatHTML {
+		return ".html"
+	}
+	return ".md"
+}
+
+var htmlTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 960px; margin: 0 auto; padding: 24px; font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; line-height: 1.6; }
+pre { background: #f6f8fa; padding: 12px; overflow: auto; }
+code { background: #f6f8fa; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d0d7de; padding: 4px 8px; }
+</style>
+</head>
+<body>
+{{if .Home}}<p><a href="{{.Home}}">Home</a></p>{{end}}
+{{.Content}}
+</body>
+</html>
+`))
+
+var markdownRenderer = markdown.New(markdown.XHTMLOutput(true), markdown.Tables(true), markdown.Linkify(true))
+
+func renderPage(pagePath, title, content string, format string) File {
+	if !strings.HasPrefix(strings.TrimSpace(content), "#") {
+		content = "# " + title + "\n\n" + content
+	}
+	if format == FormatMarkdown {
+		return File{Path: pagePath + ".md", Content: []byte(content)}
+	}
+
+	// links back to the index page relative to the page directory
+	var home string
+	if pagePath != "index" {
+		home = strings.Repeat("../", strings.Count(pagePath, "/")) + "index.html"
+	}
+
+	var builder strings.Builder
+	htmlTemplate.Execute(&builder, map[string]any{
+		"Title":   title,
+		"Home":    home,
+		"Content": template.HTML(markdownRenderer.RenderToString([]byte(content))),
+	})
+	return File{Path: pagePath + ".html", Content: []byte(builder.String())}
+}
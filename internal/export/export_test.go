@@ -0,0 +1,151 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+)
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Getting Started", "getting-started"},
+		{"Hello, World!", "hello-world"},
+		{"  --Leading and trailing--  ", "leading-and-trailing"},
+		{"C++ & Go", "c-go"},
+		{"API: v2 / REST", "api-v2-rest"},
+		{"快速开始", "快速开始"},
+		{"核心 模块：存储", "核心-模块-存储"},
+		{"版本 2.0", "版本-2-0"},
+		{"Überblick", "überblick"},
+		{"!!!", "document"},
+		{"", "document"},
+	}
+	for _, test := range tests {
+		if got := slug(test.title); got != test.want {
+			t.Errorf("slug(%q) = %q, want %q", test.title, got, test.want)
+		}
+	}
+}
+
+// sampleDocs is a generated catalogue, the untitled root holds the documents.
+func sampleDocs() []*models.Document {
+	return []*models.Document{
+		{Index: 4, ParentId: 3, Title: "核心 模块", Content: "# 核心 模块\n\nStorage."},
+		{Index: 1, Title: ""},
+		{Index: 2, ParentId: 1, Title: "Getting Started", Content: "Install it."},
+		{Index: 3, ParentId: 1, Title: "Architecture", Content: "# Architecture\n\nLayers."},
+		{Index: 5, ParentId: 4, Title: "Index & Cache", Content: "Keys."},
+		{Index: 6, ParentId: 3, Title: "API: v2", Content: "Routes."},
+	}
+}
+
+func TestBuildPaths(t *testing.T) {
+	files, err := Build("sample", "The overview.", sampleDocs(), FormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"index.md",
+		"01-getting-started.md",
+		"02-architecture/index.md",
+		"02-architecture/01-核心-模块/index.md",
+		"02-architecture/01-核心-模块/01-index-cache.md",
+		"02-architecture/02-api-v2.md",
+	}
+	if len(files) != len(want) {
+		t.Fatalf("got %d files, want %d", len(files), len(want))
+	}
+	contents := make(map[string]string)
+	for idx, file := range files {
+		if file.Path != want[idx] {
+			t.Fatalf("file %d is %s, want %s", idx, file.Path, want[idx])
+		}
+		contents[file.Path] = string(file.Content)
+	}
+
+	// pages without a heading get the title as one
+	if !strings.HasPrefix(contents["01-getting-started.md"], "# Getting Started\n\nInstall it.") {
+		t.Fatalf("got page %q", contents["01-getting-started.md"])
+	}
+	if strings.Count(contents["02-architecture/index.md"], "# Architecture") != 1 {
+		t.Fatalf("got page %q", contents["02-architecture/index.md"])
+	}
+
+	index := contents["index.md"]
+	for _, line := range []string{
+		"# sample",
+		"The overview.",
+		"- [Getting Started](01-getting-started.md)",
+		"- [Architecture](02-architecture/index.md)",
+		"  - [核心 模块](02-architecture/01-核心-模块/index.md)",
+		"    - [Index & Cache](02-architecture/01-核心-模块/01-index-cache.md)",
+		"  - [API: v2](02-architecture/02-api-v2.md)",
+	} {
+		if !strings.Contains(index, line+"\n") {
+			t.Fatalf("index has no line %q:\n%s", line, index)
+		}
+	}
+}
+
+func TestBuildHTMLLinks(t *testing.T) {
+	files, err := Build("sample", "The overview.", sampleDocs(), FormatHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := make(map[string]string)
+	for _, file := range files {
+		contents[file.Path] = string(file.Content)
+	}
+
+	tests := []struct {
+		path string
+		home string // link to the wiki index, empty for the index itself
+	}{
+		{"index.html", ""},
+		{"01-getting-started.html", `href="index.html"`},
+		{"02-architecture/index.html", `href="../index.html"`},
+		{"02-architecture/02-api-v2.html", `href="../index.html"`},
+		{"02-architecture/01-核心-模块/01-index-cache.html", `href="../../index.html"`},
+	}
+	for _, test := range tests {
+		content, ok := contents[test.path]
+		if !ok {
+			t.Fatalf("no file %s", test.path)
+		}
+		if test.home == "" {
+			if strings.Contains(content, ">Home</a>") {
+				t.Fatalf("%s links to itself", test.path)
+			}
+			continue
+		}
+		if !strings.Contains(content, test.home+">Home</a>") {
+			t.Fatalf("%s has no link %s:\n%s", test.path, test.home, content)
+		}
+	}
+
+	index := contents["index.html"]
+	for _, link := range []string{
+		`href="01-getting-started.html"`,
+		`href="02-architecture/index.html"`,
+		`href="02-architecture/02-api-v2.html"`,
+	} {
+		if !strings.Contains(index, link) {
+			t.Fatalf("index has no link %s:\n%s", link, index)
+		}
+	}
+	// titles are escaped
+	if !strings.Contains(contents["02-architecture/01-核心-模块/01-index-cache.html"], "<title>Index &amp; Cache</title>") {
+		t.Fatal("title is not escaped")
+	}
+}
+
+func TestBuildUnsupportedFormat(t *testing.T) {
+	if _, err := Build("sample", "", sampleDocs(), "pdf"); err == nil {
+		t.Fatal("built an unsupported format")
+	}
+}
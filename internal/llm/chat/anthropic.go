@@ -0,0 +1,384 @@
+package chat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+const (
+	// defaultAnthropicURL Anthropic 官方接口地址
+	defaultAnthropicURL = "https://api.anthropic.com/v1"
+	anthropicVersion    = "2023-06-01"
+	// anthropicMaxTokens Messages API 必须指定 max_tokens
+	anthropicMaxTokens = 8192
+)
+
+// AnthropicProvider Anthropic Claude 提供商实现
+type AnthropicProvider struct {
+	model  llms.Model
+	config *ProviderConfig
+}
+
+// NewAnthropicProvider 创建一个新的 Anthropic 提供商
+func NewAnthropicProvider(config *ProviderConfig) (Provider, error) {
+	apiKey := config.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("ANTHROPIC_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("未提供 Anthropic API 密钥")
+		}
+	}
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = defaultAnthropicURL
+	}
+
+	maxTokens := config.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicMaxTokens
+	}
+
+	return &AnthropicProvider{
+		model: &anthropicModel{
+			baseURL:     baseURL,
+			apiKey:      apiKey,
+			model:       config.Model,
+			maxTokens:   maxTokens,
+			temperature: config.Temperature,
+		},
+		config: config,
+	}, nil
+}
+
+func (p *AnthropicProvider) HandleResponse(response llms.ContentResponse) {
+
+}
+
+func (p *AnthropicProvider) GetModel() llms.Model {
+	return p.model
+}
+
+// anthropicModel talks to the Messages API. The langchaingo client only converts the first
+// part of a message and returns every content block as a choice of its own, which loses the
+// tool calls of an answer that starts with text.
+type anthropicModel struct {
+	baseURL     string
+	apiKey      string
+	model       string
+	maxTokens   int
+	temperature float64
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Thinking  string          `json:"thinking,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Tools         []anthropicTool    `json:"tools,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+// anthropicEvent is a server sent event of a streamed message.
+type anthropicEvent struct {
+	Type         string             `json:"type"`
+	Index        int                `json:"index"`
+	Message      *anthropicResponse `json:"message"`
+	ContentBlock *anthropicBlock    `json:"content_block"`
+	Delta        *struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Call implements llms.Model.
+func (m *anthropicModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// GenerateContent implements llms.Model.
+func (m *anthropicModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	opts := llms.CallOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	request, err := m.buildRequest(messages, &opts)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(m.baseURL, "/")+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("x-api-key", m.apiKey)
+	httpRequest.Header.Set("anthropic-version", anthropicVersion)
+
+	httpResponse, err := httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(httpResponse.Body, 4096))
+		var event anthropicEvent
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &event) == nil && event.Error != nil {
+			message = event.Error.Type + ": " + event.Error.Message
+		}
+		return nil, fmt.Errorf("API returned unexpected status code: %d: %s", httpResponse.StatusCode, message)
+	}
+
+	if request.Stream {
+		return m.readStream(ctx, httpResponse.Body, opts.StreamingFunc)
+	}
+
+	var response anthropicResponse
+	if err = json.NewDecoder(httpResponse.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decode response failed: %w", err)
+	}
+
+	var content, thinking strings.Builder
+	var assembler toolCallAssembler
+	for idx, block := range response.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "thinking":
+			thinking.WriteString(block.Thinking)
+		case "tool_use":
+			assembler.add([]compatibleToolCall{newToolCallDelta(idx, block.ID, block.Name, string(block.Input))})
+		}
+	}
+	return m.newResponse(content.String(), thinking.String(), response.StopReason, assembler.calls(), response.Usage), nil
+}
+
+func (m *anthropicModel) buildRequest(messages []llms.MessageContent, opts *llms.CallOptions) (*anthropicRequest, error) {
+	request := &anthropicRequest{
+		Model:         m.model,
+		MaxTokens:     m.maxTokens,
+		StopSequences: opts.StopWords,
+		Stream:        opts.StreamingFunc != nil,
+	}
+	if len(opts.Model) > 0 {
+		request.Model = opts.Model
+	}
+	if opts.MaxTokens > 0 {
+		request.MaxTokens = opts.MaxTokens
+	}
+	temperature := m.temperature
+	if opts.Temperature > 0 {
+		temperature = opts.Temperature
+	}
+	// the Messages API accepts a temperature between 0 and 1
+	if temperature > 0 {
+		temperature = min(temperature, 1)
+		request.Temperature = &temperature
+	}
+	for _, tool := range opts.Tools {
+		if tool.Function == nil {
+			continue
+		}
+		request.Tools = append(request.Tools, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: tool.Function.Parameters,
+		})
+	}
+
+	var system []string
+	for _, message := range messages {
+		var role string
+		var blocks []anthropicBlock
+		for _, part := range message.Parts {
+			switch p := part.(type) {
+			case llms.TextContent:
+				// Claude does not know the soft switch of the prompts
+				text := strings.TrimSpace(strings.ReplaceAll(p.Text, noThinkSwitch, ""))
+				if len(text) > 0 {
+					blocks = append(blocks, anthropicBlock{Type: "text", Text: text})
+				}
+			case llms.ToolCall:
+				block := anthropicBlock{Type: "tool_use", ID: p.ID, Input: json.RawMessage("{}")}
+				if p.FunctionCall != nil {
+					block.Name = p.FunctionCall.Name
+					if json.Valid([]byte(p.FunctionCall.Arguments)) {
+						block.Input = json.RawMessage(p.FunctionCall.Arguments)
+					}
+				}
+				blocks = append(blocks, block)
+			case llms.ToolCallResponse:
+				blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: p.ToolCallID, Content: p.Content})
+			default:
+				return nil, fmt.Errorf("unsupported message part: %T", part)
+			}
+		}
+
+		switch message.Role {
+		case llms.ChatMessageTypeSystem:
+			for _, block := range blocks {
+				system = append(system, block.Text)
+			}
+			continue
+		case llms.ChatMessageTypeHuman, llms.ChatMessageTypeGeneric, llms.ChatMessageTypeTool:
+			role = "user"
+		case llms.ChatMessageTypeAI:
+			role = "assistant"
+		default:
+			return nil, fmt.Errorf("unsupported message role: %s", message.Role)
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+
+		// the tool results of several tool messages belong to one user turn
+		if last := len(request.Messages) - 1; last >= 0 && request.Messages[last].Role == role {
+			request.Messages[last].Content = append(request.Messages[last].Content, blocks...)
+			continue
+		}
+		request.Messages = append(request.Messages, anthropicMessage{Role: role, Content: blocks})
+	}
+	request.System = strings.Join(system, "\n\n")
+	return request, nil
+}
+
+// readStream reads the server sent events, only the text is passed to the streaming func.
+func (m *anthropicModel) readStream(ctx context.Context, body io.Reader, streamingFunc func(ctx context.Context, chunk []byte) error) (*llms.ContentResponse, error) {
+	var content, thinking strings.Builder
+	var stopReason string
+	var usage anthropicUsage
+	var assembler toolCallAssembler
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var event anthropicEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return nil, fmt.Errorf("decode stream event failed: %w", err)
+		}
+
+		switch event.Type {
+		case "error":
+			if event.Error != nil {
+				return nil, fmt.Errorf("stream error: %s: %s", event.Error.Type, event.Error.Message)
+			}
+			return nil, fmt.Errorf("stream error")
+		case "message_start":
+			if event.Message != nil {
+				usage.InputTokens = event.Message.Usage.InputTokens
+			}
+		case "content_block_start":
+			if event.ContentBlock != nil && event.ContentBlock.Type == "tool_use" {
+				assembler.add([]compatibleToolCall{newToolCallDelta(event.Index, event.ContentBlock.ID, event.ContentBlock.Name, "")})
+			}
+		case "content_block_delta":
+			if event.Delta == nil {
+				continue
+			}
+			switch event.Delta.Type {
+			case "text_delta":
+				content.WriteString(event.Delta.Text)
+				if streamingFunc != nil && len(event.Delta.Text) > 0 {
+					if err := streamingFunc(ctx, []byte(event.Delta.Text)); err != nil {
+						return nil, fmt.Errorf("streaming func returned an error: %w", err)
+					}
+				}
+			case "thinking_delta":
+				thinking.WriteString(event.Delta.Thinking)
+			case "input_json_delta":
+				assembler.add([]compatibleToolCall{newToolCallDelta(event.Index, "", "", event.Delta.PartialJSON)})
+			}
+		case "message_delta":
+			if event.Delta != nil && len(event.Delta.StopReason) > 0 {
+				stopReason = event.Delta.StopReason
+			}
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "message_stop":
+			return m.newResponse(content.String(), thinking.String(), stopReason, assembler.calls(), usage), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("stream ended before message_stop")
+}
+
+func (m *anthropicModel) newResponse(content, thinking, stopReason string, toolCalls []llms.ToolCall, usage anthropicUsage) *llms.ContentResponse {
+	choice := newContentChoice(content, thinking, stopReason, toolCalls)
+	setUsage(choice, usage.InputTokens, usage.OutputTokens, usage.InputTokens+usage.OutputTokens)
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{choice}}
+}
+
+// newToolCallDelta addresses a tool call fragment to the content block it belongs to.
+func newToolCallDelta(index int, id, name, arguments string) compatibleToolCall {
+	call := compatibleToolCall{Index: &index, ID: id, Type: "function"}
+	call.Function.Name = name
+	call.Function.Arguments = arguments
+	return call
+}
@@ -0,0 +1,209 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// readFixture reads a file of testdata.
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// assertJSON compares a request body with the expected fixture, ignoring the formatting.
+func assertJSON(t *testing.T, got []byte, fixture string) {
+	t.Helper()
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("request is no json: %v", err)
+	}
+	if err := json.Unmarshal(readFixture(t, fixture), &wantValue); err != nil {
+		t.Fatalf("fixture %s is no json: %v", fixture, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		indented, _ := json.MarshalIndent(gotValue, "", "  ")
+		t.Fatalf("request differs from %s:\n%s", fixture, indented)
+	}
+}
+
+// anthropicServer answers every message with the fixture and keeps the last request.
+type anthropicServer struct {
+	*httptest.Server
+	header http.Header
+	body   []byte
+}
+
+func newAnthropicServer(t *testing.T, fixture string) *anthropicServer {
+	response := readFixture(t, fixture)
+	server := &anthropicServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			http.NotFound(w, r)
+			return
+		}
+		server.header = r.Header.Clone()
+		server.body, _ = io.ReadAll(r.Body)
+		if strings.HasSuffix(fixture, ".txt") {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.Write(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// anthropicConversation is a tool turn of the document generation: an answer that starts
+// with text before its tool calls, followed by the results of two tool messages.
+func anthropicConversation() ([]llms.MessageContent, []llms.CallOption) {
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, "You are a technical writer.", "Answer in English."),
+		llms.TextParts(llms.ChatMessageTypeHuman, "/no_think Document the repository."),
+		{Role: llms.ChatMessageTypeAI, Parts: []llms.ContentPart{
+			llms.TextContent{Text: "I will read the sources first."},
+			llms.ToolCall{ID: "toolu_01", Type: "function", FunctionCall: &llms.FunctionCall{Name: "readFiles", Arguments: `{"paths": ["README.md"]}`}},
+			llms.ToolCall{ID: "toolu_02", Type: "function", FunctionCall: &llms.FunctionCall{Name: "listFiles", Arguments: "not json"}},
+		}},
+		{Role: llms.ChatMessageTypeTool, Parts: []llms.ContentPart{
+			llms.ToolCallResponse{ToolCallID: "toolu_01", Name: "readFiles", Content: "# Sample"},
+		}},
+		{Role: llms.ChatMessageTypeTool, Parts: []llms.ContentPart{
+			llms.ToolCallResponse{ToolCallID: "toolu_02", Name: "listFiles", Content: "README.md\nsrc/lib.rs"},
+		}},
+	}
+	options := []llms.CallOption{
+		llms.WithMaxTokens(2048),
+		llms.WithTemperature(1.5),
+		llms.WithStopWords([]string{"</answer>"}),
+		llms.WithTools([]llms.Tool{
+			{Type: "function", Function: &llms.FunctionDefinition{
+				Name:        "readFiles",
+				Description: "Read the files of the repository.",
+				Parameters: map[string]any{
+					"type":       "object",
+					"properties": map[string]any{"paths": map[string]any{"type": "array", "items": map[string]any{"type": "string"}}},
+					"required":   []string{"paths"},
+				},
+			}},
+			{Type: "function"},
+		}),
+	}
+	return messages, options
+}
+
+func newAnthropicTestModel(t *testing.T, baseURL string) llms.Model {
+	provider, err := NewAnthropicProvider(&ProviderConfig{BaseURL: baseURL + "/v1", APIKey: "test-key", Model: "claude-sonnet-4-20250514"})
+	if err != nil {
+		t.Fatalf("create provider failed: %v", err)
+	}
+	return provider.GetModel()
+}
+
+func assertAnthropicAnswer(t *testing.T, response *llms.ContentResponse) {
+	t.Helper()
+	if len(response.Choices) != 1 {
+		t.Fatalf("got %d choices, want the blocks in one", len(response.Choices))
+	}
+	choice := response.Choices[0]
+	if choice.Content != "Reading the library." || choice.ReasoningContent != "The library needs its sources." {
+		t.Fatalf("got content %q and reasoning %q", choice.Content, choice.ReasoningContent)
+	}
+	if len(choice.ToolCalls) != 1 || choice.ToolCalls[0].ID != "toolu_03" || choice.ToolCalls[0].FunctionCall.Name != "readFiles" {
+		t.Fatalf("got tool calls %+v", choice.ToolCalls)
+	}
+	var arguments struct {
+		Paths []string `json:"paths"`
+	}
+	if err := json.Unmarshal([]byte(choice.ToolCalls[0].FunctionCall.Arguments), &arguments); err != nil || !reflect.DeepEqual(arguments.Paths, []string{"src/lib.rs"}) {
+		t.Fatalf("got arguments %q", choice.ToolCalls[0].FunctionCall.Arguments)
+	}
+	if choice.StopReason != "tool_use" {
+		t.Fatalf("got stop reason %q", choice.StopReason)
+	}
+	if choice.GenerationInfo["PromptTokens"] != 320 || choice.GenerationInfo["CompletionTokens"] != 48 || choice.GenerationInfo["TotalTokens"] != 368 {
+		t.Fatalf("got generation info %v", choice.GenerationInfo)
+	}
+}
+
+func TestAnthropicConvertsToolTurn(t *testing.T) {
+	server := newAnthropicServer(t, "anthropic/tool_turn.response.json")
+	messages, options := anthropicConversation()
+
+	response, err := newAnthropicTestModel(t, server.URL).GenerateContent(context.Background(), messages, options...)
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	assertJSON(t, server.body, "anthropic/tool_turn.request.json")
+	if server.header.Get("x-api-key") != "test-key" || server.header.Get("anthropic-version") != anthropicVersion {
+		t.Fatalf("sent headers %v", server.header)
+	}
+	assertAnthropicAnswer(t, response)
+}
+
+func TestAnthropicStreamsToolTurn(t *testing.T) {
+	server := newAnthropicServer(t, "anthropic/tool_turn.stream.txt")
+	messages, options := anthropicConversation()
+
+	var chunks []string
+	options = append(options, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	}))
+	response, err := newAnthropicTestModel(t, server.URL).GenerateContent(context.Background(), messages, options...)
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	var request map[string]any
+	if err = json.Unmarshal(server.body, &request); err != nil || request["stream"] != true {
+		t.Fatalf("request %s is not streamed", server.body)
+	}
+	// only the text is streamed, the thinking and the tool input are not
+	if !reflect.DeepEqual(chunks, []string{"Reading ", "the library."}) {
+		t.Fatalf("streamed %q", chunks)
+	}
+	assertAnthropicAnswer(t, response)
+}
+
+func TestAnthropicReportsTruncatedStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":3}}}\n\n"))
+	}))
+	defer server.Close()
+
+	_, err := newAnthropicTestModel(t, server.URL).GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "question")},
+		llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error { return nil }))
+	if err == nil || !strings.Contains(err.Error(), "message_stop") {
+		t.Fatalf("got error %v, want the stream reported as cut", err)
+	}
+}
+
+func TestAnthropicReportsServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"Number of requests has exceeded your rate limit"}}`))
+	}))
+	defer server.Close()
+
+	_, err := newAnthropicTestModel(t, server.URL).GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "question")})
+	if err == nil || !strings.Contains(err.Error(), "429") || !strings.Contains(err.Error(), "rate_limit_error") {
+		t.Fatalf("got error %v, want the status and type of the error", err)
+	}
+}
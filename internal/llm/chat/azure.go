@@ -0,0 +1,68 @@
+package chat
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// defaultAzureAPIVersion Azure OpenAI 默认的 api-version
+const defaultAzureAPIVersion = "2024-10-21"
+
+// AzureOpenAIProvider Azure OpenAI 提供商实现
+type AzureOpenAIProvider struct {
+	model  llms.Model
+	config *ProviderConfig
+}
+
+// NewAzureOpenAIProvider 创建一个新的 Azure OpenAI 提供商，Model 为部署名称，BaseURL 为资源的 endpoint
+func NewAzureOpenAIProvider(config *ProviderConfig) (Provider, error) {
+	apiKey := config.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("AZURE_OPENAI_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("未提供 Azure OpenAI API 密钥")
+		}
+	}
+
+	endpoint := config.BaseURL
+	if endpoint == "" {
+		endpoint = os.Getenv("AZURE_OPENAI_ENDPOINT")
+		if endpoint == "" {
+			return nil, fmt.Errorf("未提供 Azure OpenAI endpoint")
+		}
+	}
+
+	apiVersion := config.APIVersion
+	if apiVersion == "" {
+		apiVersion = defaultAzureAPIVersion
+	}
+
+	// 请求发往 {endpoint}/openai/deployments/{model}/chat/completions?api-version=，密钥放在 api-key 头中
+	m, err := openai.New(
+		openai.WithAPIType(openai.APITypeAzure),
+		openai.WithToken(apiKey),
+		openai.WithBaseURL(endpoint),
+		openai.WithModel(config.Model),
+		openai.WithAPIVersion(apiVersion),
+		openai.WithHTTPClient(httpClient),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Azure OpenAI LLM 失败: %w", err)
+	}
+
+	return &AzureOpenAIProvider{
+		model:  m,
+		config: config,
+	}, nil
+}
+
+func (p *AzureOpenAIProvider) HandleResponse(response llms.ContentResponse) {
+
+}
+
+func (p *AzureOpenAIProvider) GetModel() llms.Model {
+	return p.model
+}
@@ -0,0 +1,73 @@
+package chat
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestAzureDeploymentURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		endpoint    string // appended to the url of the server
+		envEndpoint bool   // pass the endpoint by AZURE_OPENAI_ENDPOINT
+		apiVersion  string
+		wantVersion string
+	}{
+		{"default api version", "", false, "", defaultAzureAPIVersion},
+		{"configured api version", "", false, "2025-01-01-preview", "2025-01-01-preview"},
+		{"endpoint with trailing slash", "/", false, "", defaultAzureAPIVersion},
+		{"endpoint from the environment", "", true, "", defaultAzureAPIVersion},
+	}
+	response := readFixture(t, "azure/chat.response.json")
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var path, version, apiKey, authorization string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				version = r.URL.Query().Get("api-version")
+				apiKey = r.Header.Get("api-key")
+				authorization = r.Header.Get("Authorization")
+				w.Header().Set("Content-Type", "application/json")
+				w.Write(response)
+			}))
+			defer server.Close()
+
+			config := &ProviderConfig{APIKey: "test-key", Model: "docs-gpt4o", APIVersion: test.apiVersion}
+			if test.envEndpoint {
+				t.Setenv("AZURE_OPENAI_ENDPOINT", server.URL+test.endpoint)
+			} else {
+				config.BaseURL = server.URL + test.endpoint
+			}
+			provider, err := NewAzureOpenAIProvider(config)
+			if err != nil {
+				t.Fatalf("create provider failed: %v", err)
+			}
+
+			result, err := provider.GetModel().GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "What does the repository do?")})
+			if err != nil {
+				t.Fatalf("GenerateContent failed: %v", err)
+			}
+			// the model is the name of the deployment
+			if path != "/openai/deployments/docs-gpt4o/chat/completions" || version != test.wantVersion {
+				t.Fatalf("sent to %s?api-version=%s", path, version)
+			}
+			if apiKey != "test-key" || len(authorization) > 0 {
+				t.Fatalf("sent api-key %q and Authorization %q, want the key in api-key only", apiKey, authorization)
+			}
+			if result.Choices[0].Content != "The repository greets its users." {
+				t.Fatalf("got content %q", result.Choices[0].Content)
+			}
+		})
+	}
+}
+
+func TestAzureRequiresEndpoint(t *testing.T) {
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "")
+	if _, err := NewAzureOpenAIProvider(&ProviderConfig{APIKey: "test-key", Model: "docs-gpt4o"}); err == nil {
+		t.Fatal("created a provider without an endpoint")
+	}
+}
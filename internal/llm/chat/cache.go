@@ -0,0 +1,107 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+	"go.uber.org/zap"
+)
+
+// Cache stores the responses of requests, Get reports false for missing and expired entries.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Put(key string, value []byte) error
+}
+
+// CachedProvider answers requests that were answered before from the cache. Requests are keyed
+// by the model, the messages including the tool calls and results, and the tools.
+type CachedProvider struct {
+	model    string
+	cache    Cache
+	provider Provider
+}
+
+// NewCachedProvider wraps the provider, model tells apart the answers of different models and servers.
+func NewCachedProvider(model string, cache Cache, provider Provider) Provider {
+	return &CachedProvider{
+		model:    model,
+		cache:    cache,
+		provider: provider,
+	}
+}
+
+func (p *CachedProvider) HandleResponse(response llms.ContentResponse) {
+
+}
+
+func (p *CachedProvider) GetModel() llms.Model {
+	return p
+}
+
+// Call implements llms.Model.
+func (p *CachedProvider) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, p, prompt, options...)
+}
+
+// GenerateContent implements llms.Model.
+func (p *CachedProvider) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	opts := llms.CallOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	key, err := requestKey(p.model, messages, opts.Tools)
+	if err != nil {
+		return p.provider.GetModel().GenerateContent(ctx, messages, options...)
+	}
+
+	if data, ok := p.cache.Get(key); ok {
+		var choices []savedChoice
+		if err = json.Unmarshal(data, &choices); err == nil && len(choices) > 0 {
+			response := restoreChoices(choices)
+			for _, choice := range response.Choices {
+				if choice.GenerationInfo == nil {
+					choice.GenerationInfo = make(map[string]any)
+				}
+				choice.GenerationInfo[cachedKey] = true
+			}
+			if err = streamSaved(ctx, &opts, response); err != nil {
+				return nil, err
+			}
+			zap.L().Debug("LLM cache hit", zap.String("model", p.model), zap.String("key", key))
+			return response, nil
+		}
+	}
+
+	response, err := p.provider.GetModel().GenerateContent(ctx, messages, options...)
+	if err != nil {
+		return nil, err
+	}
+	if !cacheable(response) {
+		return response, nil
+	}
+
+	data, err := json.Marshal(saveChoices(response))
+	if err == nil {
+		err = p.cache.Put(key, data)
+	}
+	if err != nil {
+		zap.L().Warn("cannot cache llm response", zap.String("model", p.model), zap.Error(err))
+	}
+	return response, nil
+}
+
+// cacheable reports whether a response is complete, a truncated answer would fail every rerun.
+func cacheable(response *llms.ContentResponse) bool {
+	if len(response.Choices) == 0 {
+		return false
+	}
+	choice := response.Choices[0]
+	switch strings.ToLower(choice.StopReason) {
+	case "length", "max_tokens":
+		return false
+	}
+	return len(choice.Content) > 0 || len(choice.ToolCalls) > 0
+}
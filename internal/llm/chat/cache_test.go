@@ -0,0 +1,173 @@
+package chat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// memoryCache is a Cache without expiry.
+type memoryCache map[string][]byte
+
+func (c memoryCache) Get(key string) ([]byte, bool) {
+	value, ok := c[key]
+	return value, ok
+}
+
+func (c memoryCache) Put(key string, value []byte) error {
+	c[key] = value
+	return nil
+}
+
+// answeringModel answers every request with response and counts the requests.
+type answeringModel struct {
+	response *llms.ContentResponse
+	calls    int
+}
+
+func (m *answeringModel) HandleResponse(response llms.ContentResponse) {}
+
+func (m *answeringModel) GetModel() llms.Model { return m }
+
+func (m *answeringModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+func (m *answeringModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.calls++
+	return m.response, nil
+}
+
+var readFilesTool = llms.Tool{Type: "function", Function: &llms.FunctionDefinition{Name: "readFiles", Description: "Read files"}}
+
+func TestRequestKey(t *testing.T) {
+	question := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "Document the repository.")}
+	toolTurn := func(arguments string) []llms.MessageContent {
+		return append(question, llms.MessageContent{Role: llms.ChatMessageTypeAI, Parts: []llms.ContentPart{
+			llms.ToolCall{ID: "call_0", Type: "function", FunctionCall: &llms.FunctionCall{Name: "readFiles", Arguments: arguments}},
+		}})
+	}
+	base, err := requestKey("model", question, []llms.Tool{readFilesTool})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		model    string
+		messages []llms.MessageContent
+		tools    []llms.Tool
+		same     bool
+	}{
+		{"identical request", "model", []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "Document the repository.")}, []llms.Tool{readFilesTool}, true},
+		{"other model", "other", question, []llms.Tool{readFilesTool}, false},
+		{"other message", "model", []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "Document the library.")}, []llms.Tool{readFilesTool}, false},
+		{"other role", "model", []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeSystem, "Document the repository.")}, []llms.Tool{readFilesTool}, false},
+		{"no tools", "model", question, nil, false},
+		{"other tool", "model", question, []llms.Tool{{Type: "function", Function: &llms.FunctionDefinition{Name: "listFiles"}}}, false},
+		{"tool call", "model", toolTurn(`{"paths":["a.go"]}`), []llms.Tool{readFilesTool}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := requestKey(test.model, test.messages, test.tools)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (key == base) != test.same {
+				t.Fatalf("key %s, base %s, want same %v", key, base, test.same)
+			}
+		})
+	}
+
+	// the arguments of a tool call are part of the key
+	first, _ := requestKey("model", toolTurn(`{"paths":["a.go"]}`), nil)
+	second, _ := requestKey("model", toolTurn(`{"paths":["b.go"]}`), nil)
+	if first == second {
+		t.Fatal("tool calls with other arguments have the same key")
+	}
+}
+
+func TestCachedProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		choice    *llms.ContentChoice
+		cacheable bool
+	}{
+		{"answer", &llms.ContentChoice{Content: "The summary.", StopReason: "stop"}, true},
+		{"tool calls", &llms.ContentChoice{StopReason: "tool_calls", ToolCalls: []llms.ToolCall{
+			{ID: "call_0", Type: "function", FunctionCall: &llms.FunctionCall{Name: "readFiles", Arguments: `{"paths":["a.go"]}`}},
+		}}, true},
+		{"cut by the token limit", &llms.ContentChoice{Content: "The sum", StopReason: "length"}, false},
+		{"cut by max_tokens", &llms.ContentChoice{Content: "The sum", StopReason: "max_tokens"}, false},
+		{"empty answer", &llms.ContentChoice{StopReason: "stop"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			model := &answeringModel{response: &llms.ContentResponse{Choices: []*llms.ContentChoice{test.choice}}}
+			cache := make(memoryCache)
+			provider := NewCachedProvider("model", cache, model).GetModel()
+			messages := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "Document the repository.")}
+
+			_, err := provider.GenerateContent(context.Background(), messages, llms.WithTools([]llms.Tool{readFilesTool}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.cacheable != (len(cache) == 1) {
+				t.Fatalf("%d cache entries, want cached %v", len(cache), test.cacheable)
+			}
+
+			var streamed string
+			second, err := provider.GenerateContent(context.Background(), messages, llms.WithTools([]llms.Tool{readFilesTool}),
+				llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+					streamed += string(chunk)
+					return nil
+				}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.cacheable {
+				if model.calls != 2 {
+					t.Fatalf("%d requests, want the model asked again", model.calls)
+				}
+				return
+			}
+			if model.calls != 1 {
+				t.Fatalf("%d requests, want the second answered by the cache", model.calls)
+			}
+			choice := second.Choices[0]
+			if choice.Content != test.choice.Content || streamed != test.choice.Content || len(choice.ToolCalls) != len(test.choice.ToolCalls) {
+				t.Fatalf("got content %q, streamed %q and %d tool calls", choice.Content, streamed, len(choice.ToolCalls))
+			}
+			if len(choice.ToolCalls) > 0 && choice.ToolCalls[0].FunctionCall.Arguments != test.choice.ToolCalls[0].FunctionCall.Arguments {
+				t.Fatalf("got tool call %+v", choice.ToolCalls[0].FunctionCall)
+			}
+
+			// another request is not answered by the cache
+			other := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "Document the library.")}
+			if _, err = provider.GenerateContent(context.Background(), other, llms.WithTools([]llms.Tool{readFilesTool})); err != nil {
+				t.Fatal(err)
+			}
+			if model.calls != 2 {
+				t.Fatalf("%d requests, want the other request sent", model.calls)
+			}
+		})
+	}
+}
+
+func TestCachedProviderMarksHits(t *testing.T) {
+	model := &answeringModel{response: &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: "The summary.", StopReason: "stop"}}}}
+	provider := NewCachedProvider("model", make(memoryCache), model).GetModel()
+	messages := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "Document the repository.")}
+
+	// cached answers cost nothing, the meter tells them apart by the flag
+	for _, want := range []bool{false, true} {
+		response, err := provider.GenerateContent(context.Background(), messages)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isCached(response.Choices[0]) != want {
+			t.Fatalf("answer marked cached %v, want %v", isCached(response.Choices[0]), want)
+		}
+	}
+}
@@ -0,0 +1,536 @@
+package chat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// noThinkSwitch is the soft switch the prompts start with to turn off the thinking of hybrid models.
+const noThinkSwitch = "/no_think"
+
+// compatibleOptions describes the quirks of an OpenAI compatible backend.
+type compatibleOptions struct {
+	baseURL        string
+	apiKey         string // optional, local servers usually run without a key
+	model          string
+	maxTokens      int
+	temperature    float64
+	stripNoThink   bool // the backend does not understand the soft switch, remove it from the prompts
+	templateKwargs bool // pass the switch as chat_template_kwargs.enable_thinking as well
+	promptThink    bool // the chat template may open the <think> block in the prompt, the answer then only closes it
+}
+
+// compatibleModel talks to the chat completions api of DeepSeek, vLLM and llama.cpp directly.
+// Unlike the generic OpenAI client it keeps the reasoning out of the answer, assembles
+// streamed tool calls by their index and does not require an api key.
+type compatibleModel struct {
+	options compatibleOptions
+}
+
+type compatibleToolCall struct {
+	Index    *int   `json:"index,omitempty"` // only set in streamed deltas
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type compatibleMessage struct {
+	Role       string               `json:"role"`
+	Content    string               `json:"content"`
+	ToolCalls  []compatibleToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string               `json:"tool_call_id,omitempty"`
+}
+
+type compatibleRequest struct {
+	Model              string              `json:"model"`
+	Messages           []compatibleMessage `json:"messages"`
+	MaxTokens          int                 `json:"max_tokens,omitempty"`
+	Temperature        *float64            `json:"temperature,omitempty"`
+	Stop               []string            `json:"stop,omitempty"`
+	Tools              []llms.Tool         `json:"tools,omitempty"`
+	Stream             bool                `json:"stream,omitempty"`
+	StreamOptions      map[string]any      `json:"stream_options,omitempty"`
+	ChatTemplateKwargs map[string]any      `json:"chat_template_kwargs,omitempty"`
+
+	noThink bool // the prompt turned the thinking off
+}
+
+type compatibleResponseMessage struct {
+	Content          string               `json:"content"`
+	ReasoningContent string               `json:"reasoning_content"`
+	Reasoning        string               `json:"reasoning"` // newer vLLM releases
+	ToolCalls        []compatibleToolCall `json:"tool_calls"`
+}
+
+type compatibleResponse struct {
+	Choices []struct {
+		Message      compatibleResponseMessage `json:"message"`
+		Delta        compatibleResponseMessage `json:"delta"`
+		FinishReason string                    `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Call implements llms.Model.
+func (m *compatibleModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// GenerateContent implements llms.Model.
+func (m *compatibleModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	opts := llms.CallOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	request, err := m.buildRequest(messages, &opts)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(m.options.baseURL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	if len(m.options.apiKey) > 0 {
+		httpRequest.Header.Set("Authorization", "Bearer "+m.options.apiKey)
+	}
+
+	httpResponse, err := httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(httpResponse.Body, 4096))
+		var errResponse compatibleResponse
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &errResponse) == nil && errResponse.Error != nil {
+			message = errResponse.Error.Message
+		}
+		return nil, fmt.Errorf("API returned unexpected status code: %d: %s", httpResponse.StatusCode, message)
+	}
+
+	if request.Stream {
+		// a model asked not to think answers right away, there is nothing to hold back
+		return m.readStream(ctx, httpResponse.Body, opts.StreamingFunc, m.options.promptThink && !request.noThink)
+	}
+
+	var response compatibleResponse
+	if err = json.NewDecoder(httpResponse.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decode response failed: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return nil, fmt.Errorf("empty response")
+	}
+
+	choice := response.Choices[0]
+	var assembler toolCallAssembler
+	assembler.add(choice.Message.ToolCalls)
+	result := newContentChoice(choice.Message.Content, choice.Message.ReasoningContent+choice.Message.Reasoning, choice.FinishReason, assembler.calls())
+	if response.Usage != nil {
+		setUsage(result, response.Usage.PromptTokens, response.Usage.CompletionTokens, response.Usage.TotalTokens)
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{result}}, nil
+}
+
+func (m *compatibleModel) buildRequest(messages []llms.MessageContent, opts *llms.CallOptions) (*compatibleRequest, error) {
+	request := &compatibleRequest{
+		Model:     m.options.model,
+		MaxTokens: m.options.maxTokens,
+		Stop:      opts.StopWords,
+		Tools:     opts.Tools,
+	}
+	if len(opts.Model) > 0 {
+		request.Model = opts.Model
+	}
+	if opts.MaxTokens > 0 {
+		request.MaxTokens = opts.MaxTokens
+	}
+	temperature := m.options.temperature
+	if opts.Temperature > 0 {
+		temperature = opts.Temperature
+	}
+	if temperature > 0 {
+		request.Temperature = &temperature
+	}
+	if opts.StreamingFunc != nil {
+		request.Stream = true
+		request.StreamOptions = map[string]any{"include_usage": true}
+	}
+
+	var noThink bool
+	for _, message := range messages {
+		converted, err := convertMessage(message)
+		if err != nil {
+			return nil, err
+		}
+		for idx := range converted {
+			if strings.Contains(converted[idx].Content, noThinkSwitch) {
+				noThink = true
+				if m.options.stripNoThink {
+					converted[idx].Content = strings.TrimSpace(strings.ReplaceAll(converted[idx].Content, noThinkSwitch, ""))
+				}
+			}
+		}
+		request.Messages = append(request.Messages, converted...)
+	}
+	request.noThink = noThink
+	if noThink && m.options.templateKwargs {
+		request.ChatTemplateKwargs = map[string]any{"enable_thinking": false}
+	}
+	return request, nil
+}
+
+// convertMessage converts a message, every tool response becomes a message of its own.
+func convertMessage(message llms.MessageContent) ([]compatibleMessage, error) {
+	var role string
+	switch message.Role {
+	case llms.ChatMessageTypeSystem:
+		role = "system"
+	case llms.ChatMessageTypeHuman, llms.ChatMessageTypeGeneric:
+		role = "user"
+	case llms.ChatMessageTypeAI:
+		role = "assistant"
+	case llms.ChatMessageTypeTool:
+		role = "tool"
+	default:
+		return nil, fmt.Errorf("unsupported message role: %s", message.Role)
+	}
+
+	var result = []compatibleMessage{{Role: role}}
+	var content strings.Builder
+	for _, part := range message.Parts {
+		switch p := part.(type) {
+		case llms.TextContent:
+			content.WriteString(p.Text)
+		case llms.ToolCall:
+			call := compatibleToolCall{ID: p.ID, Type: "function"}
+			if p.FunctionCall != nil {
+				call.Function.Name = p.FunctionCall.Name
+				call.Function.Arguments = p.FunctionCall.Arguments
+			}
+			result[0].ToolCalls = append(result[0].ToolCalls, call)
+		case llms.ToolCallResponse:
+			result = append(result, compatibleMessage{Role: "tool", Content: p.Content, ToolCallID: p.ToolCallID})
+		default:
+			return nil, fmt.Errorf("unsupported message part: %T", part)
+		}
+	}
+	result[0].Content = content.String()
+
+	// a tool message only carries its responses
+	if role == "tool" {
+		return result[1:], nil
+	}
+	return result, nil
+}
+
+// readStream reads the server sent events, only the answer is passed to the streaming func.
+// hold holds back the start of the content that may be reasoning opened by the template.
+func (m *compatibleModel) readStream(ctx context.Context, body io.Reader, streamingFunc func(ctx context.Context, chunk []byte) error, hold bool) (*llms.ContentResponse, error) {
+	var content, reasoning strings.Builder
+	var finishReason string
+	var assembler toolCallAssembler
+	var filter = thinkFilter{holding: hold}
+	var usage *compatibleResponse
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk compatibleResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("decode stream chunk failed: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("stream error: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			usage = &chunk
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		choice := chunk.Choices[0]
+		reasoning.WriteString(choice.Delta.ReasoningContent + choice.Delta.Reasoning)
+		content.WriteString(choice.Delta.Content)
+		assembler.add(choice.Delta.ToolCalls)
+		if len(choice.FinishReason) > 0 {
+			finishReason = choice.FinishReason
+		}
+
+		visible := filter.write(choice.Delta.Content)
+		if len(choice.Delta.ReasoningContent+choice.Delta.Reasoning) > 0 && filter.holding {
+			// the server separates the reasoning, the content is the answer
+			visible += filter.release()
+		}
+		if err := stream(ctx, streamingFunc, visible); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := stream(ctx, streamingFunc, filter.flush()); err != nil {
+		return nil, err
+	}
+
+	result := newContentChoice(content.String(), reasoning.String(), finishReason, assembler.calls())
+	if usage != nil {
+		setUsage(result, usage.Usage.PromptTokens, usage.Usage.CompletionTokens, usage.Usage.TotalTokens)
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{result}}, nil
+}
+
+func stream(ctx context.Context, streamingFunc func(ctx context.Context, chunk []byte) error, visible string) error {
+	if len(visible) == 0 || streamingFunc == nil {
+		return nil
+	}
+	if err := streamingFunc(ctx, []byte(visible)); err != nil {
+		return fmt.Errorf("streaming func returned an error: %w", err)
+	}
+	return nil
+}
+
+func newContentChoice(content, reasoning, finishReason string, toolCalls []llms.ToolCall) *llms.ContentChoice {
+	answer, thinking := splitThink(content)
+	if len(reasoning) > 0 && len(thinking) > 0 {
+		reasoning += "\n"
+	}
+	choice := &llms.ContentChoice{
+		Content:          answer,
+		StopReason:       finishReason,
+		ToolCalls:        toolCalls,
+		ReasoningContent: reasoning + thinking,
+		GenerationInfo:   map[string]any{},
+	}
+	if len(toolCalls) > 0 {
+		choice.FuncCall = toolCalls[0].FunctionCall
+	}
+	return choice
+}
+
+// setUsage reports the token usage with the keys of the langchaingo openai client.
+func setUsage(choice *llms.ContentChoice, promptTokens, completionTokens, totalTokens int) {
+	choice.GenerationInfo["PromptTokens"] = promptTokens
+	choice.GenerationInfo["CompletionTokens"] = completionTokens
+	choice.GenerationInfo["TotalTokens"] = totalTokens
+}
+
+// toolCallAssembler joins the fragments of streamed tool calls. Fragments are matched by
+// their index, servers without an index continue the last call until a new id starts one.
+type toolCallAssembler struct {
+	order []int
+	byIdx map[int]*compatibleToolCall
+}
+
+func (a *toolCallAssembler) add(deltas []compatibleToolCall) {
+	if a.byIdx == nil {
+		a.byIdx = make(map[int]*compatibleToolCall)
+	}
+	for _, delta := range deltas {
+		var idx int
+		switch {
+		case delta.Index != nil:
+			idx = *delta.Index
+		case len(delta.ID) > 0 || len(a.order) == 0:
+			idx = len(a.order)
+		default:
+			idx = a.order[len(a.order)-1]
+		}
+
+		call, ok := a.byIdx[idx]
+		if !ok {
+			call = &compatibleToolCall{}
+			a.byIdx[idx] = call
+			a.order = append(a.order, idx)
+		}
+		if len(delta.ID) > 0 {
+			call.ID = delta.ID
+		}
+		if len(delta.Type) > 0 {
+			call.Type = delta.Type
+		}
+		// some servers repeat the whole name in every fragment
+		if delta.Function.Name != call.Function.Name {
+			call.Function.Name += delta.Function.Name
+		}
+		call.Function.Arguments += delta.Function.Arguments
+	}
+}
+
+func (a *toolCallAssembler) calls() []llms.ToolCall {
+	var result []llms.ToolCall
+	for n, idx := range a.order {
+		call := a.byIdx[idx]
+		if len(call.Function.Name) == 0 {
+			continue
+		}
+		// llama.cpp may omit the id, the tool responses are matched by it
+		id := call.ID
+		if len(id) == 0 {
+			id = fmt.Sprintf("call_%d", n)
+		}
+		arguments := call.Function.Arguments
+		if len(strings.TrimSpace(arguments)) == 0 {
+			arguments = "{}"
+		}
+		result = append(result, llms.ToolCall{
+			ID:   id,
+			Type: "function",
+			FunctionCall: &llms.FunctionCall{
+				Name:      call.Function.Name,
+				Arguments: arguments,
+			},
+		})
+	}
+	return result
+}
+
+// splitThink separates the <think> blocks of models that reason inside the answer.
+// Templates that open the block in the prompt only leave the closing tag in the answer.
+func splitThink(content string) (string, string) {
+	var answer, thinking strings.Builder
+	for {
+		end := strings.Index(content, "</think>")
+		if end < 0 {
+			break
+		}
+		start := strings.Index(content[:end], "<think>")
+		if start < 0 {
+			// the opening tag was part of the prompt
+			thinking.WriteString(content[:end])
+		} else {
+			answer.WriteString(content[:start])
+			thinking.WriteString(content[start+len("<think>") : end])
+		}
+		content = content[end+len("</think>"):]
+	}
+
+	// an unterminated block was cut by the token limit
+	if start := strings.Index(content, "<think>"); start >= 0 {
+		thinking.WriteString(content[start+len("<think>"):])
+		content = content[:start]
+	}
+	answer.WriteString(content)
+	return strings.TrimSpace(answer.String()), strings.TrimSpace(thinking.String())
+}
+
+// maxHeldContent is the content held back without a tag before it is taken for the answer,
+// models that never reason would otherwise not stream at all.
+const maxHeldContent = 512
+
+// thinkFilter drops <think> blocks from streamed content, a tag may be split across chunks.
+// When the template may have opened the block in the prompt the content is held back until
+// a tag tells whether it starts with reasoning, the stream ends or maxHeldContent is exceeded.
+type thinkFilter struct {
+	inThink bool
+	pending string
+	holding bool
+	held    string
+}
+
+func (f *thinkFilter) write(chunk string) string {
+	if f.holding {
+		f.held += chunk
+		end := strings.Index(f.held, "</think>")
+		if end >= 0 && !strings.Contains(f.held[:end], "<think>") {
+			// the block was opened in the prompt, everything before the closing tag is reasoning
+			f.held = f.held[end+len("</think>"):]
+			return f.release()
+		}
+		if strings.Contains(f.held, "<think>") || len(f.held) > maxHeldContent {
+			return f.release()
+		}
+		return ""
+	}
+
+	s := f.pending + chunk
+	f.pending = ""
+
+	var visible strings.Builder
+	for len(s) > 0 {
+		tag := "<think>"
+		if f.inThink {
+			tag = "</think>"
+		}
+		if idx := strings.Index(s, tag); idx >= 0 {
+			if !f.inThink {
+				visible.WriteString(s[:idx])
+			}
+			s = s[idx+len(tag):]
+			f.inThink = !f.inThink
+			continue
+		}
+
+		// keep a partial tag for the next chunk
+		keep := 0
+		for n := len(tag) - 1; n > 0; n-- {
+			if strings.HasSuffix(s, tag[:n]) {
+				keep = n
+				break
+			}
+		}
+		if !f.inThink {
+			visible.WriteString(s[:len(s)-keep])
+		}
+		f.pending = s[len(s)-keep:]
+		break
+	}
+	return visible.String()
+}
+
+// release stops holding back the content and returns its visible part.
+func (f *thinkFilter) release() string {
+	held := f.held
+	f.holding, f.held = false, ""
+	return f.write(held)
+}
+
+// flush returns the content still held back at the end of the stream.
+func (f *thinkFilter) flush() string {
+	var visible string
+	if f.holding {
+		visible = f.release()
+	}
+	// a partial tag that was never completed is text
+	if !f.inThink {
+		visible += f.pending
+	}
+	f.pending = ""
+	return visible
+}
@@ -0,0 +1,444 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// compatibleServer answers every chat completion with body and keeps the last request.
+type compatibleServer struct {
+	*httptest.Server
+	header  http.Header
+	request compatibleRequest
+}
+
+func newCompatibleServer(t *testing.T, body string) *compatibleServer {
+	server := &compatibleServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		server.header = r.Header.Clone()
+		if err := json.NewDecoder(r.Body).Decode(&server.request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if server.request.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// sseBody encodes the chunks as server sent events.
+func sseBody(chunks ...string) string {
+	var body strings.Builder
+	for _, chunk := range chunks {
+		body.WriteString("data: " + chunk + "\n\n")
+	}
+	body.WriteString("data: [DONE]\n\n")
+	return body.String()
+}
+
+func newCompatibleProvider(t *testing.T, constructor func(*ProviderConfig) (Provider, error), baseURL, apiKey string) llms.Model {
+	provider, err := constructor(&ProviderConfig{BaseURL: baseURL + "/v1", APIKey: apiKey, Model: "test-model", MaxTokens: 256})
+	if err != nil {
+		t.Fatalf("create provider failed: %v", err)
+	}
+	return provider.GetModel()
+}
+
+func TestCompatibleKeepsReasoningOutOfContent(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"reasoning_content", `{"choices":[{"message":{"content":"The answer.","reasoning_content":"Let me think."},"finish_reason":"stop"}]}`},
+		{"reasoning", `{"choices":[{"message":{"content":"The answer.","reasoning":"Let me think."},"finish_reason":"stop"}]}`},
+		{"inline think", `{"choices":[{"message":{"content":"<think>\nLet me think.\n</think>\n\nThe answer."},"finish_reason":"stop"}]}`},
+		{"opened by the template", `{"choices":[{"message":{"content":"Let me think.\n</think>\n\nThe answer."},"finish_reason":"stop"}]}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newCompatibleServer(t, test.body)
+			model := newCompatibleProvider(t, NewDeepSeekProvider, server.URL, "test-key")
+
+			response, err := model.GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "question")})
+			if err != nil {
+				t.Fatalf("GenerateContent failed: %v", err)
+			}
+			choice := response.Choices[0]
+			if choice.Content != "The answer." || choice.ReasoningContent != "Let me think." {
+				t.Fatalf("got content %q and reasoning %q", choice.Content, choice.ReasoningContent)
+			}
+		})
+	}
+}
+
+func TestCompatibleStreamsOnlyTheAnswer(t *testing.T) {
+	server := newCompatibleServer(t, sseBody(
+		`{"choices":[{"delta":{"reasoning_content":"Plan "}}]}`,
+		`{"choices":[{"delta":{"content":"<thi"}}]}`,
+		`{"choices":[{"delta":{"content":"nk>more plan</th"}}]}`,
+		`{"choices":[{"delta":{"content":"ink>Hel"}}]}`,
+		`{"choices":[{"delta":{"content":"lo <"}}]}`,
+		`{"choices":[{"delta":{"content":"b>world</b>"},"finish_reason":"stop"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":7,"total_tokens":19}}`,
+	))
+	model := newCompatibleProvider(t, NewVLLMProvider, server.URL, "")
+
+	var streamed strings.Builder
+	response, err := model.GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "question")},
+		llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+			streamed.Write(chunk)
+			return nil
+		}))
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if streamed.String() != "Hello <b>world</b>" {
+		t.Fatalf("streamed %q, want only the answer", streamed.String())
+	}
+	choice := response.Choices[0]
+	if choice.Content != "Hello <b>world</b>" || choice.ReasoningContent != "Plan \nmore plan" {
+		t.Fatalf("got content %q and reasoning %q", choice.Content, choice.ReasoningContent)
+	}
+	if choice.StopReason != "stop" || choice.GenerationInfo["TotalTokens"] != 19 {
+		t.Fatalf("got stop reason %q and generation info %v", choice.StopReason, choice.GenerationInfo)
+	}
+	if server.request.StreamOptions["include_usage"] != true {
+		t.Fatalf("stream options %v do not include the usage", server.request.StreamOptions)
+	}
+}
+
+func TestThinkFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		holding bool // the template may open the block in the prompt
+		chunks  []string
+		want    string
+	}{
+		{"whole tags", false, []string{"<think>plan</think>answer"}, "answer"},
+		{"split opening tag", false, []string{"<", "thi", "nk>plan</think>answer"}, "answer"},
+		{"split closing tag", false, []string{"<think>plan<", "/", "think", ">answer"}, "answer"},
+		{"text before the block", false, []string{"intro <th", "ink>plan</think> answer"}, "intro  answer"},
+		{"tag like text", false, []string{"a <", "b> c"}, "a <b> c"},
+		{"partial tag at the end", false, []string{"a <", "thi"}, "a <thi"},
+		{"unterminated block", false, []string{"answer<think>cut by the token li", "mit"}, "answer"},
+		{"opened in the prompt", true, []string{"plan ", "more plan</th", "ink>ans", "wer"}, "answer"},
+		{"opened in the answer", true, []string{"<thi", "nk>plan</think>", "answer"}, "answer"},
+		{"no reasoning", true, []string{"just ", "the answer"}, "just the answer"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var filter = thinkFilter{holding: test.holding}
+			var visible strings.Builder
+			for _, chunk := range test.chunks {
+				visible.WriteString(filter.write(chunk))
+			}
+			visible.WriteString(filter.flush())
+			if visible.String() != test.want {
+				t.Fatalf("got %q, want %q", visible.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestThinkFilterReleasesLongContentWithoutTag(t *testing.T) {
+	var filter = thinkFilter{holding: true}
+	if visible := filter.write("The answer "); visible != "" {
+		t.Fatalf("got %q before the limit, want it held back", visible)
+	}
+	long := strings.Repeat("x", maxHeldContent)
+	if visible := filter.write(long); visible != "The answer "+long {
+		t.Fatalf("got %q, want the held content once it exceeds the limit", visible)
+	}
+	if visible := filter.write(" more"); visible != " more" {
+		t.Fatalf("got %q after the release, want it streamed", visible)
+	}
+}
+
+func TestCompatibleStreamsBeforeDoneWithoutThinking(t *testing.T) {
+	// the server only finishes the stream once the client received the first chunk
+	var received chan struct{}
+	var early = make(chan bool, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(`data: {"choices":[{"delta":{"content":"The summary."}}]}` + "\n\n"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-received:
+			early <- true
+		case <-time.After(2 * time.Second):
+			early <- false
+		}
+		w.Write([]byte(`data: {"choices":[{"delta":{},"finish_reason":"stop"}]}` + "\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	for _, constructor := range []func(*ProviderConfig) (Provider, error){NewVLLMProvider, NewLlamaCPPProvider} {
+		received = make(chan struct{})
+		model := newCompatibleProvider(t, constructor, server.URL, "")
+		var streamed bool
+		_, err := model.GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "/no_think question")},
+			llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+				if !streamed {
+					streamed = true
+					close(received)
+				}
+				return nil
+			}))
+		if err != nil {
+			t.Fatalf("GenerateContent failed: %v", err)
+		}
+		if !<-early {
+			t.Fatal("the content was held back until the end of the stream")
+		}
+	}
+}
+
+func TestCompatibleStreamsAnswerAfterPromptThink(t *testing.T) {
+	// the chat template of Qwen3 and DeepSeek-R1 opens the block, the answer only closes it
+	server := newCompatibleServer(t, sseBody(
+		`{"choices":[{"delta":{"content":"The user asks "}}]}`,
+		`{"choices":[{"delta":{"content":"for a summary.\n</th"}}]}`,
+		`{"choices":[{"delta":{"content":"ink>\n\nThe "}}]}`,
+		`{"choices":[{"delta":{"content":"summary."},"finish_reason":"stop"}]}`,
+	))
+	model := newCompatibleProvider(t, NewLlamaCPPProvider, server.URL, "")
+
+	var streamed strings.Builder
+	response, err := model.GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "question")},
+		llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+			streamed.Write(chunk)
+			return nil
+		}))
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if strings.TrimSpace(streamed.String()) != "The summary." {
+		t.Fatalf("streamed %q, want only the answer", streamed.String())
+	}
+	choice := response.Choices[0]
+	if choice.Content != "The summary." || choice.ReasoningContent != "The user asks for a summary." {
+		t.Fatalf("got content %q and reasoning %q", choice.Content, choice.ReasoningContent)
+	}
+}
+
+func TestCompatibleStreamsAnswerWithoutReasoning(t *testing.T) {
+	server := newCompatibleServer(t, sseBody(
+		`{"choices":[{"delta":{"content":"The "}}]}`,
+		`{"choices":[{"delta":{"content":"summary."},"finish_reason":"stop"}]}`,
+	))
+	model := newCompatibleProvider(t, NewVLLMProvider, server.URL, "")
+
+	var streamed strings.Builder
+	response, err := model.GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "/no_think question")},
+		llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+			streamed.Write(chunk)
+			return nil
+		}))
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if streamed.String() != "The summary." || response.Choices[0].Content != "The summary." {
+		t.Fatalf("streamed %q and got content %q", streamed.String(), response.Choices[0].Content)
+	}
+}
+
+func TestToolCallAssembler(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas string
+		want   []llms.ToolCall
+	}{
+		{
+			name: "by index",
+			deltas: `[
+				{"index":0,"id":"call_a","type":"function","function":{"name":"readFiles","arguments":""}},
+				{"index":1,"id":"call_b","type":"function","function":{"name":"listFiles","arguments":"{\"dir\":"}},
+				{"index":0,"function":{"arguments":"{\"paths\":"}},
+				{"index":1,"function":{"arguments":"\"src\"}"}},
+				{"index":0,"function":{"arguments":"[\"a.go\"]}"}}
+			]`,
+			want: []llms.ToolCall{
+				{ID: "call_a", Type: "function", FunctionCall: &llms.FunctionCall{Name: "readFiles", Arguments: `{"paths":["a.go"]}`}},
+				{ID: "call_b", Type: "function", FunctionCall: &llms.FunctionCall{Name: "listFiles", Arguments: `{"dir":"src"}`}},
+			},
+		},
+		{
+			name: "by id without index",
+			deltas: `[
+				{"id":"call_a","function":{"name":"readFiles","arguments":"{\"paths\":"}},
+				{"function":{"name":"readFiles","arguments":"[\"a.go\"]}"}},
+				{"id":"call_b","function":{"name":"listFiles","arguments":""}}
+			]`,
+			want: []llms.ToolCall{
+				{ID: "call_a", Type: "function", FunctionCall: &llms.FunctionCall{Name: "readFiles", Arguments: `{"paths":["a.go"]}`}},
+				{ID: "call_b", Type: "function", FunctionCall: &llms.FunctionCall{Name: "listFiles", Arguments: "{}"}},
+			},
+		},
+		{
+			name: "without id",
+			deltas: `[
+				{"index":0,"function":{"name":"readFiles","arguments":"{\"paths\":[]}"}},
+				{"index":1,"function":{"name":"listFiles","arguments":"{}"}}
+			]`,
+			want: []llms.ToolCall{
+				{ID: "call_0", Type: "function", FunctionCall: &llms.FunctionCall{Name: "readFiles", Arguments: `{"paths":[]}`}},
+				{ID: "call_1", Type: "function", FunctionCall: &llms.FunctionCall{Name: "listFiles", Arguments: "{}"}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var deltas []compatibleToolCall
+			if err := json.Unmarshal([]byte(test.deltas), &deltas); err != nil {
+				t.Fatal(err)
+			}
+			// the fragments arrive one per chunk
+			var assembler toolCallAssembler
+			for idx := range deltas {
+				assembler.add(deltas[idx : idx+1])
+			}
+			assertToolCalls(t, assembler.calls(), test.want)
+		})
+	}
+}
+
+func assertToolCalls(t *testing.T, got, want []llms.ToolCall) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d tool calls, want %d", len(got), len(want))
+	}
+	for idx := range want {
+		if got[idx].ID != want[idx].ID || got[idx].Type != want[idx].Type || *got[idx].FunctionCall != *want[idx].FunctionCall {
+			t.Fatalf("tool call %d is %+v %+v, want %+v %+v", idx, got[idx], got[idx].FunctionCall, want[idx], want[idx].FunctionCall)
+		}
+	}
+}
+
+func TestCompatibleStreamsToolCalls(t *testing.T) {
+	server := newCompatibleServer(t, sseBody(
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"name":"readFiles","arguments":"{\"paths\":"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"[\"a.go\"]}"}}]},"finish_reason":"tool_calls"}]}`,
+	))
+	model := newCompatibleProvider(t, NewLlamaCPPProvider, server.URL, "")
+
+	response, err := model.GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "question")},
+		llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error { return nil }))
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	choice := response.Choices[0]
+	assertToolCalls(t, choice.ToolCalls, []llms.ToolCall{
+		{ID: "call_0", Type: "function", FunctionCall: &llms.FunctionCall{Name: "readFiles", Arguments: `{"paths":["a.go"]}`}},
+	})
+	if choice.FuncCall == nil || choice.StopReason != "tool_calls" {
+		t.Fatalf("got func call %v and stop reason %q", choice.FuncCall, choice.StopReason)
+	}
+}
+
+func TestCompatibleNoThink(t *testing.T) {
+	tests := []struct {
+		name        string
+		constructor func(*ProviderConfig) (Provider, error)
+		wantPrompt  string
+		wantKwargs  bool
+	}{
+		{"deepseek strips the switch", NewDeepSeekProvider, "Summarize the repository.", false},
+		{"vllm passes the template kwargs", NewVLLMProvider, "/no_think Summarize the repository.", true},
+		{"llama.cpp passes the template kwargs", NewLlamaCPPProvider, "/no_think Summarize the repository.", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newCompatibleServer(t, `{"choices":[{"message":{"content":"done"},"finish_reason":"stop"}]}`)
+			model := newCompatibleProvider(t, test.constructor, server.URL, "test-key")
+
+			_, err := model.GenerateContent(context.Background(), []llms.MessageContent{
+				llms.TextParts(llms.ChatMessageTypeSystem, "You are a technical writer."),
+				llms.TextParts(llms.ChatMessageTypeHuman, "/no_think Summarize the repository."),
+			})
+			if err != nil {
+				t.Fatalf("GenerateContent failed: %v", err)
+			}
+			messages := server.request.Messages
+			if len(messages) != 2 || messages[1].Content != test.wantPrompt {
+				t.Fatalf("sent messages %+v, want the prompt %q", messages, test.wantPrompt)
+			}
+			kwargs := server.request.ChatTemplateKwargs
+			if test.wantKwargs != (kwargs != nil) || (test.wantKwargs && kwargs["enable_thinking"] != false) {
+				t.Fatalf("sent chat_template_kwargs %v", kwargs)
+			}
+		})
+	}
+}
+
+func TestCompatibleThinksWithoutSwitch(t *testing.T) {
+	server := newCompatibleServer(t, `{"choices":[{"message":{"content":"done"},"finish_reason":"stop"}]}`)
+	model := newCompatibleProvider(t, NewVLLMProvider, server.URL, "test-key")
+
+	if _, err := model.GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "Summarize the repository.")}); err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if server.request.ChatTemplateKwargs != nil {
+		t.Fatalf("sent chat_template_kwargs %v without the switch", server.request.ChatTemplateKwargs)
+	}
+}
+
+func TestLlamaCPPWithoutKey(t *testing.T) {
+	server := newCompatibleServer(t, `{"choices":[{"message":{"content":"done"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`)
+	model := newCompatibleProvider(t, NewLlamaCPPProvider, server.URL, "")
+
+	response, err := model.GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "question")})
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if auth := server.header.Get("Authorization"); len(auth) > 0 {
+		t.Fatalf("sent Authorization %q without a key", auth)
+	}
+	if server.request.Model != "test-model" || server.request.MaxTokens != 256 {
+		t.Fatalf("sent model %q and max tokens %d", server.request.Model, server.request.MaxTokens)
+	}
+	if choice := response.Choices[0]; choice.Content != "done" || choice.GenerationInfo["PromptTokens"] != 3 {
+		t.Fatalf("got content %q and generation info %v", choice.Content, choice.GenerationInfo)
+	}
+}
+
+func TestCompatibleSendsKey(t *testing.T) {
+	server := newCompatibleServer(t, `{"choices":[{"message":{"content":"done"},"finish_reason":"stop"}]}`)
+	model := newCompatibleProvider(t, NewVLLMProvider, server.URL, "test-key")
+
+	if _, err := model.GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "question")}); err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if auth := server.header.Get("Authorization"); auth != "Bearer test-key" {
+		t.Fatalf("sent Authorization %q", auth)
+	}
+}
+
+func TestCompatibleReportsServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"context length exceeded"}}`))
+	}))
+	defer server.Close()
+	model := newCompatibleProvider(t, NewLlamaCPPProvider, server.URL, "")
+
+	_, err := model.GenerateContent(context.Background(), []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "question")})
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "context length exceeded") {
+		t.Fatalf("got error %v, want the status and message of the server", err)
+	}
+}
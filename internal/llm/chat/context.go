@@ -0,0 +1,367 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tmc/langchaingo/llms"
+	"go.uber.org/zap"
+)
+
+// DefaultContextWindow is assumed for the models that are neither configured nor known.
+const DefaultContextWindow = 32768
+
+const (
+	contextMargin    = 256  // tokens kept free for the message framing of the providers
+	minOutputTokens  = 512  // the answer is never given less room than this
+	minPartTokens    = 512  // parts are not truncated below this
+	messageOverhead  = 4    // tokens of the role and separators of a message
+	binaryPartTokens = 1024 // images and other binary parts are counted with a fixed size
+)
+
+// ErrContextExceeded is returned when a request cannot be fitted into the context window of the model.
+var ErrContextExceeded = errors.New("request exceeds the context window")
+
+// knownWindows are the context windows of common models, matched by the prefix of the model name.
+// The more specific prefixes come first.
+var knownWindows = []struct {
+	prefix string
+	window int
+}{
+	{"gpt-4.1", 1047576},
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4", 8192},
+	{"gpt-3.5-turbo", 16385},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+	{"claude", 200000},
+	{"gemini", 1048576},
+	{"deepseek", 65536},
+	{"qwen-plus", 131072},
+	{"qwen-turbo", 131072},
+	{"qwen", 32768},
+	{"llama3.1", 131072},
+	{"llama-3.1", 131072},
+}
+
+// ContextWindow returns the configured context window of a provider, the known window of its model otherwise.
+func ContextWindow(config *ProviderConfig) int {
+	if config.ContextWindow > 0 {
+		return config.ContextWindow
+	}
+	// e.g. "Qwen/Qwen3-14B" of vLLM or "qwen3:4b" of Ollama
+	model := strings.ToLower(config.Model)
+	if idx := strings.LastIndex(model, "/"); idx >= 0 {
+		model = model[idx+1:]
+	}
+	for _, known := range knownWindows {
+		if strings.HasPrefix(model, known.prefix) {
+			return known.window
+		}
+	}
+	return DefaultContextWindow
+}
+
+// EstimateTokens counts the tokens of a text pessimistically, about three bytes of
+// ascii or one character of other scripts per token. It is fast and needs no tokenizer,
+// being too high only costs some context.
+func EstimateTokens(text string) int {
+	return (textUnits(text) + 2) / 3
+}
+
+// textUnits is the size of a text in thirds of a token.
+func textUnits(text string) int {
+	var units int
+	for _, r := range text {
+		units += runeUnits(r)
+	}
+	return units
+}
+
+func runeUnits(r rune) int {
+	if r < utf8.RuneSelf {
+		return 1
+	}
+	return 3
+}
+
+// TruncateText shortens a text to about maxTokens by cutting out its middle at line
+// boundaries, a marker tells the model how much was left out.
+func TruncateText(text string, maxTokens int) string {
+	tokens := EstimateTokens(text)
+	if tokens <= maxTokens {
+		return text
+	}
+
+	marker := func(omitted int) string {
+		return fmt.Sprintf("\n... [%d tokens truncated to fit the context window] ...\n", omitted)
+	}
+	budget := (maxTokens - EstimateTokens(marker(tokens))) * 3
+	if budget <= 0 {
+		return strings.TrimPrefix(marker(tokens), "\n")
+	}
+
+	// the beginning usually says more about a text than its end
+	headUnits := budget * 2 / 3
+	head, units := 0, 0
+	for idx, r := range text {
+		if units+runeUnits(r) > headUnits {
+			break
+		}
+		units += runeUnits(r)
+		head = idx + utf8.RuneLen(r)
+	}
+	if cut := strings.LastIndexByte(text[:head], '\n'); cut > head/2 {
+		head = cut + 1
+	}
+
+	tailUnits := budget - textUnits(text[:head])
+	tail, units := len(text), 0
+	for tail > head {
+		r, size := utf8.DecodeLastRuneInString(text[:tail])
+		if units+runeUnits(r) > tailUnits {
+			break
+		}
+		units += runeUnits(r)
+		tail -= size
+	}
+	if cut := strings.IndexByte(text[tail:], '\n'); cut >= 0 && cut < (len(text)-tail)/2 {
+		tail += cut + 1
+	}
+
+	omitted := EstimateTokens(text[head:tail])
+	return strings.TrimSuffix(text[:head], "\n") + marker(omitted) + strings.TrimPrefix(text[tail:], "\n")
+}
+
+// ContextProvider fits every request into the context window of the model: older tool
+// results are replaced by a short note, then the largest parts are truncated and at last
+// the room of the answer is reduced. A request that still does not fit is not sent.
+type ContextProvider struct {
+	window    int
+	maxTokens int // max tokens of the provider, used when a request sets none
+	provider  Provider
+}
+
+// NewContextProvider wraps the provider of a model with the given context window.
+func NewContextProvider(window, maxTokens int, provider Provider) Provider {
+	if window <= 0 {
+		window = DefaultContextWindow
+	}
+	return &ContextProvider{
+		window:    window,
+		maxTokens: maxTokens,
+		provider:  provider,
+	}
+}
+
+func (p *ContextProvider) HandleResponse(response llms.ContentResponse) {
+
+}
+
+func (p *ContextProvider) GetModel() llms.Model {
+	return p
+}
+
+// Call implements llms.Model.
+func (p *ContextProvider) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, p, prompt, options...)
+}
+
+// GenerateContent implements llms.Model.
+func (p *ContextProvider) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	opts := llms.CallOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	// the answer may take at most half of the window, the prompt needs the rest
+	reserve := opts.MaxTokens
+	if reserve <= 0 {
+		reserve = p.maxTokens
+	}
+	if reserve <= 0 {
+		reserve = p.window / 8
+	}
+	if reserve > p.window/2 {
+		reserve = p.window / 2
+	}
+
+	tools := estimateTools(opts.Tools)
+	limit := p.window - reserve - contextMargin - tools
+	before := estimateMessages(messages)
+	fitted, dropped, truncated := fitMessages(messages, limit)
+	after := estimateMessages(fitted)
+
+	if after > limit {
+		// give up some room of the answer before failing the request
+		reserve = p.window - after - contextMargin - tools
+		if reserve < minOutputTokens {
+			return nil, fmt.Errorf("%w: about %d prompt tokens, %d tokens of tools, window %d", ErrContextExceeded, after, tools, p.window)
+		}
+	}
+	if reserve < opts.MaxTokens || (opts.MaxTokens <= 0 && p.maxTokens > reserve) {
+		options = append(options, llms.WithMaxTokens(reserve))
+	}
+	if dropped > 0 || truncated > 0 {
+		zap.L().Info("Fitted llm request into the context window", zap.Int("window", p.window), zap.Int("before", before), zap.Int("after", after),
+			zap.Int("dropped_tool_results", dropped), zap.Int("truncated_parts", truncated), zap.Int("max_tokens", reserve))
+	}
+
+	return p.provider.GetModel().GenerateContent(ctx, fitted, options...)
+}
+
+// partRef addresses a part of a message.
+type partRef struct {
+	message int
+	part    int
+}
+
+// fitMessages shrinks the messages to about limit tokens. The messages of the caller are
+// not changed, it keeps its full history and every request is fitted anew.
+func fitMessages(messages []llms.MessageContent, limit int) ([]llms.MessageContent, int, int) {
+	total := estimateMessages(messages)
+	if total <= limit {
+		return messages, 0, 0
+	}
+
+	fitted := make([]llms.MessageContent, len(messages))
+	copy(fitted, messages)
+	copied := make(map[int]bool)
+	replace := func(ref partRef, part llms.ContentPart) {
+		if !copied[ref.message] {
+			parts := make([]llms.ContentPart, len(fitted[ref.message].Parts))
+			copy(parts, fitted[ref.message].Parts)
+			fitted[ref.message].Parts = parts
+			copied[ref.message] = true
+		}
+		total += estimatePart(part) - estimatePart(fitted[ref.message].Parts[ref.part])
+		fitted[ref.message].Parts[ref.part] = part
+	}
+
+	// the results of the latest tool calls are still needed, the older ones were answered already
+	latest := len(fitted)
+	for idx := len(fitted) - 1; idx >= 0; idx-- {
+		if fitted[idx].Role == llms.ChatMessageTypeAI {
+			latest = idx
+			break
+		}
+	}
+
+	var dropped int
+	for idx := 0; idx < latest && total > limit; idx++ {
+		for idy, part := range fitted[idx].Parts {
+			result, ok := part.(llms.ToolCallResponse)
+			if !ok {
+				continue
+			}
+			note := result
+			note.Content = summarizeToolResult(result)
+			if estimatePart(note) >= estimatePart(result) {
+				continue
+			}
+			replace(partRef{idx, idy}, note)
+			dropped++
+			if total <= limit {
+				break
+			}
+		}
+	}
+
+	var truncated int
+	for total > limit {
+		// cut the largest part, it loses the least in proportion
+		var (
+			largest partRef
+			size    int
+		)
+		for idx, message := range fitted {
+			for idy, part := range message.Parts {
+				if n := estimatePart(part); n > size && isText(part) {
+					largest, size = partRef{idx, idy}, n
+				}
+			}
+		}
+		if size <= minPartTokens {
+			break
+		}
+
+		target := size - (total - limit)
+		if target < minPartTokens {
+			target = minPartTokens
+		}
+		switch part := fitted[largest.message].Parts[largest.part].(type) {
+		case llms.TextContent:
+			part.Text = TruncateText(part.Text, target)
+			replace(largest, part)
+		case llms.ToolCallResponse:
+			part.Content = TruncateText(part.Content, target)
+			replace(largest, part)
+		}
+		truncated++
+	}
+	return fitted, dropped, truncated
+}
+
+// summarizeToolResult replaces an older tool result by a note, the files of a readFiles
+// result are listed so the model knows what it read and may read again.
+func summarizeToolResult(result llms.ToolCallResponse) string {
+	var files map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(result.Content), &files); err == nil && len(files) > 0 {
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Sprintf("[earlier result of %s removed to fit the context window, it contained: %s]", result.Name, strings.Join(names, ", "))
+	}
+	return fmt.Sprintf("[earlier result of %s removed to fit the context window]", result.Name)
+}
+
+func isText(part llms.ContentPart) bool {
+	switch part.(type) {
+	case llms.TextContent, llms.ToolCallResponse:
+		return true
+	}
+	return false
+}
+
+func estimateMessages(messages []llms.MessageContent) int {
+	var tokens int
+	for _, message := range messages {
+		tokens += messageOverhead
+		for _, part := range message.Parts {
+			tokens += estimatePart(part)
+		}
+	}
+	return tokens
+}
+
+func estimatePart(part llms.ContentPart) int {
+	switch p := part.(type) {
+	case llms.TextContent:
+		return EstimateTokens(p.Text)
+	case llms.ToolCall:
+		if p.FunctionCall != nil {
+			return EstimateTokens(p.ID+p.FunctionCall.Name+p.FunctionCall.Arguments) + messageOverhead
+		}
+		return EstimateTokens(p.ID)
+	case llms.ToolCallResponse:
+		return EstimateTokens(p.ToolCallID+p.Name+p.Content) + messageOverhead
+	}
+	return binaryPartTokens
+}
+
+func estimateTools(tools []llms.Tool) int {
+	if len(tools) == 0 {
+		return 0
+	}
+	data, _ := json.Marshal(tools)
+	return EstimateTokens(string(data))
+}
@@ -0,0 +1,207 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// recordingModel keeps the messages and options of the last request it received.
+type recordingModel struct {
+	messages []llms.MessageContent
+	opts     llms.CallOptions
+	calls    int
+}
+
+func (m *recordingModel) HandleResponse(response llms.ContentResponse) {}
+
+func (m *recordingModel) GetModel() llms.Model { return m }
+
+func (m *recordingModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+func (m *recordingModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.calls++
+	m.messages = messages
+	m.opts = llms.CallOptions{}
+	for _, option := range options {
+		option(&m.opts)
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: "done"}}}, nil
+}
+
+// toolHistory is a conversation of turns tool calls reading large files, then a new question.
+func toolHistory(turns int, fileSize int) []llms.MessageContent {
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, "You are a technical writer."),
+		llms.TextParts(llms.ChatMessageTypeHuman, "Document the repository."),
+	}
+	for turn := 0; turn < turns; turn++ {
+		id := fmt.Sprintf("call_%d", turn)
+		messages = append(messages,
+			llms.MessageContent{Role: llms.ChatMessageTypeAI, Parts: []llms.ContentPart{
+				llms.ToolCall{ID: id, Type: "function", FunctionCall: &llms.FunctionCall{Name: "readFiles", Arguments: fmt.Sprintf(`{"paths":["file%d.go"]}`, turn)}},
+			}},
+			llms.MessageContent{Role: llms.ChatMessageTypeTool, Parts: []llms.ContentPart{
+				llms.ToolCallResponse{ToolCallID: id, Name: "readFiles", Content: fmt.Sprintf(`{"file%d.go":%q}`, turn, strings.Repeat("x", fileSize))},
+			}},
+		)
+	}
+	return append(messages, llms.TextParts(llms.ChatMessageTypeHuman, "Now write the overview."))
+}
+
+func messageText(message llms.MessageContent) string {
+	var text strings.Builder
+	for _, part := range message.Parts {
+		switch p := part.(type) {
+		case llms.TextContent:
+			text.WriteString(p.Text)
+		case llms.ToolCallResponse:
+			text.WriteString(p.Content)
+		}
+	}
+	return text.String()
+}
+
+func TestContextProviderFitsRequests(t *testing.T) {
+	const window, maxTokens = 8192, 1024
+
+	tests := []struct {
+		name      string
+		messages  []llms.MessageContent
+		truncated bool // the newest message is too large itself and is cut
+	}{
+		{
+			name:     "fits already",
+			messages: toolHistory(2, 300),
+		},
+		{
+			name:     "long tool history",
+			messages: toolHistory(20, 3000),
+		},
+		{
+			name: "one huge message",
+			messages: []llms.MessageContent{
+				llms.TextParts(llms.ChatMessageTypeSystem, "You are a technical writer."),
+				llms.TextParts(llms.ChatMessageTypeHuman, strings.Repeat("line of the source file\n", 4000)),
+			},
+			truncated: true,
+		},
+		{
+			name: "one huge multibyte message",
+			messages: []llms.MessageContent{
+				llms.TextParts(llms.ChatMessageTypeSystem, "你是一名技术文档作者。"),
+				llms.TextParts(llms.ChatMessageTypeHuman, strings.Repeat("仓库的源代码文件🙂\n", 3000)),
+			},
+			truncated: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			model := &recordingModel{}
+			provider := NewContextProvider(window, maxTokens, model)
+			if _, err := provider.GetModel().GenerateContent(context.Background(), test.messages); err != nil {
+				t.Fatalf("GenerateContent failed: %v", err)
+			}
+
+			sent := model.messages
+			if len(sent) != len(test.messages) {
+				t.Fatalf("sent %d messages, want %d", len(sent), len(test.messages))
+			}
+			// the output room is never given up before the prompt is fitted
+			reserve := model.opts.MaxTokens
+			if reserve <= 0 {
+				reserve = maxTokens
+			}
+			if total := estimateMessages(sent); total+reserve > window {
+				t.Fatalf("sent about %d tokens with %d reserved for the answer, window %d", total, reserve, window)
+			}
+
+			if messageText(sent[0]) != messageText(test.messages[0]) {
+				t.Fatalf("system message changed to %q", messageText(sent[0]))
+			}
+			last := len(sent) - 1
+			got, original := messageText(sent[last]), messageText(test.messages[last])
+			if !test.truncated && got != original {
+				t.Fatalf("newest message changed to %q", got)
+			}
+			if test.truncated && (got == original || !strings.Contains(got, "truncated to fit the context window")) {
+				t.Fatal("huge message was not truncated")
+			}
+			for idx, message := range sent {
+				if !utf8.ValidString(messageText(message)) {
+					t.Fatalf("message %d was not cut on a rune boundary", idx)
+				}
+			}
+		})
+	}
+
+	// the caller keeps its full history
+	history := toolHistory(20, 3000)
+	before := estimateMessages(history)
+	NewContextProvider(window, maxTokens, &recordingModel{}).GetModel().GenerateContent(context.Background(), history)
+	if estimateMessages(history) != before {
+		t.Fatal("the messages of the caller were changed")
+	}
+}
+
+func TestContextProviderRejectsRequestsThatCannotFit(t *testing.T) {
+	// many small parts cannot be truncated, they leave no room for the answer
+	var parts []string
+	for idx := 0; idx < 100; idx++ {
+		parts = append(parts, strings.Repeat("y", 3*minPartTokens))
+	}
+	model := &recordingModel{}
+	provider := NewContextProvider(8192, 1024, model)
+
+	_, err := provider.GetModel().GenerateContent(context.Background(), []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, parts...),
+	})
+	if !errors.Is(err, ErrContextExceeded) {
+		t.Fatalf("got error %v, want ErrContextExceeded", err)
+	}
+	if model.calls != 0 {
+		t.Fatal("an over-length request was sent")
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		maxTokens  int
+		markerOnly bool // the budget does not even fit the marker
+	}{
+		{"ascii lines", strings.Repeat("func main() {}\n", 2000), 600, false},
+		{"cjk", strings.Repeat("文档生成", 3000), 700, false},
+		{"emoji without newlines", strings.Repeat("a🙂", 5000), 900, false},
+		{"tiny budget", strings.Repeat("代码", 1000), 5, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := TruncateText(test.text, test.maxTokens)
+			if !utf8.ValidString(got) {
+				t.Fatal("text was not cut on a rune boundary")
+			}
+			if tokens := EstimateTokens(got); tokens > test.maxTokens && !test.markerOnly {
+				t.Fatalf("truncated to about %d tokens, want at most %d", tokens, test.maxTokens)
+			}
+			if !strings.Contains(got, "truncated to fit the context window") {
+				t.Fatal("truncated text has no marker")
+			}
+			if test.markerOnly && strings.Contains(got, "代码") {
+				t.Fatalf("got %q, want only the marker", got)
+			}
+		})
+	}
+
+	if text := "short text"; TruncateText(text, 100) != text {
+		t.Fatal("text within the limit was changed")
+	}
+}
@@ -0,0 +1,54 @@
+package chat
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// defaultDeepSeekURL DeepSeek 官方接口地址
+const defaultDeepSeekURL = "https://api.deepseek.com/v1"
+
+// DeepSeekProvider DeepSeek 提供商实现
+type DeepSeekProvider struct {
+	model  llms.Model
+	config *ProviderConfig
+}
+
+// NewDeepSeekProvider 创建一个新的 DeepSeek 提供商
+func NewDeepSeekProvider(config *ProviderConfig) (Provider, error) {
+	apiKey := config.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("DEEPSEEK_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("未提供 DeepSeek API 密钥")
+		}
+	}
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = defaultDeepSeekURL
+	}
+
+	// deepseek-reasoner 的思考内容在 reasoning_content 中返回，且不理解 /no_think
+	return &DeepSeekProvider{
+		model: &compatibleModel{options: compatibleOptions{
+			baseURL:      baseURL,
+			apiKey:       apiKey,
+			model:        config.Model,
+			maxTokens:    config.MaxTokens,
+			temperature:  config.Temperature,
+			stripNoThink: true,
+		}},
+		config: config,
+	}, nil
+}
+
+func (p *DeepSeekProvider) HandleResponse(response llms.ContentResponse) {
+
+}
+
+func (p *DeepSeekProvider) GetModel() llms.Model {
+	return p.model
+}
@@ -0,0 +1,44 @@
+package chat
+
+import (
+	"github.com/tmc/langchaingo/llms"
+)
+
+// defaultLlamaCPPURL llama.cpp server 默认的 OpenAI 兼容接口地址
+const defaultLlamaCPPURL = "http://localhost:8080/v1"
+
+// LlamaCPPProvider llama.cpp 提供商实现
+type LlamaCPPProvider struct {
+	model  llms.Model
+	config *ProviderConfig
+}
+
+// NewLlamaCPPProvider 创建一个新的 llama.cpp 提供商，服务以 --api-key 启动时才需要密钥
+func NewLlamaCPPProvider(config *ProviderConfig) (Provider, error) {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = defaultLlamaCPPURL
+	}
+
+	// 服务只加载一个模型，模型名称仅用于日志；工具调用需要以 --jinja 启动
+	return &LlamaCPPProvider{
+		model: &compatibleModel{options: compatibleOptions{
+			baseURL:        baseURL,
+			apiKey:         config.APIKey,
+			model:          config.Model,
+			maxTokens:      config.MaxTokens,
+			temperature:    config.Temperature,
+			templateKwargs: true,
+			promptThink:    true,
+		}},
+		config: config,
+	}, nil
+}
+
+func (p *LlamaCPPProvider) HandleResponse(response llms.ContentResponse) {
+
+}
+
+func (p *LlamaCPPProvider) GetModel() llms.Model {
+	return p.model
+}
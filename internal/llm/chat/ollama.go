@@ -28,6 +28,7 @@ func NewOllamaProvider(config *ProviderConfig) (Provider, error) {
 	opts := []ollama.Option{
 		ollama.WithModel(config.Model),
 		ollama.WithServerURL(config.BaseURL),
+		ollama.WithHTTPClient(httpClient),
 	}
 
 	m, err := ollama.New(opts...)
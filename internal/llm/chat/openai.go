@@ -28,6 +28,7 @@ func NewOpenAIProvider(config *ProviderConfig) (Provider, error) {
 	opts := []openai.Option{
 		openai.WithToken(apiKey),
 		openai.WithModel(config.Model),
+		openai.WithHTTPClient(httpClient),
 	}
 
 	// 如果提供了自定义 URL，则使用它
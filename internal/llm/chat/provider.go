@@ -8,22 +8,35 @@ import (
 type ProviderType string
 
 const (
-	ProviderOpenAI   ProviderType = "openai"
-	ProviderGoogle   ProviderType = "google"
-	ProviderDeepseek ProviderType = "deepseek"
-	ProviderOllama   ProviderType = "ollama"
-	ProviderLlamaCPP ProviderType = "llamacpp"
-	ProviderVLLM     ProviderType = "vllm"
+	ProviderOpenAI    ProviderType = "openai"
+	ProviderGoogle    ProviderType = "google"
+	ProviderDeepseek  ProviderType = "deepseek"
+	ProviderOllama    ProviderType = "ollama"
+	ProviderLlamaCPP  ProviderType = "llamacpp"
+	ProviderVLLM      ProviderType = "vllm"
+	ProviderAnthropic ProviderType = "anthropic"
+	ProviderAzure     ProviderType = "azure"
 )
 
+// IsSupported reports whether the provider type is one of the known providers.
+func IsSupported(t ProviderType) bool {
+	switch t {
+	case ProviderOpenAI, ProviderGoogle, ProviderDeepseek, ProviderOllama, ProviderLlamaCPP, ProviderVLLM, ProviderAnthropic, ProviderAzure:
+		return true
+	}
+	return false
+}
+
 // ProviderConfig LLM 提供商配置
 type ProviderConfig struct {
-	Type        ProviderType `json:"type"`        // 提供商类型
-	APIKey      string       `json:"api_key"`     // API 密钥
-	Model       string       `json:"model"`       // 模型名称
-	BaseURL     string       `json:"base_url"`    // 基础 URL（对于自定义端点）
-	MaxTokens   int          `json:"max_tokens"`  // 最大令牌数
-	Temperature float64      `json:"temperature"` // 温度
+	Type          ProviderType `json:"type"`           // 提供商类型
+	APIKey        string       `json:"api_key"`        // API 密钥
+	Model         string       `json:"model"`          // 模型名称
+	BaseURL       string       `json:"base_url"`       // 基础 URL（对于自定义端点）
+	APIVersion    string       `json:"api_version"`    // API 版本（Azure OpenAI）
+	MaxTokens     int          `json:"max_tokens"`     // 最大令牌数
+	Temperature   float64      `json:"temperature"`    // 温度
+	ContextWindow int          `json:"context_window"` // 上下文窗口，0 表示按模型推断
 }
 
 // Provider LLM 提供商接口
@@ -42,6 +55,16 @@ func NewProvider(config *ProviderConfig) (Provider, error) {
 		return NewGoogleProvider(config)
 	case ProviderOllama:
 		return NewOllamaProvider(config)
+	case ProviderDeepseek:
+		return NewDeepSeekProvider(config)
+	case ProviderVLLM:
+		return NewVLLMProvider(config)
+	case ProviderLlamaCPP:
+		return NewLlamaCPPProvider(config)
+	case ProviderAnthropic:
+		return NewAnthropicProvider(config)
+	case ProviderAzure:
+		return NewAzureOpenAIProvider(config)
 	default:
 		// 默认使用 OpenAI
 		return NewOpenAIProvider(config)
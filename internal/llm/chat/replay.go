@@ -0,0 +1,249 @@
+package chat
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tmc/langchaingo/llms"
+	"go.uber.org/zap"
+)
+
+const (
+	ReplayModeRecord = "record" // call the provider and save every exchange as a fixture
+	ReplayModeReplay = "replay" // answer from the fixtures only, no model is called
+)
+
+// ErrFixtureNotFound is returned in replay mode when no exchange was recorded for a request.
+var ErrFixtureNotFound = errors.New("llm fixture not found")
+
+// ReplayProvider records the requests and responses of a provider to a fixture directory,
+// or replays them without a model. Fixtures are keyed by the hash of the messages and tools,
+// so a deterministic pipeline run can be repeated offline.
+type ReplayProvider struct {
+	dir      string
+	provider Provider // nil in replay mode
+}
+
+// NewRecordProvider wraps the provider and saves its responses to dir.
+func NewRecordProvider(dir string, provider Provider) Provider {
+	return &ReplayProvider{
+		dir:      dir,
+		provider: provider,
+	}
+}
+
+// NewReplayProvider answers from the fixtures recorded to dir.
+func NewReplayProvider(dir string) Provider {
+	return &ReplayProvider{
+		dir: dir,
+	}
+}
+
+// fixture is a recorded exchange, the request is kept to make the files reviewable.
+type fixture struct {
+	Messages []llms.MessageContent `json:"messages"`
+	Tools    []llms.Tool           `json:"tools,omitempty"`
+	Choices  []savedChoice         `json:"choices"`
+}
+
+// savedChoice is a choice as it is saved by the replay provider and the cache.
+type savedChoice struct {
+	Content          string          `json:"content"`
+	StopReason       string          `json:"stop_reason,omitempty"`
+	ReasoningContent string          `json:"reasoning_content,omitempty"`
+	ToolCalls        []savedToolCall `json:"tool_calls,omitempty"`
+	GenerationInfo   map[string]any  `json:"generation_info,omitempty"`
+}
+
+// savedToolCall is a tool call, llms.ToolCall loses the function when it is decoded.
+type savedToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+func (p *ReplayProvider) HandleResponse(response llms.ContentResponse) {
+
+}
+
+func (p *ReplayProvider) GetModel() llms.Model {
+	return p
+}
+
+// Call implements llms.Model.
+func (p *ReplayProvider) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, p, prompt, options...)
+}
+
+// GenerateContent implements llms.Model.
+func (p *ReplayProvider) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	opts := llms.CallOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	key, err := requestKey("", messages, opts.Tools)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(p.dir, key+".json")
+
+	if p.provider == nil {
+		return p.replay(ctx, path, messages, &opts)
+	}
+
+	response, err := p.provider.GetModel().GenerateContent(ctx, messages, options...)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.record(path, messages, opts.Tools, response); err != nil {
+		zap.L().Warn("cannot record llm fixture", zap.String("path", path), zap.Error(err))
+	}
+	return response, nil
+}
+
+func (p *ReplayProvider) replay(ctx context.Context, path string, messages []llms.MessageContent, opts *llms.CallOptions) (*llms.ContentResponse, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s, last message: %s", ErrFixtureNotFound, filepath.Base(path), preview(messages))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var recorded fixture
+	if err = json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("decode fixture %s failed: %w", path, err)
+	}
+
+	response := restoreChoices(recorded.Choices)
+	if len(response.Choices) == 0 {
+		return nil, fmt.Errorf("fixture %s has no choices", path)
+	}
+	if err = streamSaved(ctx, opts, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (p *ReplayProvider) record(path string, messages []llms.MessageContent, tools []llms.Tool, response *llms.ContentResponse) error {
+	recorded := fixture{
+		Messages: messages,
+		Tools:    tools,
+		Choices:  saveChoices(response),
+	}
+
+	data, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(p.dir, 0755); err != nil {
+		return err
+	}
+
+	// concurrent generations may record the same request, write the file at once
+	file, err := os.CreateTemp(p.dir, ".fixture-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// saveChoices converts the choices of a response to their saved form.
+func saveChoices(response *llms.ContentResponse) []savedChoice {
+	var choices []savedChoice
+	for _, choice := range response.Choices {
+		saved := savedChoice{
+			Content:          choice.Content,
+			StopReason:       choice.StopReason,
+			ReasoningContent: choice.ReasoningContent,
+			GenerationInfo:   choice.GenerationInfo,
+		}
+		for _, call := range choice.ToolCalls {
+			savedCall := savedToolCall{ID: call.ID}
+			if call.FunctionCall != nil {
+				savedCall.Name = call.FunctionCall.Name
+				savedCall.Arguments = call.FunctionCall.Arguments
+			}
+			saved.ToolCalls = append(saved.ToolCalls, savedCall)
+		}
+		choices = append(choices, saved)
+	}
+	return choices
+}
+
+// restoreChoices converts saved choices back to a response.
+func restoreChoices(choices []savedChoice) *llms.ContentResponse {
+	response := &llms.ContentResponse{}
+	for _, choice := range choices {
+		result := &llms.ContentChoice{
+			Content:          choice.Content,
+			StopReason:       choice.StopReason,
+			ReasoningContent: choice.ReasoningContent,
+			GenerationInfo:   choice.GenerationInfo,
+		}
+		for _, call := range choice.ToolCalls {
+			result.ToolCalls = append(result.ToolCalls, llms.ToolCall{
+				ID:   call.ID,
+				Type: "function",
+				FunctionCall: &llms.FunctionCall{
+					Name:      call.Name,
+					Arguments: call.Arguments,
+				},
+			})
+		}
+		if len(result.ToolCalls) > 0 {
+			result.FuncCall = result.ToolCalls[0].FunctionCall
+		}
+		response.Choices = append(response.Choices, result)
+	}
+	return response
+}
+
+// streamSaved passes a saved answer to the streaming func as a single chunk.
+func streamSaved(ctx context.Context, opts *llms.CallOptions, response *llms.ContentResponse) error {
+	if opts.StreamingFunc == nil || len(response.Choices) == 0 || len(response.Choices[0].Content) == 0 {
+		return nil
+	}
+	return opts.StreamingFunc(ctx, []byte(response.Choices[0].Content))
+}
+
+// requestKey hashes the parts of a request that decide the answer, the model is optional.
+func requestKey(model string, messages []llms.MessageContent, tools []llms.Tool) (string, error) {
+	data, err := json.Marshal(struct {
+		Model    string                `json:"model,omitempty"`
+		Messages []llms.MessageContent `json:"messages"`
+		Tools    []llms.Tool           `json:"tools,omitempty"`
+	}{model, messages, tools})
+	if err != nil {
+		return "", fmt.Errorf("encode request failed: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// preview shortens the last message for the error of a missing fixture.
+func preview(messages []llms.MessageContent) string {
+	if len(messages) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(messages[len(messages)-1])
+	if len(data) > 120 {
+		return string(data[:120]) + "..."
+	}
+	return string(data)
+}
@@ -0,0 +1,262 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryConfig controls the retries of a provider before the next provider is tried.
+type RetryConfig struct {
+	MaxAttempts    int           // attempts per provider, default 3
+	InitialBackoff time.Duration // delay before the first retry, doubled on every retry, default 2s
+	MaxBackoff     time.Duration // longest delay, a longer Retry-After fails over to the next provider or is cut on the last, default 1m
+}
+
+// FallbackProvider retries transient errors (429, 5xx, timeouts) with exponential backoff
+// and fails over to the next provider when a provider keeps failing. A streamed request
+// is not retried once a chunk was delivered, the chunks would be sent twice.
+type FallbackProvider struct {
+	names     []string
+	providers []Provider
+	retry     RetryConfig
+}
+
+// NewFallbackProvider wraps the providers, they are tried in order. The names label the
+// providers in the logs and the choices they answered, e.g. "default/gpt-4o".
+func NewFallbackProvider(names []string, providers []Provider, retry RetryConfig) Provider {
+	if retry.MaxAttempts <= 0 {
+		retry.MaxAttempts = 3
+	}
+	if retry.InitialBackoff <= 0 {
+		retry.InitialBackoff = 2 * time.Second
+	}
+	if retry.MaxBackoff <= 0 {
+		retry.MaxBackoff = time.Minute
+	}
+	return &FallbackProvider{
+		names:     names,
+		providers: providers,
+		retry:     retry,
+	}
+}
+
+func (p *FallbackProvider) HandleResponse(response llms.ContentResponse) {
+
+}
+
+func (p *FallbackProvider) GetModel() llms.Model {
+	return p
+}
+
+// Call implements llms.Model.
+func (p *FallbackProvider) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, p, prompt, options...)
+}
+
+// GenerateContent implements llms.Model.
+func (p *FallbackProvider) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	opts := llms.CallOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+	var streamed atomic.Bool
+	if streamingFunc := opts.StreamingFunc; streamingFunc != nil {
+		options = append(options, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+			streamed.Store(true)
+			return streamingFunc(ctx, chunk)
+		}))
+	}
+
+	var lastErr error
+	for idx, provider := range p.providers {
+		isLast := idx == len(p.providers)-1
+		if idx > 0 {
+			zap.L().Warn("LLM provider failed, falling back", zap.String("from", p.names[idx-1]), zap.String("to", p.names[idx]), zap.Error(lastErr))
+		}
+
+		backoff := p.retry.InitialBackoff
+		for attempt := 1; attempt <= p.retry.MaxAttempts; attempt++ {
+			hint := &responseHint{}
+			response, err := provider.GetModel().GenerateContent(context.WithValue(ctx, hintKey{}, hint), messages, options...)
+			if err == nil {
+				setAnsweredBy(response, p.names[idx])
+				return response, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil || streamed.Load() {
+				return nil, err
+			}
+			// other errors are not fixed by waiting, the next provider may still succeed
+			if !isRetryable(err, hint) || attempt == p.retry.MaxAttempts {
+				break
+			}
+
+			delay := jitter(backoff)
+			if retryAfter := hint.RetryAfter(); retryAfter > 0 {
+				// a longer wait fails over, the last provider retries after MaxBackoff at most
+				if retryAfter > p.retry.MaxBackoff && !isLast {
+					break
+				}
+				delay = retryAfter
+			}
+			delay = min(delay, p.retry.MaxBackoff)
+
+			zap.L().Warn("LLM request failed, retrying", zap.String("provider", p.names[idx]), zap.Int("attempt", attempt), zap.Duration("delay", delay), zap.Error(err))
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+
+			backoff *= 2
+			if backoff > p.retry.MaxBackoff {
+				backoff = p.retry.MaxBackoff
+			}
+		}
+	}
+	return nil, lastErr
+}
+
+// jitter spreads the retries of concurrent requests by up to 20%.
+func jitter(delay time.Duration) time.Duration {
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+// isRetryable reports whether an error is worth retrying on the same provider.
+func isRetryable(err error, hint *responseHint) bool {
+	if status := errorStatus(err, hint); status >= 400 {
+		return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, keyword := range []string{"rate limit", "timeout", "timed out", "overloaded", "unavailable"} {
+		if strings.Contains(message, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorStatus returns the http status of a failed request, from the hint of the providers
+// using httpClient or from the typed errors of the Google client. It is 0 when unknown.
+func errorStatus(err error, hint *responseHint) int {
+	if status := hint.Status(); status >= 400 {
+		return status
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	if grpcStatus, ok := status.FromError(err); ok {
+		switch grpcStatus.Code() {
+		case codes.ResourceExhausted:
+			return http.StatusTooManyRequests
+		case codes.Unavailable:
+			return http.StatusServiceUnavailable
+		case codes.DeadlineExceeded:
+			return http.StatusGatewayTimeout
+		case codes.Internal, codes.Unknown:
+			return http.StatusInternalServerError
+		case codes.OK, codes.Canceled:
+			return 0
+		default:
+			return http.StatusBadRequest
+		}
+	}
+	return 0
+}
+
+type hintKey struct{}
+
+// responseHint records the status and Retry-After of the http responses of a request.
+type responseHint struct {
+	mu         sync.Mutex
+	status     int
+	retryAfter time.Duration
+}
+
+func (h *responseHint) record(response *http.Response) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status = response.StatusCode
+	h.retryAfter = parseRetryAfter(response.Header)
+}
+
+func (h *responseHint) Status() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
+
+func (h *responseHint) RetryAfter() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.retryAfter
+}
+
+// parseRetryAfter reads Retry-After in seconds or as a date, and the retry-after-ms of OpenAI.
+func parseRetryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.Atoi(header.Get("Retry-After-Ms")); err == nil && ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+
+	value := header.Get("Retry-After")
+	if len(value) == 0 {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// hintTransport passes the responses to the hint of the request context,
+// the llm clients do not expose the response headers.
+type hintTransport struct {
+	base http.RoundTripper
+}
+
+func (t *hintTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := t.base.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	if hint, ok := request.Context().Value(hintKey{}).(*responseHint); ok {
+		hint.record(response)
+	}
+	return response, nil
+}
+
+// httpClient is used by the providers that accept a http client.
+var httpClient = &http.Client{
+	Transport: &hintTransport{base: http.DefaultTransport},
+}
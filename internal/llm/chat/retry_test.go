@@ -0,0 +1,193 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyModel streams its chunks and then fails with the errors, one per call, before it succeeds.
+type flakyModel struct {
+	chunks     []string
+	errs       []error
+	retryAfter time.Duration // sent with the errors
+	calls      int
+}
+
+func (m *flakyModel) HandleResponse(response llms.ContentResponse) {}
+
+func (m *flakyModel) GetModel() llms.Model { return m }
+
+func (m *flakyModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+func (m *flakyModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	opts := llms.CallOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+	m.calls++
+	for _, chunk := range m.chunks {
+		if opts.StreamingFunc != nil {
+			opts.StreamingFunc(ctx, []byte(chunk))
+		}
+	}
+	if m.calls <= len(m.errs) {
+		if hint, ok := ctx.Value(hintKey{}).(*responseHint); ok {
+			hint.retryAfter = m.retryAfter
+		}
+		return nil, m.errs[m.calls-1]
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+		Content:        "done",
+		GenerationInfo: map[string]any{"PromptTokens": 10, "CompletionTokens": 2},
+	}}}, nil
+}
+
+func newTestFallback(models ...*flakyModel) Provider {
+	names := make([]string, len(models))
+	providers := make([]Provider, len(models))
+	for idx, model := range models {
+		names[idx] = fmt.Sprintf("model-%d", idx)
+		providers[idx] = model
+	}
+	return NewFallbackProvider(names, providers, RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+}
+
+func TestFallbackRetriesBeforeStreaming(t *testing.T) {
+	model := &flakyModel{errs: []error{googleapiError(http.StatusServiceUnavailable)}}
+	response, err := newTestFallback(model).GetModel().GenerateContent(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if response.Choices[0].Content != "done" || model.calls != 2 {
+		t.Fatalf("got %q after %d calls, want done after 2", response.Choices[0].Content, model.calls)
+	}
+}
+
+func TestFallbackDoesNotRepeatStreamedChunks(t *testing.T) {
+	failing := &flakyModel{chunks: []string{"hel", "lo"}, errs: []error{googleapiError(http.StatusServiceUnavailable)}}
+	next := &flakyModel{chunks: []string{"again"}}
+
+	var streamed string
+	_, err := newTestFallback(failing, next).GetModel().GenerateContent(context.Background(), nil,
+		llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+			streamed += string(chunk)
+			return nil
+		}))
+	if err == nil {
+		t.Fatal("GenerateContent succeeded after streaming a failed answer")
+	}
+	if streamed != "hello" || failing.calls != 1 || next.calls != 0 {
+		t.Fatalf("streamed %q with %d and %d calls, want hello with 1 and 0", streamed, failing.calls, next.calls)
+	}
+}
+
+func TestFallbackLimitsRetryAfter(t *testing.T) {
+	tests := []struct {
+		name         string
+		fallback     bool // a second provider is configured
+		wantFailing  int
+		wantFallback int
+	}{
+		{"fails over to the next provider", true, 1, 1},
+		{"last provider waits at most MaxBackoff", false, 2, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			failing := &flakyModel{errs: []error{googleapiError(http.StatusTooManyRequests)}, retryAfter: time.Hour}
+			next := &flakyModel{}
+			models := []*flakyModel{failing}
+			if test.fallback {
+				models = append(models, next)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := newTestFallback(models...).GetModel().GenerateContent(ctx, nil); err != nil {
+				t.Fatalf("GenerateContent failed: %v", err)
+			}
+			if failing.calls != test.wantFailing || next.calls != test.wantFallback {
+				t.Fatalf("got %d and %d calls, want %d and %d", failing.calls, next.calls, test.wantFailing, test.wantFallback)
+			}
+		})
+	}
+}
+
+// recordingMeter keeps the models the usage was recorded with.
+type recordingMeter struct {
+	models []string
+}
+
+func (m *recordingMeter) Allow() error { return nil }
+
+func (m *recordingMeter) Record(stage, model string, usage Usage) {
+	m.models = append(m.models, model)
+}
+
+func TestMeteredFallbackRecordsAnsweringModel(t *testing.T) {
+	tests := []struct {
+		name   string
+		errs   []error // errors of the primary model
+		want   string
+		wantOf int // calls of the fallback model
+	}{
+		{"primary answers", nil, "model-0", 0},
+		{"fallback answers", []error{googleapiError(http.StatusNotFound)}, "model-1", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			primary, fallback := &flakyModel{errs: test.errs}, &flakyModel{}
+			meter := &recordingMeter{}
+			provider := NewMeteredProvider("document", "model-0", meter, newTestFallback(primary, fallback))
+
+			if _, err := provider.GetModel().GenerateContent(context.Background(), nil); err != nil {
+				t.Fatalf("GenerateContent failed: %v", err)
+			}
+			if len(meter.models) != 1 || meter.models[0] != test.want || fallback.calls != test.wantOf {
+				t.Fatalf("recorded %v after %d fallback calls, want %s", meter.models, fallback.calls, test.want)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		hint int
+		want bool
+	}{
+		{"hint 429", errors.New("request failed"), http.StatusTooManyRequests, true},
+		{"hint 400", errors.New("request failed, status 503"), http.StatusBadRequest, false},
+		{"numbers in the message", errors.New("error: prompt has 5000 tokens, the model supports 4096"), 0, false},
+		{"status in the message", errors.New("status 503"), 0, false},
+		{"googleapi 503", googleapiError(http.StatusServiceUnavailable), 0, true},
+		{"googleapi 404", googleapiError(http.StatusNotFound), 0, false},
+		{"grpc resource exhausted", status.Error(codes.ResourceExhausted, "quota"), 0, true},
+		{"grpc invalid argument", status.Error(codes.InvalidArgument, "bad request"), 0, false},
+		{"wrapped grpc unavailable", fmt.Errorf("generate: %w", status.Error(codes.Unavailable, "down")), 0, true},
+		{"deadline", context.DeadlineExceeded, 0, true},
+		{"rate limit keyword", errors.New("Rate limit reached"), 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isRetryable(test.err, &responseHint{status: test.hint}); got != test.want {
+				t.Fatalf("isRetryable(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func googleapiError(code int) error {
+	return &googleapi.Error{Code: code, Message: http.StatusText(code)}
+}
@@ -0,0 +1,175 @@
+package chat
+
+import (
+	"context"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Usage is the token usage of a request.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	Estimated        bool // the provider did not report the usage, it was counted with tiktoken
+}
+
+// Meter accounts the usage of the requests of a task.
+type Meter interface {
+	// Allow returns an error when no more requests may be made, e.g. the budget is spent.
+	Allow() error
+	Record(stage, model string, usage Usage)
+}
+
+// MeteredProvider reports the usage of every request of a stage to the meter.
+type MeteredProvider struct {
+	stage    string
+	model    string
+	meter    Meter
+	provider Provider
+}
+
+// NewMeteredProvider wraps the provider, model is the name the usage is recorded and priced with.
+func NewMeteredProvider(stage, model string, meter Meter, provider Provider) Provider {
+	return &MeteredProvider{
+		stage:    stage,
+		model:    model,
+		meter:    meter,
+		provider: provider,
+	}
+}
+
+func (p *MeteredProvider) HandleResponse(response llms.ContentResponse) {
+
+}
+
+func (p *MeteredProvider) GetModel() llms.Model {
+	return p
+}
+
+// Call implements llms.Model.
+func (p *MeteredProvider) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, p, prompt, options...)
+}
+
+// GenerateContent implements llms.Model.
+func (p *MeteredProvider) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	if err := p.meter.Allow(); err != nil {
+		return nil, err
+	}
+
+	response, err := p.provider.GetModel().GenerateContent(ctx, messages, options...)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Choices) == 0 || isCached(response.Choices[0]) {
+		return response, nil
+	}
+
+	// a fallback provider may have answered instead of the primary model
+	model := p.model
+	if name := answeredBy(response.Choices[0]); len(name) > 0 {
+		model = name
+	}
+	usage, ok := usageOf(response.Choices[0])
+	if !ok {
+		usage = estimateUsage(model, messages, response.Choices[0])
+	}
+	p.meter.Record(p.stage, model, usage)
+	return response, nil
+}
+
+// answeredByKey names the provider and model that answered a choice, see FallbackProvider.
+const answeredByKey = "AnsweredBy"
+
+func setAnsweredBy(response *llms.ContentResponse, model string) {
+	for _, choice := range response.Choices {
+		if choice.GenerationInfo == nil {
+			choice.GenerationInfo = make(map[string]any)
+		}
+		choice.GenerationInfo[answeredByKey] = model
+	}
+}
+
+func answeredBy(choice *llms.ContentChoice) string {
+	model, _ := choice.GenerationInfo[answeredByKey].(string)
+	return model
+}
+
+// cachedKey marks the choices answered by the cache, they cost nothing.
+const cachedKey = "Cached"
+
+func isCached(choice *llms.ContentChoice) bool {
+	cached, _ := choice.GenerationInfo[cachedKey].(bool)
+	return cached
+}
+
+// usageOf reads the usage a provider reported in the generation info,
+// the keys differ between the clients and replayed numbers are decoded as float64.
+func usageOf(choice *llms.ContentChoice) (Usage, bool) {
+	var usage Usage
+	for _, key := range []string{"PromptTokens", "input_tokens", "InputTokens"} {
+		if n, ok := toInt(choice.GenerationInfo[key]); ok {
+			usage.PromptTokens = n
+			break
+		}
+	}
+	for _, key := range []string{"CompletionTokens", "output_tokens", "OutputTokens"} {
+		if n, ok := toInt(choice.GenerationInfo[key]); ok {
+			usage.CompletionTokens = n
+			break
+		}
+	}
+	// streamed responses of some servers report no usage at all
+	return usage, usage.PromptTokens > 0 || usage.CompletionTokens > 0
+}
+
+func toInt(value any) (int, bool) {
+	switch n := value.(type) {
+	case int:
+		return n, true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+// estimateUsage counts the tokens of the request and the answer.
+func estimateUsage(model string, messages []llms.MessageContent, choice *llms.ContentChoice) Usage {
+	// the model is prefixed with the provider name, e.g. "default/gpt-4o"
+	if _, name, ok := strings.Cut(model, "/"); ok {
+		model = name
+	}
+
+	var prompt strings.Builder
+	for _, message := range messages {
+		for _, part := range message.Parts {
+			switch p := part.(type) {
+			case llms.TextContent:
+				prompt.WriteString(p.Text)
+			case llms.ToolCall:
+				if p.FunctionCall != nil {
+					prompt.WriteString(p.FunctionCall.Name + p.FunctionCall.Arguments)
+				}
+			case llms.ToolCallResponse:
+				prompt.WriteString(p.Content)
+			}
+		}
+	}
+
+	completion := choice.Content + choice.ReasoningContent
+	for _, call := range choice.ToolCalls {
+		if call.FunctionCall != nil {
+			completion += call.FunctionCall.Name + call.FunctionCall.Arguments
+		}
+	}
+	return Usage{
+		PromptTokens:     llms.CountTokens(model, prompt.String()),
+		CompletionTokens: llms.CountTokens(model, completion),
+		Estimated:        true,
+	}
+}
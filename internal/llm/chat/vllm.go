@@ -0,0 +1,51 @@
+package chat
+
+import (
+	"os"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// defaultVLLMURL vLLM 默认的 OpenAI 兼容接口地址
+const defaultVLLMURL = "http://localhost:8000/v1"
+
+// VLLMProvider vLLM 提供商实现
+type VLLMProvider struct {
+	model  llms.Model
+	config *ProviderConfig
+}
+
+// NewVLLMProvider 创建一个新的 vLLM 提供商，未启用 --api-key 的服务无需密钥
+func NewVLLMProvider(config *ProviderConfig) (Provider, error) {
+	apiKey := config.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("VLLM_API_KEY")
+	}
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = defaultVLLMURL
+	}
+
+	// /no_think 同时通过 chat_template_kwargs 关闭 Qwen3 等混合模型的思考
+	return &VLLMProvider{
+		model: &compatibleModel{options: compatibleOptions{
+			baseURL:        baseURL,
+			apiKey:         apiKey,
+			model:          config.Model,
+			maxTokens:      config.MaxTokens,
+			temperature:    config.Temperature,
+			templateKwargs: true,
+			promptThink:    true,
+		}},
+		config: config,
+	}, nil
+}
+
+func (p *VLLMProvider) HandleResponse(response llms.ContentResponse) {
+
+}
+
+func (p *VLLMProvider) GetModel() llms.Model {
+	return p.model
+}
@@ -0,0 +1,174 @@
+package progress
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	EventStage  = "stage"  // a generation stage started
+	EventToken  = "token"  // streamed model output
+	EventStatus = "status" // task status changed
+)
+
+// Stages of a documentation task.
+const (
+	StageClone     = "clone"
+	StageReadme    = "readme"
+	StageCatalogue = "catalogue"
+	StageOverview  = "overview"
+	StageIndex     = "index"
+	StageThink     = "think"
+	StageDocument  = "document"
+	StageHistory   = "history"
+	StageChat      = "chat"
+	StageResearch  = "research"
+)
+
+// Event is a progress event of a task. Documents are generated concurrently, the events
+// of a document carry the key of its catalogue item to tell their token streams apart.
+type Event struct {
+	TaskID  uint      `json:"task_id"`
+	Type    string    `json:"type"`
+	Stage   string    `json:"stage,omitempty"`
+	Key     string    `json:"key,omitempty"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+type keyContext struct{}
+
+// WithKey returns a context for the generation of the catalogue item with the given key.
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, keyContext{}, key)
+}
+
+// KeyFromContext returns the catalogue item key of a context, empty outside of a document.
+func KeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(keyContext{}).(string)
+	return key
+}
+
+// Broker fans out task events to subscribers.
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[chan *Event]struct{}
+}
+
+// NewBroker creates a new event broker.
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[uint]map[chan *Event]struct{}),
+	}
+}
+
+// subscriberBuffer is the number of events a subscriber may fall behind.
+const subscriberBuffer = 256
+
+// Subscribe returns a channel receiving the events of a task and a function to unsubscribe.
+func (b *Broker) Subscribe(taskID uint) (<-chan *Event, func()) {
+	ch := make(chan *Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[taskID] == nil {
+		b.subscribers[taskID] = make(map[chan *Event]struct{})
+	}
+	b.subscribers[taskID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers[taskID], ch)
+		if len(b.subscribers[taskID]) == 0 {
+			delete(b.subscribers, taskID)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// Publish sends an event to all subscribers of its task. Slow subscribers miss tokens
+// instead of blocking the task, stages and statuses replace the oldest buffered events.
+// Clients wait for the final status, it must never be dropped.
+func (b *Broker) Publish(event *Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers[event.TaskID] {
+		for !trySend(ch, event) {
+			if event.Type == EventToken {
+				break
+			}
+			select {
+			case <-ch:
+			default:
+			}
+		}
+	}
+}
+
+func trySend(ch chan *Event, event *Event) bool {
+	select {
+	case ch <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+var defaultBroker = NewBroker()
+
+// Subscribe subscribes to the events of a task on the default broker.
+func Subscribe(taskID uint) (<-chan *Event, func()) {
+	return defaultBroker.Subscribe(taskID)
+}
+
+// Publish publishes an event on the default broker.
+func Publish(event *Event) {
+	defaultBroker.Publish(event)
+}
+
+// Reporter publishes the progress of a single task. A nil Reporter discards events.
+type Reporter struct {
+	taskID uint
+}
+
+// NewReporter creates a reporter for a task.
+func NewReporter(taskID uint) *Reporter {
+	return &Reporter{taskID: taskID}
+}
+
+// Stage reports that a stage started.
+func (r *Reporter) Stage(stage, message string) {
+	r.publish(EventStage, stage, "", message)
+}
+
+// Item reports that the generation of a catalogue item started.
+func (r *Reporter) Item(stage, key, message string) {
+	r.publish(EventStage, stage, key, message)
+}
+
+// Token reports streamed model output of a stage, key is the catalogue item being generated.
+func (r *Reporter) Token(stage, key string, chunk []byte) {
+	r.publish(EventToken, stage, key, string(chunk))
+}
+
+// Status reports a task status change.
+func (r *Reporter) Status(status string) {
+	r.publish(EventStatus, "", "", status)
+}
+
+func (r *Reporter) publish(eventType, stage, key, message string) {
+	if r == nil {
+		return
+	}
+	Publish(&Event{
+		TaskID:  r.taskID,
+		Type:    eventType,
+		Stage:   stage,
+		Key:     key,
+		Message: message,
+		Time:    time.Now(),
+	})
+}
@@ -0,0 +1,105 @@
+package progress
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestReporterPublishesTaskEvents(t *testing.T) {
+	events, unsubscribe := Subscribe(41)
+	defer unsubscribe()
+
+	reporter := NewReporter(41)
+	reporter.Stage(StageDocument, "Overview")
+	reporter.Token(StageDocument, "", []byte("hello"))
+	reporter.Status("Completed")
+
+	for _, want := range []Event{
+		{Type: EventStage, Stage: StageDocument, Message: "Overview"},
+		{Type: EventToken, Stage: StageDocument, Message: "hello"},
+		{Type: EventStatus, Message: "Completed"},
+	} {
+		got := <-events
+		if got.TaskID != 41 || got.Type != want.Type || got.Stage != want.Stage || got.Message != want.Message {
+			t.Fatalf("got event %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestTokenCarriesCatalogueKey(t *testing.T) {
+	events, unsubscribe := Subscribe(42)
+	defer unsubscribe()
+
+	reporter := NewReporter(42)
+	ctx := WithKey(context.Background(), "1.2")
+	reporter.Item(StageDocument, KeyFromContext(ctx), "Overview")
+	reporter.Token(StageDocument, KeyFromContext(ctx), []byte("hello"))
+	reporter.Token(StageOverview, KeyFromContext(context.Background()), []byte("world"))
+
+	for _, want := range []Event{
+		{Type: EventStage, Stage: StageDocument, Key: "1.2", Message: "Overview"},
+		{Type: EventToken, Stage: StageDocument, Key: "1.2", Message: "hello"},
+		{Type: EventToken, Stage: StageOverview, Message: "world"},
+	} {
+		got := <-events
+		if got.TaskID != 42 || got.Type != want.Type || got.Stage != want.Stage || got.Key != want.Key || got.Message != want.Message {
+			t.Fatalf("got event %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestStatusSurvivesFullBuffer(t *testing.T) {
+	events, unsubscribe := Subscribe(43)
+	defer unsubscribe()
+
+	// a long token stream fills the buffer of a subscriber that does not read
+	reporter := NewReporter(43)
+	for idx := 0; idx < subscriberBuffer*2; idx++ {
+		reporter.Token(StageDocument, "1", []byte("token"))
+	}
+	reporter.Stage(StageOverview, "")
+	reporter.Status("Completed")
+
+	var received []*Event
+	for len(events) > 0 {
+		received = append(received, <-events)
+	}
+	if len(received) != subscriberBuffer {
+		t.Fatalf("received %d events, want a full buffer of %d", len(received), subscriberBuffer)
+	}
+	stage, status := received[len(received)-2], received[len(received)-1]
+	if stage.Type != EventStage || stage.Stage != StageOverview {
+		t.Fatalf("got event %+v before the status, want the overview stage", stage)
+	}
+	if status.Type != EventStatus || status.Message != "Completed" {
+		t.Fatalf("got last event %+v, want the completed status", status)
+	}
+}
+
+func TestStatusWithConcurrentTokens(t *testing.T) {
+	events, unsubscribe := Subscribe(44)
+	defer unsubscribe()
+
+	reporter := NewReporter(44)
+	var wg sync.WaitGroup
+	for worker := 0; worker < 4; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := 0; idx < subscriberBuffer; idx++ {
+				reporter.Token(StageDocument, "1", []byte("token"))
+			}
+		}()
+	}
+	wg.Wait()
+	reporter.Status("Failed")
+
+	var last *Event
+	for len(events) > 0 {
+		last = <-events
+	}
+	if last == nil || last.Type != EventStatus || last.Message != "Failed" {
+		t.Fatalf("got last event %+v, want the failed status", last)
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/progress"
+)
+
+// ErrTaskCancelled stops a task whose status was set to cancelled while it was processed.
+var ErrTaskCancelled = errors.New("task was cancelled")
+
+// running tasks and research tasks that can be cancelled, keyed by their id.
+var (
+	cancelMu        sync.Mutex
+	cancelFuncs     = make(map[uint]context.CancelFunc)
+	researchCancels = make(map[uint]context.CancelFunc)
+)
+
+func registerCancel(taskID uint, cancel context.CancelFunc) {
+	cancelMu.Lock()
+	defer cancelMu.Unlock()
+	cancelFuncs[taskID] = cancel
+}
+
+func unregisterCancel(taskID uint) {
+	cancelMu.Lock()
+	defer cancelMu.Unlock()
+	delete(cancelFuncs, taskID)
+}
+
+// CancelTask cancels a running task, it returns false if the task is not running.
+func CancelTask(taskID uint) bool {
+	cancelMu.Lock()
+	defer cancelMu.Unlock()
+
+	cancel, ok := cancelFuncs[taskID]
+	if !ok {
+		return false
+	}
+	cancel()
+	return true
+}
+
+func registerResearchCancel(researchID uint, cancel context.CancelFunc) {
+	cancelMu.Lock()
+	defer cancelMu.Unlock()
+	researchCancels[researchID] = cancel
+}
+
+func unregisterResearchCancel(researchID uint) {
+	cancelMu.Lock()
+	defer cancelMu.Unlock()
+	delete(researchCancels, researchID)
+}
+
+// CancelResearch cancels a running research task, it returns false if the research is not running.
+func CancelResearch(researchID uint) bool {
+	cancelMu.Lock()
+	defer cancelMu.Unlock()
+
+	cancel, ok := researchCancels[researchID]
+	if !ok {
+		return false
+	}
+	cancel()
+	return true
+}
+
+// TaskQueued publishes the pending status of a new or requeued task and wakes the queue.
+func TaskQueued(taskID uint) {
+	taskQueued(taskID, models.RepositoryStatusPending)
+}
+
+func taskQueued(taskID uint, status int) {
+	progress.NewReporter(taskID).Status(models.GetStatusString(status))
+	wakeQueue()
+}
+
+// RequeueTask queues a stopped task again. A task stopped while it was synced is synced again,
+// analyzing it from scratch would regenerate the wiki from the catalogue the sync already changed.
+func RequeueTask(taskID uint) error {
+	taskDao := dao.NewRepositoryTaskDAO()
+	task, err := taskDao.GetRepositoryTaskByID(taskID)
+	if err != nil {
+		return err
+	}
+
+	status := models.RepositoryStatusPending
+	if task.Syncing && task.Source != models.RepositorySourceLocal {
+		status = models.RepositoryStatusUpdating
+	}
+	if err = taskDao.UpdateRepositoryTaskStatus(taskID, status); err != nil {
+		return err
+	}
+	taskQueued(taskID, status)
+	return nil
+}
+
+// RestartTask queues a stopped or finished task to be cloned and analyzed from scratch.
+func RestartTask(taskID uint) error {
+	taskDao := dao.NewRepositoryTaskDAO()
+	if err := taskDao.UpdateRepositoryTaskSyncing(taskID, false); err != nil {
+		return err
+	}
+	if err := taskDao.UpdateRepositoryTaskStatus(taskID, models.RepositoryStatusPending); err != nil {
+		return err
+	}
+	TaskQueued(taskID)
+	return nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+)
+
+func TestRequeueTaskResumesSync(t *testing.T) {
+	taskDao := dao.NewRepositoryTaskDAO()
+	task, err := taskDao.CreateRepositoryTask("https://github.com/example/requeue.git", "", models.LanguageEnglish, "tester", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Delete(&models.RepositoryTask{}, task.ID)
+	})
+
+	tests := []struct {
+		name    string
+		status  int
+		syncing bool
+		restart bool
+		want    int
+	}{
+		{"failed analysis", models.RepositoryStatusFailed, false, false, models.RepositoryStatusPending},
+		{"failed sync", models.RepositoryStatusFailed, true, false, models.RepositoryStatusUpdating},
+		{"cancelled sync", models.RepositoryStatusCancelled, true, false, models.RepositoryStatusUpdating},
+		{"regenerated sync", models.RepositoryStatusFailed, true, true, models.RepositoryStatusPending},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := taskDao.UpdateRepositoryTaskSyncing(task.ID, test.syncing); err != nil {
+				t.Fatal(err)
+			}
+			if err := taskDao.UpdateRepositoryTaskStatus(task.ID, test.status); err != nil {
+				t.Fatal(err)
+			}
+			requeue := RequeueTask
+			if test.restart {
+				requeue = RestartTask
+			}
+			if err := requeue(task.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			current, err := taskDao.GetRepositoryTaskByID(task.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current.Status != test.want {
+				t.Fatalf("requeued as %s, want %s", current.StatusString(), models.GetStatusString(test.want))
+			}
+			if current.Syncing != (test.want == models.RepositoryStatusUpdating) {
+				t.Fatalf("requeued with syncing %v, want it only for a sync", current.Syncing)
+			}
+		})
+	}
+}
+
+func TestCancelTaskAsWorkerStarts(t *testing.T) {
+	tq := NewTaskQueue(t.TempDir(), &config.TaskConfig{Workers: 1})
+	// the directory is missing, a task that is not cancelled fails at once
+	missing := filepath.Join(t.TempDir(), "missing")
+	newTask := func(idx int) *Task {
+		task, err := tq.taskDao.CreateLocalRepositoryTask(fmt.Sprintf("%s-%d", missing, idx), models.LanguageEnglish, "tester")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			database.GetDB().Unscoped().Delete(&models.RepositoryTask{}, task.ID)
+		})
+		return NewTaskFromModel(task)
+	}
+	// cancel marks the task as the cancel endpoint does, a running task is stopped
+	cancel := func(task *Task) {
+		if err := tq.taskDao.UpdateRepositoryTaskStatus(task.ID, models.RepositoryStatusCancelled); err != nil {
+			t.Error(err)
+		}
+		CancelTask(task.ID)
+	}
+
+	// the cancel and the worker race, the worker must never overwrite the cancelled status
+	for idx := 0; idx < 50; idx++ {
+		task := newTask(idx)
+		var (
+			start = make(chan struct{})
+			wg    sync.WaitGroup
+		)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			<-start
+			tq.runTask(task)
+		}()
+		go func() {
+			defer wg.Done()
+			<-start
+			cancel(task)
+		}()
+		close(start)
+		wg.Wait()
+
+		current, err := tq.taskDao.GetRepositoryTaskByID(task.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.Status != models.RepositoryStatusCancelled {
+			t.Fatalf("task %d is %s after the cancel, want cancelled", idx, current.StatusString())
+		}
+	}
+
+	// a worker still holding the task keeps the cancelled status
+	task := newTask(-1)
+	cancel(task)
+	if err := task.UpdateStatus(tq.processParams(), models.RepositoryStatusCloned); !errors.Is(err, ErrTaskCancelled) {
+		t.Fatalf("got error %v updating a cancelled task, want ErrTaskCancelled", err)
+	}
+	if current, _ := tq.taskDao.GetRepositoryTaskByID(task.ID); current.Status != models.RepositoryStatusCancelled {
+		t.Fatalf("cancelled task is %s, want cancelled", current.StatusString())
+	}
+}
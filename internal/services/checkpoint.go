@@ -0,0 +1,80 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/o0olele/opendeepwiki-go/internal/analyzer"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"go.uber.org/zap"
+)
+
+// documentCheckpoint stores the generated documents of a repository in the database.
+type documentCheckpoint struct {
+	repoId uint
+	dao    *dao.DocumentCheckpointDao
+
+	mu        sync.Mutex
+	documents map[string]*models.DocumentCheckpoint
+}
+
+func newDocumentCheckpoint(repoId uint) *documentCheckpoint {
+	c := &documentCheckpoint{
+		repoId:    repoId,
+		dao:       dao.NewDocumentCheckpointDao(),
+		documents: make(map[string]*models.DocumentCheckpoint),
+	}
+
+	checkpoints, err := c.dao.GetCheckpointsByRepoId(repoId)
+	if err != nil {
+		zap.L().Warn("load document checkpoints failed", zap.Uint("repository_id", repoId), zap.Error(err))
+		return c
+	}
+	for _, checkpoint := range checkpoints {
+		c.documents[checkpoint.Key] = checkpoint
+	}
+	if len(checkpoints) > 0 {
+		zap.L().Info("resume document generation", zap.Uint("repository_id", repoId), zap.Int("documents", len(checkpoints)))
+	}
+	return c
+}
+
+func (c *documentCheckpoint) Load(key string) (*analyzer.WikiDocument, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	checkpoint, ok := c.documents[key]
+	if !ok {
+		return nil, false
+	}
+	return &analyzer.WikiDocument{
+		Title:   checkpoint.Title,
+		Content: checkpoint.Content,
+	}, true
+}
+
+func (c *documentCheckpoint) Save(key string, doc *analyzer.WikiDocument) error {
+	checkpoint := &models.DocumentCheckpoint{
+		RepoId:  c.repoId,
+		Key:     key,
+		Title:   doc.Title,
+		Content: doc.Content,
+	}
+	if err := c.dao.SaveCheckpoint(checkpoint); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.documents[key] = checkpoint
+	return nil
+}
+
+// Clear removes the checkpoints once the documents are saved.
+func (c *documentCheckpoint) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.documents = make(map[string]*models.DocumentCheckpoint)
+	return c.dao.DeleteCheckpointsByRepoId(c.repoId)
+}
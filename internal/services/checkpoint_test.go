@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/o0olele/opendeepwiki-go/internal/analyzer"
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+)
+
+func TestDocumentCheckpoint(t *testing.T) {
+	const repoId, otherRepoId = 9301, 9302
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Where("repo_id IN ?", []uint{repoId, otherRepoId}).Delete(&models.DocumentCheckpoint{})
+	})
+
+	checkpoint := newDocumentCheckpoint(repoId)
+	for _, doc := range []struct{ key, title, content string }{
+		{"1", "Overview", "first draft"},
+		{"1.1", "Setup", "setup"},
+		{"1", "Overview", "second draft"},
+	} {
+		if err := checkpoint.Save(doc.key, &analyzer.WikiDocument{Title: doc.title, Content: doc.content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := newDocumentCheckpoint(otherRepoId).Save("1", &analyzer.WikiDocument{Title: "Other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a resumed task loads the documents saved by the previous run of its repository
+	resumed := newDocumentCheckpoint(repoId)
+	tests := []struct {
+		key     string
+		want    string
+		wantHit bool
+	}{
+		{"1", "second draft", true},
+		{"1.1", "setup", true},
+		{"2", "", false},
+	}
+	for _, test := range tests {
+		doc, ok := resumed.Load(test.key)
+		if ok != test.wantHit {
+			t.Fatalf("load %s: got hit %v, want %v", test.key, ok, test.wantHit)
+		}
+		if ok && doc.Content != test.want {
+			t.Fatalf("load %s: got %q, want %q", test.key, doc.Content, test.want)
+		}
+	}
+	var count int64
+	database.GetDB().Model(&models.DocumentCheckpoint{}).Where("repo_id = ?", repoId).Count(&count)
+	if count != 2 {
+		t.Fatalf("got %d checkpoints, want 2", count)
+	}
+
+	if err := resumed.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := newDocumentCheckpoint(repoId).Load("1"); ok {
+		t.Fatal("checkpoint loaded after clear")
+	}
+	if _, ok := newDocumentCheckpoint(otherRepoId).Load("1"); !ok {
+		t.Fatal("clear removed the checkpoint of another repository")
+	}
+}
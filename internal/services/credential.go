@@ -0,0 +1,113 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/utils"
+	"go.uber.org/zap"
+)
+
+// CreateGitCredential encrypts and saves a credential, it returns the credential id.
+func CreateGitCredential(credentialType, username, secret, passphrase string) (uint, error) {
+	if credentialType != models.GitCredentialToken && credentialType != models.GitCredentialSSH {
+		return 0, fmt.Errorf("unsupported credential type: %s", credentialType)
+	}
+	if len(secret) == 0 {
+		return 0, fmt.Errorf("credential secret is empty")
+	}
+
+	key := config.GetSecurityConfig().SecretKey
+	encryptedSecret, err := utils.Encrypt(key, secret)
+	if err != nil {
+		return 0, fmt.Errorf("encrypt credential failed: %w", err)
+	}
+
+	var encryptedPassphrase string
+	if len(passphrase) > 0 {
+		encryptedPassphrase, err = utils.Encrypt(key, passphrase)
+		if err != nil {
+			return 0, fmt.Errorf("encrypt credential failed: %w", err)
+		}
+	}
+
+	credential := &models.GitCredential{
+		Type:       credentialType,
+		Username:   username,
+		Secret:     encryptedSecret,
+		Passphrase: encryptedPassphrase,
+	}
+	if err = dao.NewGitCredentialDAO().CreateCredential(credential); err != nil {
+		return 0, err
+	}
+	return credential.ID, nil
+}
+
+// DeleteGitCredential removes a credential that is not attached to a task, 0 is ignored.
+func DeleteGitCredential(credentialID uint) {
+	if credentialID == 0 {
+		return
+	}
+	if err := dao.NewGitCredentialDAO().DeleteCredential(credentialID); err != nil {
+		zap.L().Warn("Failed to delete git credential", zap.Uint("credential_id", credentialID), zap.Error(err))
+	}
+}
+
+// gitAuth returns the go-git auth method of a credential, nil for public repositories.
+func gitAuth(credentialID uint) (transport.AuthMethod, error) {
+	if credentialID == 0 {
+		return nil, nil
+	}
+
+	credential, err := dao.NewGitCredentialDAO().GetCredentialByID(credentialID)
+	if err != nil {
+		return nil, fmt.Errorf("get credential failed: %w", err)
+	}
+
+	key := config.GetSecurityConfig().SecretKey
+	secret, err := utils.Decrypt(key, credential.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt credential failed: %w", err)
+	}
+
+	var username = credential.Username
+	if len(username) == 0 {
+		// token authentication only checks the password, ssh servers expect "git"
+		username = "git"
+	}
+
+	switch credential.Type {
+	case models.GitCredentialToken:
+		return &http.BasicAuth{
+			Username: username,
+			Password: secret,
+		}, nil
+	case models.GitCredentialSSH:
+		var passphrase string
+		if len(credential.Passphrase) > 0 {
+			passphrase, err = utils.Decrypt(key, credential.Passphrase)
+			if err != nil {
+				return nil, fmt.Errorf("decrypt credential failed: %w", err)
+			}
+		}
+		auth, err := ssh.NewPublicKeys(username, []byte(secret), passphrase)
+		if err != nil {
+			return nil, err
+		}
+		// without a configured file go-git reads ~/.ssh/known_hosts or SSH_KNOWN_HOSTS when it connects
+		if knownHosts := config.GetSecurityConfig().KnownHosts; len(knownHosts) > 0 {
+			auth.HostKeyCallback, err = ssh.NewKnownHostsCallback(knownHosts)
+			if err != nil {
+				return nil, fmt.Errorf("load known_hosts %s failed: %w", knownHosts, err)
+			}
+		}
+		return auth, nil
+	default:
+		return nil, fmt.Errorf("unsupported credential type: %s", credential.Type)
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// useSecurity replaces the security configuration for the test.
+func useSecurity(t *testing.T, secretKey, knownHosts string) {
+	security := config.GetSecurityConfig()
+	previous := *security
+	security.SecretKey, security.KnownHosts = secretKey, knownHosts
+	t.Cleanup(func() { *security = previous })
+}
+
+// newHostKey returns a new ssh key pair, the private key PEM encoded.
+func newHostKey(t *testing.T) (ssh.PublicKey, []byte) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sshPublic, pem.EncodeToMemory(block)
+}
+
+func TestGitAuthToken(t *testing.T) {
+	useSecurity(t, "test-secret", "")
+
+	tests := []struct {
+		name     string
+		username string
+		want     string
+	}{
+		{"default username", "", "git"},
+		{"username", "deploy", "deploy"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id, err := CreateGitCredential("token", test.username, "glpat-secret", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer DeleteGitCredential(id)
+
+			stored, err := dao.NewGitCredentialDAO().GetCredentialByID(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Secret == "glpat-secret" {
+				t.Fatal("the token is stored in plain text")
+			}
+
+			auth, err := gitAuth(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			basic, ok := auth.(*http.BasicAuth)
+			if !ok || basic.Username != test.want || basic.Password != "glpat-secret" {
+				t.Fatalf("got auth %#v, want user %s with the token", auth, test.want)
+			}
+		})
+	}
+
+	// the secret cannot be read with another key
+	id, err := CreateGitCredential("token", "", "glpat-secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteGitCredential(id)
+	config.GetSecurityConfig().SecretKey = "other-secret"
+	if _, err = gitAuth(id); err == nil {
+		t.Fatal("decrypted the token with another secret key")
+	}
+}
+
+func TestGitAuthSSHKnownHosts(t *testing.T) {
+	hostKey, _ := newHostKey(t)
+	otherKey, _ := newHostKey(t)
+	_, privateKey := newHostKey(t)
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize("gitlab.internal:22")}, hostKey)
+	if err := os.WriteFile(knownHosts, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	useSecurity(t, "test-secret", knownHosts)
+
+	id, err := CreateGitCredential("ssh", "", string(privateKey), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteGitCredential(id)
+
+	auth, err := gitAuth(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, ok := auth.(*gitssh.PublicKeys)
+	if !ok || keys.User != "git" || keys.HostKeyCallback == nil {
+		t.Fatalf("got auth %#v, want the user git checking the known hosts", auth)
+	}
+	address := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}
+	if err = keys.HostKeyCallback("gitlab.internal:22", address, hostKey); err != nil {
+		t.Fatalf("known host key rejected: %v", err)
+	}
+	if err = keys.HostKeyCallback("gitlab.internal:22", address, otherKey); err == nil {
+		t.Fatal("unknown host key accepted")
+	}
+
+	// a configured file that cannot be read fails before connecting
+	config.GetSecurityConfig().KnownHosts = filepath.Join(t.TempDir(), "missing")
+	if _, err = gitAuth(id); err == nil {
+		t.Fatal("missing known_hosts file accepted")
+	}
+}
+
+func TestDeleteGitCredential(t *testing.T) {
+	useSecurity(t, "test-secret", "")
+
+	id, err := CreateGitCredential("token", "", "glpat-secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	DeleteGitCredential(id)
+	if _, err = dao.NewGitCredentialDAO().GetCredentialByID(id); err == nil {
+		t.Fatal("the credential was not deleted")
+	}
+	// nothing is deleted for public repositories
+	DeleteGitCredential(0)
+}
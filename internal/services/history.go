@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/o0olele/opendeepwiki-go/internal/analyzer"
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"go.uber.org/zap"
+)
+
+// generateHistory summarises the commit groups into changelog records when the changelog is enabled,
+// it costs a model call per group. Groups whose newest commit is already summarised are skipped.
+func (t *Task) generateHistory(ctx context.Context, r *analyzer.Repository, repoId uint) error {
+	if !config.GetTaskConfig().Changelog {
+		return nil
+	}
+
+	groups, err := r.CommitGroups()
+	if err != nil {
+		return err
+	}
+
+	recordDao := dao.NewDocumentCommitRecordDao()
+	records, err := recordDao.GetRecordsByRepoId(repoId, models.DocumentRecordHistory)
+	if err != nil {
+		return err
+	}
+
+	var summarised = make(map[string]string)
+	for _, record := range records {
+		summarised[record.Title] = record.CommitID
+	}
+
+	for _, group := range groups {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if summarised[group.Title] == group.CommitID {
+			continue
+		}
+
+		record, err := r.SummariseCommits(ctx, group)
+		if errors.Is(err, ErrBudgetExceeded) {
+			return err
+		}
+		if err != nil {
+			zap.L().Warn("summarise commits failed", zap.String("title", group.Title), zap.Error(err))
+			continue
+		}
+
+		err = recordDao.SaveRecord(&models.DocumentCommitRecord{
+			RepoId:        repoId,
+			Type:          models.DocumentRecordHistory,
+			Title:         record.Title,
+			CommitMessage: record.Description,
+			Author:        record.Author,
+			CommitID:      record.CommitID,
+			Date:          record.Date,
+		})
+		if err != nil {
+			zap.L().Error("Failed to save commit record", zap.Error(err))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"time"
+
+	"github.com/o0olele/opendeepwiki-go/internal/analyzer"
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"go.uber.org/zap"
+)
+
+// defaultLLMCacheTTL keeps the cached responses for a week.
+const defaultLLMCacheTTL = 7 * 24 * time.Hour
+
+// llmCache stores the llm responses of a repository in the database.
+type llmCache struct {
+	repoId uint
+	ttl    time.Duration
+	dao    *dao.LLMCacheDao
+}
+
+func (c *llmCache) Get(key string) ([]byte, bool) {
+	entry, err := c.dao.GetCache(c.repoId, key)
+	if err != nil {
+		return nil, false
+	}
+	return []byte(entry.Response), true
+}
+
+func (c *llmCache) Put(key string, value []byte) error {
+	return c.dao.SaveCache(&models.LLMCache{
+		RepoId:    c.repoId,
+		Key:       key,
+		Response:  string(value),
+		ExpiresAt: time.Now().Add(c.ttl),
+	})
+}
+
+// useLLMCache lets the repository answer repeated requests from the cache when it is enabled,
+// a rerun of a failed task then only pays for the requests that changed.
+func useLLMCache(r *analyzer.Repository, repoId uint) {
+	llm := config.GetLLMConfig()
+	// recorded fixtures must see every request
+	if !llm.Cache.Enabled || len(llm.Replay.Mode) > 0 {
+		return
+	}
+
+	ttl := llm.Cache.TTL
+	if ttl <= 0 {
+		ttl = defaultLLMCacheTTL
+	}
+	r.SetCache(&llmCache{
+		repoId: repoId,
+		ttl:    ttl,
+		dao:    dao.NewLLMCacheDao(),
+	})
+}
+
+// InvalidateLLMCache removes the cached responses of a repository.
+func InvalidateLLMCache(repoId uint) (int64, error) {
+	count, err := dao.NewLLMCacheDao().DeleteCacheByRepoId(repoId)
+	if err != nil {
+		return 0, err
+	}
+	zap.L().Info("Invalidated llm cache", zap.Uint("repo_id", repoId), zap.Int64("entries", count))
+	return count, nil
+}
+
+// cleanLLMCache removes the expired responses every hour.
+func (tq *TaskQueue) cleanLLMCache() {
+	var ticker = time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	cacheDao := dao.NewLLMCacheDao()
+	for range ticker.C {
+		count, err := cacheDao.DeleteExpiredCache()
+		if err != nil {
+			zap.L().Warn("Failed to clean llm cache", zap.Error(err))
+			continue
+		}
+		if count > 0 {
+			zap.L().Info("Cleaned expired llm cache", zap.Int64("entries", count))
+		}
+	}
+}
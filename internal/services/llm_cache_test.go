@@ -0,0 +1,103 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+)
+
+func TestLLMCacheExpiry(t *testing.T) {
+	const repoId = 9101
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Where("repo_id = ?", repoId).Delete(&models.LLMCache{})
+	})
+
+	cache := &llmCache{repoId: repoId, ttl: time.Hour, dao: dao.NewLLMCacheDao()}
+	if err := cache.Put("fresh", []byte("answer")); err != nil {
+		t.Fatal(err)
+	}
+	expired := &llmCache{repoId: repoId, ttl: -time.Minute, dao: dao.NewLLMCacheDao()}
+	if err := expired.Put("expired", []byte("old answer")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		key   string
+		want  string
+		found bool
+	}{
+		{"fresh entry", "fresh", "answer", true},
+		{"expired entry", "expired", "", false},
+		{"missing entry", "missing", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, ok := cache.Get(test.key)
+			if ok != test.found || string(value) != test.want {
+				t.Fatalf("got %q and found %v, want %q and %v", value, ok, test.want, test.found)
+			}
+		})
+	}
+
+	// saving the key again replaces the entry and extends it
+	if err := cache.Put("expired", []byte("new answer")); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := cache.Get("expired"); !ok || string(value) != "new answer" {
+		t.Fatalf("got %q and found %v after saving the key again", value, ok)
+	}
+	var count int64
+	database.GetDB().Model(&models.LLMCache{}).Where("repo_id = ?", repoId).Count(&count)
+	if count != 2 {
+		t.Fatalf("%d entries, want 2", count)
+	}
+
+	// the cleanup only removes expired entries
+	if err := expired.Put("expired again", []byte("old answer")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.NewLLMCacheDao().DeleteExpiredCache(); err != nil {
+		t.Fatal(err)
+	}
+	database.GetDB().Unscoped().Model(&models.LLMCache{}).Where("repo_id = ?", repoId).Count(&count)
+	if count != 2 {
+		t.Fatalf("%d entries after the cleanup, want 2", count)
+	}
+}
+
+func TestInvalidateLLMCache(t *testing.T) {
+	const repoId, otherRepoId = 9102, 9103
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Where("repo_id IN ?", []uint{repoId, otherRepoId}).Delete(&models.LLMCache{})
+	})
+
+	caches := map[uint]*llmCache{}
+	for _, id := range []uint{repoId, otherRepoId} {
+		caches[id] = &llmCache{repoId: id, ttl: time.Hour, dao: dao.NewLLMCacheDao()}
+		for _, key := range []string{"readme", "catalogue"} {
+			if err := caches[id].Put(key, []byte(key)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	count, err := InvalidateLLMCache(repoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("removed %d entries, want 2", count)
+	}
+	for _, key := range []string{"readme", "catalogue"} {
+		if _, ok := caches[repoId].Get(key); ok {
+			t.Fatalf("entry %q of the invalidated repository is still cached", key)
+		}
+		if _, ok := caches[otherRepoId].Get(key); !ok {
+			t.Fatalf("entry %q of the other repository was removed", key)
+		}
+	}
+}
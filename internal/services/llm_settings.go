@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/llm/chat"
+	"github.com/o0olele/opendeepwiki-go/internal/utils"
+	"github.com/tmc/langchaingo/llms"
+	"go.uber.org/zap"
+)
+
+// llmTestTimeout bounds the completion made to test llm settings.
+const llmTestTimeout = 30 * time.Second
+
+// encryptedKeyPrefix marks the api keys encrypted with the secret key, keys saved before are plain text.
+const encryptedKeyPrefix = "enc:"
+
+// SetLLMSettingsKey encrypts the api key into the settings, like the repository credentials.
+func SetLLMSettingsKey(settings *models.LLMSettings, apiKey string) error {
+	if len(apiKey) == 0 {
+		settings.APIKey = ""
+		return nil
+	}
+	encrypted, err := utils.Encrypt(config.GetSecurityConfig().SecretKey, apiKey)
+	if err != nil {
+		return fmt.Errorf("encrypt api key failed: %w", err)
+	}
+	settings.APIKey = encryptedKeyPrefix + encrypted
+	return nil
+}
+
+// LLMSettingsKey returns the api key of the settings.
+func LLMSettingsKey(settings *models.LLMSettings) (string, error) {
+	if !strings.HasPrefix(settings.APIKey, encryptedKeyPrefix) {
+		return settings.APIKey, nil
+	}
+	apiKey, err := utils.Decrypt(config.GetSecurityConfig().SecretKey, strings.TrimPrefix(settings.APIKey, encryptedKeyPrefix))
+	if err != nil {
+		return "", fmt.Errorf("decrypt api key failed: %w", err)
+	}
+	return apiKey, nil
+}
+
+// EncryptLLMSettingsKeys encrypts the api keys still saved in plain text, such as the key the first
+// profile copies from the llm section. They are left as they are until a secret key is configured.
+func EncryptLLMSettingsKeys() error {
+	settingsDao := dao.NewLLMSettingsDAO()
+	list, err := settingsDao.ListSettings()
+	if err != nil {
+		return err
+	}
+
+	for _, settings := range list {
+		if len(settings.APIKey) == 0 || strings.HasPrefix(settings.APIKey, encryptedKeyPrefix) {
+			continue
+		}
+		if len(config.GetSecurityConfig().SecretKey) == 0 {
+			zap.L().Warn("LLM api keys are saved in plain text, configure security.secret_key to encrypt them")
+			return nil
+		}
+		if err = SetLLMSettingsKey(settings, settings.APIKey); err != nil {
+			return err
+		}
+		if err = settingsDao.UpdateSettings(settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyLLMSettings makes the settings the live llm configuration, running tasks keep their provider.
+func ApplyLLMSettings(settings *models.LLMSettings) error {
+	apiKey, err := LLMSettingsKey(settings)
+	if err != nil {
+		return err
+	}
+
+	llm := *config.GetLLMConfig()
+	llm.ProviderType = settings.ProviderType
+	llm.APIKey = apiKey
+	llm.Model = settings.ModelLLM
+	llm.BaseURL = settings.BaseURL
+	llm.APIVersion = settings.APIVersion
+	llm.MaxTokens = settings.MaxTokens
+	llm.Temperature = settings.Temperature
+	llm.ContextWindow = settings.ContextWindow
+	config.SetLLMConfig(llm)
+
+	zap.L().Info("Applied LLM settings", zap.Uint("settings_id", settings.ID), zap.String("provider", settings.ProviderType), zap.String("model", settings.ModelLLM))
+	return nil
+}
+
+// TestLLMSettings makes a tiny completion with the settings and returns the reply.
+func TestLLMSettings(ctx context.Context, settings *models.LLMSettings) (string, error) {
+	apiKey, err := LLMSettingsKey(settings)
+	if err != nil {
+		return "", err
+	}
+
+	provider, err := chat.NewProvider(&chat.ProviderConfig{
+		Type:          chat.ProviderType(settings.ProviderType),
+		APIKey:        apiKey,
+		Model:         settings.ModelLLM,
+		BaseURL:       settings.BaseURL,
+		APIVersion:    settings.APIVersion,
+		MaxTokens:     settings.MaxTokens,
+		Temperature:   settings.Temperature,
+		ContextWindow: settings.ContextWindow,
+	})
+	if err != nil {
+		return "", fmt.Errorf("create llm provider failed: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, llmTestTimeout)
+	defer cancel()
+
+	message := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, "Reply with OK."),
+	}
+	response, err := provider.GetModel().GenerateContent(ctx, message,
+		llms.WithMaxTokens(16),
+	)
+	if err != nil {
+		return "", err
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("empty response")
+	}
+	return response.Choices[0].Content, nil
+}
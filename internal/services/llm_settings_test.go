@@ -0,0 +1,59 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+)
+
+func TestEncryptLLMSettingsKeys(t *testing.T) {
+	settingsDao := dao.NewLLMSettingsDAO()
+	// a profile saved before the keys were encrypted
+	plain := &models.LLMSettings{ProviderType: "openai", ModelLLM: "plain-model", APIKey: "sk-plain-key"}
+	if err := settingsDao.CreateSettings(plain); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Delete(&models.LLMSettings{}, plain.ID)
+	})
+
+	// without a secret key the plain keys still work
+	useSecurity(t, "", "")
+	if err := EncryptLLMSettingsKeys(); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := settingsDao.GetSettingsByID(plain.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := LLMSettingsKey(settings); err != nil || key != "sk-plain-key" {
+		t.Fatalf("got key %q (%v), want the plain key", key, err)
+	}
+
+	useSecurity(t, "test-secret", "")
+	if err = EncryptLLMSettingsKeys(); err != nil {
+		t.Fatal(err)
+	}
+	settings, err = settingsDao.GetSettingsByID(plain.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(settings.APIKey, "sk-plain-key") {
+		t.Fatalf("key %q is still saved in plain text", settings.APIKey)
+	}
+	if key, err := LLMSettingsKey(settings); err != nil || key != "sk-plain-key" {
+		t.Fatalf("got key %q (%v), want the decrypted key", key, err)
+	}
+
+	// an encrypted key is not encrypted twice
+	encrypted := settings.APIKey
+	if err = EncryptLLMSettingsKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if settings, err = settingsDao.GetSettingsByID(plain.ID); err != nil || settings.APIKey != encrypted {
+		t.Fatalf("got key %q (%v), want it unchanged", settings.APIKey, err)
+	}
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	dir, err := os.MkdirTemp("", "opendeepwiki-services-")
+	if err != nil {
+		panic(err)
+	}
+	config.LoadTemplates()
+	repoConfig := config.GetRepositoryConfig()
+	repoConfig.Dir = filepath.Join(dir, "repos")
+	repoConfig.Code = filepath.Join(dir, "code")
+	repoConfig.Vector = filepath.Join(dir, "vector")
+	// the sample repository has no source files the code index embeds
+	embeddingConfig := config.GetEmbeddingConfig()
+	embeddingConfig.APIKey = "unused"
+	embeddingConfig.BaseURL = "http://127.0.0.1:1/v1"
+	if err = database.InitDB(filepath.Join(dir, "test.db")); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
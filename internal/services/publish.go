@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/o0olele/opendeepwiki-go/internal/analyzer"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/export"
+)
+
+// PublishDocuments commits the wiki of a task as Markdown files on a new branch and pushes it
+// with the credential of the task. The publish is recorded as a document commit record of its own
+// type, it is not part of the changelog.
+func PublishDocuments(ctx context.Context, task *models.RepositoryTask, options *analyzer.PublishOptions) (*models.DocumentCommitRecord, error) {
+	repoModal, err := dao.NewRepositoryDAO().GetRepositoryByGitURLAndBranch(task.GitURL, task.Ref)
+	if err != nil {
+		return nil, fmt.Errorf("repository not found")
+	}
+
+	docs, err := dao.NewDocumentDao().GetDocumentByRepoId(repoModal.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("repository has no documents")
+	}
+
+	files, err := export.Build(repoModal.Name, repoModal.Overview, docs, export.FormatMarkdown)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := analyzer.NewRepositoryFromModel(repoModal)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := gitAuth(task.CredentialID)
+	if err != nil {
+		return nil, err
+	}
+	r.SetAuth(auth)
+
+	commit, err := r.Publish(ctx, files, options)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.DocumentCommitRecord{
+		RepoId:        repoModal.ID,
+		Type:          models.DocumentRecordPublish,
+		Title:         "Published to " + options.Branch,
+		CommitMessage: options.Message,
+		Author:        options.Author,
+		CommitID:      commit,
+		Date:          time.Now(),
+	}
+	if err = dao.NewDocumentCommitRecordDao().CreateRecord(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/o0olele/opendeepwiki-go/internal/analyzer"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/utils"
+	"go.uber.org/zap"
+)
+
+// remoteCheckTimeout bounds the time spent listing the references of one remote.
+const remoteCheckTimeout = time.Minute
+
+var (
+	ErrTaskNotCompleted = errors.New("only completed repositories and stopped syncs can be refreshed")
+	ErrLocalRepository  = errors.New("imported repositories have no remote to refresh from")
+)
+
+// wakeChan makes the queue load new tasks without waiting for the next poll.
+var wakeChan = make(chan struct{}, 1)
+
+func wakeQueue() {
+	select {
+	case wakeChan <- struct{}{}:
+	default:
+	}
+}
+
+// RefreshTask queues an incremental refresh of a completed git repository or of a sync that failed
+// or was cancelled.
+func RefreshTask(task *models.RepositoryTask) error {
+	if task.Source == models.RepositorySourceLocal {
+		return ErrLocalRepository
+	}
+	if task.Status != models.RepositoryStatusCompleted && !syncStopped(task) {
+		return ErrTaskNotCompleted
+	}
+
+	taskDao := dao.NewRepositoryTaskDAO()
+	if err := taskDao.UpdateRepositoryTaskSyncing(task.ID, true); err != nil {
+		return err
+	}
+	if err := taskDao.UpdateRepositoryTaskStatus(task.ID, models.RepositoryStatusUpdating); err != nil {
+		return err
+	}
+	task.Status = models.RepositoryStatusUpdating
+
+	taskQueued(task.ID, models.RepositoryStatusUpdating)
+	return nil
+}
+
+// syncStopped reports whether a sync of the task failed or was cancelled. The wiki is complete up to
+// the documented commit, a new sync covers the remaining changes.
+func syncStopped(task *models.RepositoryTask) bool {
+	return task.Syncing && (task.Status == models.RepositoryStatusFailed || task.Status == models.RepositoryStatusCancelled)
+}
+
+// RefreshOrDefer queues a refresh of a completed git repository, a task still being generated
+// or synced is marked and syncs again once it completes. It reports whether the refresh was deferred.
+func RefreshOrDefer(task *models.RepositoryTask) (bool, error) {
+	switch task.Status {
+	case models.RepositoryStatusPending, models.RepositoryStatusCloned, models.RepositoryStatusAnalyzed, models.RepositoryStatusUpdating:
+	default:
+		return false, RefreshTask(task)
+	}
+	if task.Source == models.RepositorySourceLocal {
+		return false, ErrLocalRepository
+	}
+
+	taskDao := dao.NewRepositoryTaskDAO()
+	if err := taskDao.SetRepositoryTaskRefreshPending(task.ID, true); err != nil {
+		return false, err
+	}
+
+	// the task may have completed before the mark was set, nothing would pick it up then
+	current, err := taskDao.GetRepositoryTaskByID(task.ID)
+	if err == nil && current.Status == models.RepositoryStatusCompleted {
+		if taken, _ := taskDao.TakeRepositoryTaskRefreshPending(task.ID); taken {
+			return false, RefreshTask(current)
+		}
+	}
+	return true, nil
+}
+
+// scheduleRefresh checks the remote of every scheduled repository when its schedule is due,
+// a sync is queued only when the remote branch moved past the documented commit.
+func (tq *TaskQueue) scheduleRefresh() {
+	var ticker = time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		repos, err := tq.repoDao.ListScheduledRepositories()
+		if err != nil {
+			continue
+		}
+
+		for _, repo := range repos {
+			schedule, err := utils.ParseSchedule(repo.RefreshSchedule)
+			if err != nil {
+				zap.L().Warn("Invalid refresh schedule", zap.Uint("repo_id", repo.ID), zap.String("schedule", repo.RefreshSchedule), zap.Error(err))
+				continue
+			}
+
+			next := schedule.Next(repo.RefreshedAt)
+			if next.IsZero() || next.After(now) {
+				continue
+			}
+			// a failed check waits for the next schedule instead of retrying every minute
+			tq.repoDao.UpdateRepositoryRefreshedAt(repo.ID, now)
+
+			if err = tq.checkRemote(repo); err != nil {
+				zap.L().Warn("Failed to check repository remote", zap.Uint("repo_id", repo.ID), zap.String("git_url", repo.GitURL), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (tq *TaskQueue) checkRemote(repoModal *models.Repository) error {
+	task, err := tq.taskDao.GetRepositoryTaskByGitURL(repoModal.GitURL, repoModal.Branch)
+	if err != nil {
+		return err
+	}
+	// repositories being generated or synced are checked on the next schedule
+	if (task.Status != models.RepositoryStatusCompleted && !syncStopped(task)) || task.Source == models.RepositorySourceLocal {
+		return nil
+	}
+
+	auth, err := gitAuth(task.CredentialID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteCheckTimeout)
+	defer cancel()
+
+	head, err := analyzer.RemoteHead(ctx, repoModal.Path, auth)
+	if errors.Is(err, analyzer.ErrDetachedHead) {
+		// pinned tags and commits never change
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if head == repoModal.CommitID {
+		return nil
+	}
+
+	zap.L().Info("Remote changed, queue repository sync", zap.String("git_url", repoModal.GitURL), zap.String("commit", head))
+	return RefreshTask(task)
+}
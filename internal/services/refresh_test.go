@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+)
+
+func TestRefreshTaskRetriesStoppedSync(t *testing.T) {
+	taskDao := dao.NewRepositoryTaskDAO()
+	task, err := taskDao.CreateRepositoryTask("https://github.com/example/refresh.git", "", models.LanguageEnglish, "tester", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Delete(&models.RepositoryTask{}, task.ID)
+	})
+
+	tests := []struct {
+		name    string
+		status  int
+		syncing bool
+		wantErr error
+	}{
+		{"completed", models.RepositoryStatusCompleted, false, nil},
+		{"failed sync", models.RepositoryStatusFailed, true, nil},
+		{"cancelled sync", models.RepositoryStatusCancelled, true, nil},
+		{"failed analysis", models.RepositoryStatusFailed, false, ErrTaskNotCompleted},
+		{"running sync", models.RepositoryStatusUpdating, true, ErrTaskNotCompleted},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := taskDao.UpdateRepositoryTaskSyncing(task.ID, test.syncing); err != nil {
+				t.Fatal(err)
+			}
+			if err := taskDao.UpdateRepositoryTaskStatus(task.ID, test.status); err != nil {
+				t.Fatal(err)
+			}
+			current, err := taskDao.GetRepositoryTaskByID(task.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = RefreshTask(current)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+			if test.wantErr != nil {
+				return
+			}
+			if current, err = taskDao.GetRepositoryTaskByID(task.ID); err != nil {
+				t.Fatal(err)
+			}
+			if current.Status != models.RepositoryStatusUpdating || !current.Syncing {
+				t.Fatalf("refreshed as %s with syncing %v, want a sync", current.StatusString(), current.Syncing)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/o0olele/opendeepwiki-go/internal/analyzer"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"go.uber.org/zap"
+)
+
+// ResearchTask represents a deep research task to be processed.
+type ResearchTask struct {
+	ID           uint   `json:"id"` // research task id
+	RepositoryID uint   `json:"repository_id"`
+	Question     string `json:"question"`
+	Rounds       int    `json:"rounds"`
+	Status       int    `json:"status"`
+}
+
+// NewResearchTaskFromModel creates a new ResearchTask from a models.ResearchTask.
+func NewResearchTaskFromModel(task *models.ResearchTask) *ResearchTask {
+	return &ResearchTask{
+		ID:           task.ID,
+		RepositoryID: task.RepositoryID,
+		Question:     task.Question,
+		Rounds:       task.Rounds,
+		Status:       task.Status,
+	}
+}
+
+// Process runs the research iterations and saves the conclusion, a cancelled research is left to the caller.
+func (t *ResearchTask) Process(ctx context.Context, params *TaskProcessParams) {
+	err := t.UpdateStatus(params, models.ResearchStatusRunning)
+	if err != nil {
+		return
+	}
+
+	// a research interrupted by a restart starts over, its iterations are numbered again
+	if err = params.researchDao.DeleteResearchIterations(t.ID); err != nil {
+		zap.L().Warn("Failed to delete research iterations", zap.Uint("research_id", t.ID), zap.Error(err))
+	}
+
+	conclusion, err := t.research(ctx, params)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		zap.L().Error("Failed to process research task", zap.Uint("research_id", t.ID), zap.Error(err))
+		params.researchDao.UpdateResearchTaskErrors(t.ID, err.Error())
+		t.UpdateStatus(params, models.ResearchStatusFailed)
+		return
+	}
+
+	params.researchDao.UpdateResearchTaskConclusion(t.ID, conclusion)
+	t.UpdateStatus(params, models.ResearchStatusCompleted)
+}
+
+// UpdateStatus updates the research task status.
+func (t *ResearchTask) UpdateStatus(params *TaskProcessParams, status int) error {
+	err := params.researchDao.UpdateResearchTaskStatus(t.ID, status)
+	if err != nil {
+		return err
+	}
+
+	t.Status = status
+	return nil
+}
+
+func (t *ResearchTask) research(ctx context.Context, params *TaskProcessParams) (string, error) {
+	repoModel, err := params.repoDao.GetRepositoryByID(t.RepositoryID)
+	if err != nil {
+		return "", fmt.Errorf("repository not found")
+	}
+
+	r, err := analyzer.NewRepositoryFromModel(repoModel)
+	if err != nil {
+		return "", err
+	}
+
+	if err = r.LoadCodeIndex(); err != nil {
+		zap.L().Warn("load code index failed", zap.Uint("repository_id", repoModel.ID), zap.Error(err))
+	}
+
+	return r.Research(ctx, t.Question, t.Rounds, func(index int, content string) error {
+		zap.L().Info("Research iteration finished", zap.Uint("research_id", t.ID), zap.Int("index", index))
+		return params.researchDao.CreateResearchIteration(t.ID, index, content)
+	})
+}
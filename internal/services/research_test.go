@@ -0,0 +1,94 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/llm/chat"
+)
+
+// useAnsweringLLM configures an OpenAI compatible server that streams answer to every request.
+func useAnsweringLLM(t *testing.T, answer string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(`data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"` + answer + `"}}]}` + "\n\n"))
+		w.Write([]byte(`data: {"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}` + "\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	previous := *config.GetLLMConfig()
+	config.SetLLMConfig(config.LLMConfig{
+		ProviderType: string(chat.ProviderVLLM),
+		BaseURL:      server.URL + "/v1",
+		Model:        "research-model",
+		MaxTokens:    1024,
+	})
+	t.Cleanup(func() {
+		config.SetLLMConfig(previous)
+	})
+}
+
+func TestQueuedResearchCompletes(t *testing.T) {
+	useAnsweringLLM(t, "The library prints a greeting.")
+
+	path := t.TempDir()
+	if err := os.WriteFile(filepath.Join(path, "README.md"), []byte("# Greeting\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tq := NewTaskQueue(t.TempDir(), &config.TaskConfig{Workers: 1})
+	repoModel, err := tq.repoDao.CreateRepository("https://github.com/example/research.git", "", "research", path, models.RepositoryStatusCompleted, models.LanguageEnglish)
+	if err != nil {
+		t.Fatal(err)
+	}
+	research, err := tq.researchDao.CreateResearchTask(repoModel.ID, "What does the library do?", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db := database.GetDB()
+		db.Unscoped().Where("research_id = ?", research.ID).Delete(&models.ResearchIteration{})
+		db.Unscoped().Delete(&models.ResearchTask{}, research.ID)
+		db.Unscoped().Delete(&models.Repository{}, repoModel.ID)
+	})
+
+	// the consumer polls every 10 seconds, the wake up must load the task before that
+	go tq.processResearch()
+	tq.ResearchQueued()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		research, err = tq.researchDao.GetResearchTaskByID(research.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if research.Status == models.ResearchStatusCompleted || research.Status == models.ResearchStatusFailed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("research is still %d, want it picked up and completed", research.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if research.Status != models.ResearchStatusCompleted {
+		t.Fatalf("research failed: %s", research.Errors)
+	}
+	if research.Conclusion != "The library prints a greeting." {
+		t.Fatalf("got conclusion %q", research.Conclusion)
+	}
+
+	iterations, err := tq.researchDao.ListResearchIterations(research.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(iterations) != 2 {
+		t.Fatalf("got %d iterations, want 2", len(iterations))
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+// fairScheduler orders queued tasks round-robin across owners, so one owner
+// submitting many repositories cannot occupy every worker.
+type fairScheduler struct {
+	maxPerOwner int                // maximum running tasks per owner, 0 means unlimited
+	queues      map[string][]*Task // queued tasks per owner
+	owners      []string           // owners in round-robin order
+	next        int                // owner to look at first on the next pop
+	running     map[string]int     // running tasks per owner
+	known       map[uint]bool      // queued or running task ids
+}
+
+func newFairScheduler(maxPerOwner int) *fairScheduler {
+	return &fairScheduler{
+		maxPerOwner: maxPerOwner,
+		queues:      make(map[string][]*Task),
+		running:     make(map[string]int),
+		known:       make(map[uint]bool),
+	}
+}
+
+// Push queues a task, it returns false if the task is already queued or running.
+func (s *fairScheduler) Push(task *Task) bool {
+	if s.known[task.ID] {
+		return false
+	}
+	s.known[task.ID] = true
+
+	if _, ok := s.queues[task.Owner]; !ok && s.running[task.Owner] == 0 {
+		s.owners = append(s.owners, task.Owner)
+	}
+	s.queues[task.Owner] = append(s.queues[task.Owner], task)
+	return true
+}
+
+// Pop returns the next task to run, or nil if no owner may start a task.
+func (s *fairScheduler) Pop() *Task {
+	for i := 0; i < len(s.owners); i++ {
+		idx := (s.next + i) % len(s.owners)
+		owner := s.owners[idx]
+
+		queue := s.queues[owner]
+		if len(queue) == 0 {
+			continue
+		}
+		if s.maxPerOwner > 0 && s.running[owner] >= s.maxPerOwner {
+			continue
+		}
+
+		task := queue[0]
+		if len(queue) == 1 {
+			delete(s.queues, owner)
+		} else {
+			s.queues[owner] = queue[1:]
+		}
+		s.running[owner]++
+		s.next = idx + 1
+		return task
+	}
+	return nil
+}
+
+// Done marks a running task as finished.
+func (s *fairScheduler) Done(task *Task) {
+	delete(s.known, task.ID)
+	s.running[task.Owner]--
+	if s.running[task.Owner] > 0 {
+		return
+	}
+	delete(s.running, task.Owner)
+	if _, ok := s.queues[task.Owner]; ok {
+		return
+	}
+
+	// the owner has nothing left, drop it from the round-robin order
+	for idx, owner := range s.owners {
+		if owner != task.Owner {
+			continue
+		}
+		s.owners = append(s.owners[:idx], s.owners[idx+1:]...)
+		if s.next > idx {
+			s.next--
+		}
+		break
+	}
+}
+
+// Running returns the number of running tasks.
+func (s *fairScheduler) Running() int {
+	var total int
+	for _, count := range s.running {
+		total += count
+	}
+	return total
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+// popAll pops tasks until the scheduler has none it may start and returns their ids.
+func popAll(scheduler *fairScheduler) []uint {
+	var ids []uint
+	for task := scheduler.Pop(); task != nil; task = scheduler.Pop() {
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
+
+func TestFairSchedulerRoundRobin(t *testing.T) {
+	scheduler := newFairScheduler(0)
+	for _, task := range []*Task{
+		{ID: 1, Owner: "alice"}, {ID: 2, Owner: "alice"}, {ID: 3, Owner: "alice"},
+		{ID: 4, Owner: "bob"},
+		{ID: 5, Owner: "carol"}, {ID: 6, Owner: "carol"},
+	} {
+		if !scheduler.Push(task) {
+			t.Fatalf("task %d was not queued", task.ID)
+		}
+	}
+
+	if got, want := popAll(scheduler), []uint{1, 4, 5, 2, 6, 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("popped %v, want %v", got, want)
+	}
+	if scheduler.Running() != 6 {
+		t.Fatalf("%d tasks running, want 6", scheduler.Running())
+	}
+}
+
+func TestFairSchedulerMaxPerOwner(t *testing.T) {
+	scheduler := newFairScheduler(1)
+	alice1, alice2, bob := &Task{ID: 1, Owner: "alice"}, &Task{ID: 2, Owner: "alice"}, &Task{ID: 3, Owner: "bob"}
+	for _, task := range []*Task{alice1, alice2, bob} {
+		scheduler.Push(task)
+	}
+
+	// the second task of alice waits for the first one
+	if got, want := popAll(scheduler), []uint{1, 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("popped %v, want %v", got, want)
+	}
+	scheduler.Done(bob)
+	if task := scheduler.Pop(); task != nil {
+		t.Fatalf("popped task %d while alice is at the limit", task.ID)
+	}
+	scheduler.Done(alice1)
+	if got, want := popAll(scheduler), []uint{2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("popped %v, want %v", got, want)
+	}
+	scheduler.Done(alice2)
+	if scheduler.Running() != 0 || len(scheduler.owners) != 0 {
+		t.Fatalf("%d tasks running and owners %v left, want none", scheduler.Running(), scheduler.owners)
+	}
+}
+
+func TestFairSchedulerSkipsKnownTasks(t *testing.T) {
+	scheduler := newFairScheduler(0)
+	task := &Task{ID: 1, Owner: "alice"}
+	if !scheduler.Push(task) || scheduler.Push(task) {
+		t.Fatal("a queued task was queued again")
+	}
+	scheduler.Pop()
+	if scheduler.Push(task) {
+		t.Fatal("a running task was queued again")
+	}
+	scheduler.Done(task)
+	if !scheduler.Push(task) {
+		t.Fatal("a finished task was not queued again")
+	}
+}
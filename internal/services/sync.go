@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/o0olele/opendeepwiki-go/internal/analyzer"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/progress"
+	"go.uber.org/zap"
+)
+
+// Sync pulls the repository and regenerates only the documents affected by the new commits.
+func (t *Task) Sync(ctx context.Context, params *TaskProcessParams) error {
+	err := t.sync(ctx, params)
+	if err != nil {
+		zap.L().Error("Failed to sync repository", zap.Uint("task_id", t.ID), zap.Error(err))
+		return err
+	}
+
+	// the sync is done, a later failure is retried from scratch
+	if err = params.taskDao.UpdateRepositoryTaskSyncing(t.ID, false); err != nil {
+		return err
+	}
+	return t.UpdateStatus(params, models.RepositoryStatusCompleted)
+}
+
+func (t *Task) sync(ctx context.Context, params *TaskProcessParams) error {
+	repoModal, err := params.repoDao.GetRepositoryByGitURLAndBranch(t.GitURL, t.Ref)
+	if err != nil {
+		return fmt.Errorf("repository not found")
+	}
+
+	r, err := analyzer.NewRepositoryFromModel(repoModal)
+	if err != nil {
+		return err
+	}
+	r.SetReporter(t.reporter)
+	useLLMCache(r, repoModal.ID)
+	useLLMMeter(r, t.ID, repoModal.ID)
+
+	auth, err := gitAuth(t.CredentialID)
+	if err != nil {
+		return err
+	}
+	r.SetAuth(auth)
+
+	t.reporter.Stage(progress.StageClone, "pull")
+	if err = r.Pull(ctx); err != nil {
+		return err
+	}
+
+	head, err := r.HeadCommit()
+	if err != nil {
+		return err
+	}
+
+	if len(repoModal.CommitID) == 0 {
+		// documented before commits were recorded, take the current head as the baseline
+		zap.L().Warn("No documented commit recorded, skip sync", zap.String("git_url", t.GitURL))
+		return params.repoDao.UpdateRepositoryCommit(repoModal.ID, head)
+	}
+
+	gitUpdate, err := r.ChangesSince(repoModal.CommitID)
+	if err != nil {
+		return err
+	}
+	if len(gitUpdate) == 0 {
+		zap.L().Info("Repository is up to date", zap.String("git_url", t.GitURL))
+		return nil
+	}
+
+	files, err := r.FilesChangedSince(repoModal.CommitID)
+	if err != nil {
+		return err
+	}
+
+	// the file tree and the code index are stale after the pull
+	if err = r.RefreshCatalogue(); err != nil {
+		return err
+	}
+
+	// the structure of the repository only changes with added or deleted files
+	if len(files.Added) > 0 || len(files.Deleted) > 0 {
+		t.stage(r, progress.StageCatalogue)
+		r.StructedCatalogue, err = r.GenerateStructedCatalogue(ctx)
+		if err != nil {
+			return err
+		}
+		params.repoDao.UpdateRepositoryCatalogue(repoModal.ID, r.StructedCatalogue)
+	}
+
+	t.reporter.Stage(progress.StageIndex, "")
+	// only the changed files are indexed again, the previous files are removed once the new ones are recorded
+	previousCode, previousVector := r.StructedCodePath, r.StructedVectorPath
+	if err = r.UpdateCodeIndex(files); err != nil {
+		return err
+	}
+	params.repoDao.UpdateRepositoryCodePath(repoModal.ID, r.StructedCodePath)
+	params.repoDao.UpdateRepositoryVectorPath(repoModal.ID, r.StructedVectorPath)
+	if previousCode != r.StructedCodePath {
+		analyzer.RemoveCodeIndex(previousCode, previousVector)
+	}
+
+	docDao := dao.NewDocumentDao()
+	docs, err := docDao.GetDocumentByRepoId(repoModal.ID)
+	if err != nil {
+		return err
+	}
+
+	t.stage(r, progress.StageThink)
+	update, err := r.GenerateDocumentUpdate(ctx, gitUpdate, documentCatalogueString(docs))
+	if err != nil {
+		return err
+	}
+
+	// keep the old commit when a document failed, so the next sync or a retry covers the remaining changes
+	err = applyDocumentUpdate(ctx, r.GenerateDocument, docDao, repoModal.ID, docs, update)
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		// keep the old commit so the next sync or a retry covers the remaining changes
+		return ctx.Err()
+	}
+
+	// the documents are up to date, a spent budget only leaves the changelog behind
+	if err = params.repoDao.UpdateRepositoryCommit(repoModal.ID, head); err != nil {
+		return err
+	}
+	if err = t.generateHistory(ctx, r, repoModal.ID); err != nil {
+		zap.L().Warn("generate repository history failed", zap.Error(err))
+		if errors.Is(err, ErrBudgetExceeded) {
+			return err
+		}
+	}
+	return nil
+}
+
+// documentCatalogueString describes the existing documents for the model, the id is the document index.
+func documentCatalogueString(docs []*models.Document) string {
+	type item struct {
+		ID       int    `json:"id"`
+		Title    string `json:"title"`
+		ParentID uint   `json:"parent_id"`
+	}
+	var items = make([]item, 0, len(docs))
+	for _, doc := range docs {
+		items = append(items, item{
+			ID:       doc.Index,
+			Title:    doc.Title,
+			ParentID: doc.ParentId,
+		})
+	}
+	s, _ := json.Marshal(items)
+	return string(s)
+}
+
+// generateDocumentFunc generates the content of a catalogue item, see analyzer.Repository.GenerateDocument.
+type generateDocumentFunc func(ctx context.Context, item *analyzer.DocumentResultCalalogueItem) (*analyzer.WikiDocument, error)
+
+// applyDocumentUpdate deletes, regenerates and adds documents as decided by the model.
+// A document that cannot be generated does not stop the others, the failures are returned together.
+// The commit is kept then and the next sync or a retry applies the update again, a document added
+// already under the same parent and title is not added twice.
+func applyDocumentUpdate(ctx context.Context, generate generateDocumentFunc, docDao *dao.DocumentDao, repoId uint, docs []*models.Document, update *analyzer.DocumentUpdateCatalogue) error {
+	var (
+		docMap   = make(map[int]*models.Document)
+		maxIndex int
+		rootId   int
+	)
+	for _, doc := range docs {
+		docMap[doc.Index] = doc
+		if doc.Index > maxIndex {
+			maxIndex = doc.Index
+		}
+		if doc.ParentId == 0 && doc.Title == "" {
+			rootId = doc.Index
+		}
+	}
+
+	// delete the documents and their descendants
+	var deletes []int
+	for _, id := range update.DeleteID {
+		index, ok := parseDocumentIndex(id)
+		if !ok || index == rootId {
+			continue
+		}
+		deletes = append(deletes, descendantIndexes(docs, index)...)
+	}
+	if err := docDao.DeleteDocumentsByRepoIdAndIndexes(repoId, deletes); err != nil {
+		return err
+	}
+	for _, index := range deletes {
+		delete(docMap, index)
+	}
+
+	var failures []error
+	var apply func(items []analyzer.DocumentUpdateItem, parentId int)
+	apply = func(items []analyzer.DocumentUpdateItem, parentId int) {
+		for idx := range items {
+			item := &items[idx]
+			if ctx.Err() != nil {
+				return
+			}
+
+			var current = parentId
+			switch item.Type {
+			case analyzer.DocumentUpdateUpdate:
+				index, ok := parseDocumentIndex(item.ID)
+				doc, exists := docMap[index]
+				if !ok || !exists {
+					zap.L().Warn("Document to update not found", zap.Any("id", item.ID))
+					continue
+				}
+				wiki, err := generate(ctx, item.CatalogueItem())
+				if err != nil {
+					failures = append(failures, fmt.Errorf("update document %s failed: %w", item.Title, err))
+					continue
+				}
+				doc.Title = wiki.Title
+				doc.Content = wiki.Content
+				if err = docDao.UpdateDocument(doc); err != nil {
+					zap.L().Error("Failed to update document", zap.Error(err))
+					failures = append(failures, err)
+				}
+				current = doc.Index
+			default:
+				if doc := findDocument(docMap, parentId, item.Title); doc != nil {
+					zap.L().Info("Document already added", zap.String("title", item.Title), zap.Int("index", doc.Index))
+					apply(item.Children, doc.Index)
+					continue
+				}
+				wiki, err := generate(ctx, item.CatalogueItem())
+				if err != nil {
+					failures = append(failures, fmt.Errorf("add document %s failed: %w", item.Title, err))
+					continue
+				}
+				maxIndex++
+				doc := &models.Document{
+					Title:    wiki.Title,
+					Content:  wiki.Content,
+					RepoId:   repoId,
+					Index:    maxIndex,
+					ParentId: uint(parentId),
+				}
+				if err = docDao.CreateDocument(doc); err != nil {
+					zap.L().Error("Failed to create document", zap.Error(err))
+					failures = append(failures, err)
+					continue
+				}
+				docMap[doc.Index] = doc
+				current = doc.Index
+			}
+
+			apply(item.Children, current)
+		}
+	}
+	apply(update.Items, rootId)
+
+	return errors.Join(failures...)
+}
+
+// findDocument returns the document with the title under the parent, nil if there is none.
+func findDocument(docMap map[int]*models.Document, parentId int, title string) *models.Document {
+	for _, doc := range docMap {
+		if int(doc.ParentId) == parentId && doc.Title == title {
+			return doc
+		}
+	}
+	return nil
+}
+
+// descendantIndexes returns the index and the indexes of all its descendants.
+func descendantIndexes(docs []*models.Document, index int) []int {
+	var result = []int{index}
+	for _, doc := range docs {
+		if int(doc.ParentId) == index && doc.Index != index {
+			result = append(result, descendantIndexes(docs, doc.Index)...)
+		}
+	}
+	return result
+}
+
+// parseDocumentIndex accepts the document id as a JSON number or string.
+func parseDocumentIndex(id any) (int, bool) {
+	switch v := id.(type) {
+	case float64:
+		return int(v), true
+	case string:
+		index, err := strconv.Atoi(v)
+		return index, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/o0olele/opendeepwiki-go/internal/analyzer"
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+)
+
+func TestApplyDocumentUpdateTwiceAfterFailure(t *testing.T) {
+	const repoId = 9001
+	docDao := dao.NewDocumentDao()
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Where("repo_id = ?", repoId).Delete(&models.Document{})
+	})
+	err := docDao.CreateDocuments([]*models.Document{
+		{RepoId: repoId, Index: 1},
+		{RepoId: repoId, Index: 2, ParentId: 1, Title: "Overview", Content: "old"},
+		{RepoId: repoId, Index: 3, ParentId: 1, Title: "Removed", Content: "old"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := &analyzer.DocumentUpdateCatalogue{
+		DeleteID: []any{float64(3)},
+		Items: []analyzer.DocumentUpdateItem{
+			{Type: analyzer.DocumentUpdateUpdate, ID: "2", Title: "Overview"},
+			{Type: analyzer.DocumentUpdateAdd, Title: "Command Line", Children: []analyzer.DocumentUpdateItem{
+				{Type: analyzer.DocumentUpdateAdd, Title: "Options"},
+			}},
+			{Type: analyzer.DocumentUpdateAdd, Title: "Library"},
+		},
+	}
+
+	// the library fails on the first sync only
+	var generated []string
+	failLibrary := true
+	generate := func(ctx context.Context, item *analyzer.DocumentResultCalalogueItem) (*analyzer.WikiDocument, error) {
+		if item.Title == "Library" && failLibrary {
+			return nil, errors.New("model unavailable")
+		}
+		generated = append(generated, item.Title)
+		return &analyzer.WikiDocument{Title: item.Title, Content: "new " + item.Title}, nil
+	}
+
+	for run := 0; run < 2; run++ {
+		docs, err := docDao.GetDocumentByRepoId(repoId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = applyDocumentUpdate(context.Background(), generate, docDao, repoId, docs, update)
+		if run == 0 && err == nil {
+			t.Fatal("first sync did not report the failed document")
+		}
+		if run == 1 && err != nil {
+			t.Fatalf("second sync failed: %v", err)
+		}
+		failLibrary = false
+	}
+
+	docs, err := docDao.GetDocumentByRepoId(repoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := make(map[string]int)
+	parents := make(map[string]uint)
+	indexes := make(map[uint]string)
+	for _, doc := range docs {
+		titles[doc.Title]++
+		parents[doc.Title] = doc.ParentId
+		indexes[uint(doc.Index)] = doc.Title
+	}
+	for _, title := range []string{"Overview", "Command Line", "Options", "Library"} {
+		if titles[title] != 1 {
+			t.Fatalf("got %d documents %q, want one: %v", titles[title], title, titles)
+		}
+	}
+	if titles["Removed"] != 0 || len(docs) != 5 {
+		t.Fatalf("got documents %v", titles)
+	}
+	if indexes[parents["Options"]] != "Command Line" {
+		t.Fatalf("options is a child of %q", indexes[parents["Options"]])
+	}
+	// only the failed document and the updated one are generated again
+	if len(generated) != 5 {
+		t.Fatalf("generated %v", generated)
+	}
+}
@@ -1,34 +1,47 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/o0olele/opendeepwiki-go/internal/analyzer"
 	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
 	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/progress"
 	"github.com/o0olele/opendeepwiki-go/internal/utils"
 	"go.uber.org/zap"
 )
 
 // TaskProcessParams represents the parameters for processing a task.
 type TaskProcessParams struct {
-	taskDao *dao.RepositoryTaskDAO
-	repoDao *dao.RepositoryDAO
-	repoDir string
+	taskDao     *dao.RepositoryTaskDAO
+	repoDao     *dao.RepositoryDAO
+	researchDao *dao.ResearchDAO
+	repoDir     string
 }
 
 // Task represents a task to be processed.
 type Task struct {
 	ID        uint      `json:"id"` // task id
 	GitURL    string    `json:"git_url"`
+	Ref       string    `json:"ref"`    // branch, tag or commit, empty for the default branch
+	Source    string    `json:"source"` // git or local
 	Language  string    `json:"language"`
+	Owner     string    `json:"owner"` // submitter, used for fair scheduling
 	CreatedAt time.Time `json:"created_at"`
 	Status    int       `json:"status"`
+
+	CredentialID uint `json:"credential_id"` // credential of a private repository
+
+	reporter *progress.Reporter
 }
 
 // NewTaskFromModel creates a new Task from a models.RepositoryTask.
@@ -36,57 +49,109 @@ func NewTaskFromModel(task *models.RepositoryTask) *Task {
 	return &Task{
 		ID:        task.ID,
 		GitURL:    task.GitURL,
+		Ref:       task.Ref,
+		Source:    task.Source,
 		Language:  task.Language,
+		Owner:     task.Owner,
 		CreatedAt: task.CreatedAt,
 		Status:    int(task.Status),
+
+		CredentialID: task.CredentialID,
 	}
 }
 
-// Process processes the task.
-func (t *Task) Process(params *TaskProcessParams) {
+// Process processes the task until it is completed, failed or ctx is cancelled.
+func (t *Task) Process(ctx context.Context, params *TaskProcessParams) {
+	t.reporter = progress.NewReporter(t.ID)
 
 	for {
-		var breakFlag = false
+		if ctx.Err() != nil {
+			return
+		}
+
+		var err error
 		switch t.Status {
 		case models.RepositoryStatusPending:
 			// first clone the repository to the local machine.
-			err := t.Clone(params)
-			if err != nil {
-				break
+			err = t.Clone(ctx, params)
+		case models.RepositoryStatusCloned, models.RepositoryStatusAnalyzed:
+			// then process the repository, the finished stages are skipped.
+			err = t.Analyze(ctx, params)
+		case models.RepositoryStatusUpdating:
+			// pull the new commits and update the affected documents.
+			err = t.Sync(ctx, params)
+		default:
+			return
+		}
+
+		if errors.Is(err, ErrTaskCancelled) {
+			// the cancelled status is kept, the caller stops the task
+			return
+		}
+		if err != nil {
+			if errors.Is(err, ErrBudgetExceeded) {
+				// the error names the budget, it is kept as the reason of the failure
+				zap.L().Warn("Task stopped, llm budget exceeded", zap.Uint("task_id", t.ID), zap.Error(err))
 			}
-		case models.RepositoryStatusCloned:
-			// then process the repository.
-			err := t.Analyze(params)
-			if err != nil {
-				break
+			// a failed stage must not be retried forever, cancellation is handled by the caller
+			if ctx.Err() == nil && t.Status != models.RepositoryStatusFailed {
+				params.taskDao.UpdateRepositoryTaskErrors(t.ID, err.Error())
+				t.UpdateStatus(params, models.RepositoryStatusFailed)
 			}
-		default:
-			breakFlag = true
+			return
 		}
-		if breakFlag ||
-			t.Status == models.RepositoryStatusCompleted ||
+		if t.Status == models.RepositoryStatusCompleted && t.takeRefresh(params) {
+			continue
+		}
+		if t.Status == models.RepositoryStatusCompleted ||
 			t.Status == models.RepositoryStatusFailed {
-			break
+			return
 		}
 	}
+}
+
+// takeRefresh syncs a completed task again when a push arrived while it was running.
+func (t *Task) takeRefresh(params *TaskProcessParams) bool {
+	pending, err := params.taskDao.TakeRepositoryTaskRefreshPending(t.ID)
+	if err != nil || !pending || t.Source == models.RepositorySourceLocal {
+		return false
+	}
+	zap.L().Info("Sync the pushes received while the task was running", zap.Uint("task_id", t.ID))
+	if err = params.taskDao.UpdateRepositoryTaskSyncing(t.ID, true); err != nil {
+		return false
+	}
+	return t.UpdateStatus(params, models.RepositoryStatusUpdating) == nil
+}
 
+// stage reports a generation stage with the model it is routed to.
+func (t *Task) stage(r *analyzer.Repository, stage string) {
+	model := r.StageModel(stage)
+	zap.L().Info("Task stage started", zap.Uint("task_id", t.ID), zap.String("stage", stage), zap.String("model", model))
+	t.reporter.Stage(stage, model)
 }
 
-// UpdateStatus updates the task status.
+// UpdateStatus updates the task status, it returns ErrTaskCancelled once the task was cancelled.
 func (t *Task) UpdateStatus(params *TaskProcessParams, status int) error {
 	dao := params.taskDao
 
-	err := dao.UpdateRepositoryTaskStatus(t.ID, status)
+	updated, err := dao.UpdateProcessedTaskStatus(t.ID, status)
 	if err != nil {
 		return err
 	}
+	if !updated {
+		return ErrTaskCancelled
+	}
 
 	t.Status = status
+	t.reporter.Status(models.GetStatusString(status))
 	return nil
 }
 
 // Clone clones the repository to the local machine.
-func (t *Task) Clone(params *TaskProcessParams) error {
+func (t *Task) Clone(ctx context.Context, params *TaskProcessParams) error {
+	if t.Source == models.RepositorySourceLocal {
+		return t.openLocal(params)
+	}
 
 	// first check if the repository already exists.
 	repoName, err := utils.ExtractRepoName(t.GitURL)
@@ -95,7 +160,9 @@ func (t *Task) Clone(params *TaskProcessParams) error {
 		return err
 	}
 
-	var repoPath = filepath.Join(params.repoDir, repoName)
+	// every ref is checked out in its own directory
+	var repoPath = filepath.Join(params.repoDir, repoDirName(repoName, t.Ref))
+	t.reporter.Stage(progress.StageClone, t.GitURL)
 
 	if _, err = os.Stat(repoPath); err == nil {
 		_, err = git.PlainOpen(repoPath)
@@ -109,33 +176,123 @@ func (t *Task) Clone(params *TaskProcessParams) error {
 		}
 	}
 
+	auth, err := gitAuth(t.CredentialID)
+	if err != nil {
+		zap.L().Error("Failed to load repository credential", zap.Uint("task_id", t.ID), zap.Error(err))
+		return err
+	}
+
 	// clone the repository.
-	_, err = git.PlainClone(repoPath, false, &git.CloneOptions{
+	gitRepo, err := git.PlainCloneContext(ctx, repoPath, false, &git.CloneOptions{
 		URL:      t.GitURL,
+		Auth:     auth,
 		Progress: os.Stdout,
 	})
 	if err != nil {
 		zap.L().Error("Failed to clone repository: %v", zap.Error(err))
-		t.UpdateStatus(params, models.RepositoryStatusFailed)
 		return err
 	}
 
-	zap.L().Info("Cloned repository", zap.String("git_url", t.GitURL))
+	commit, err := checkoutRef(gitRepo, t.Ref)
+	if err != nil {
+		zap.L().Error("Failed to checkout ref", zap.String("ref", t.Ref), zap.Error(err))
+		// the next attempt must clone again
+		os.RemoveAll(repoPath)
+		return err
+	}
+
+	zap.L().Info("Cloned repository", zap.String("git_url", t.GitURL), zap.String("ref", t.Ref), zap.String("commit", commit))
 	err = t.UpdateStatus(params, models.RepositoryStatusCloned)
 	if err != nil {
 		return err
 	}
 
-	params.repoDao.CreateRepository(t.GitURL, repoName, repoPath, models.RepositoryStatusCloned, t.Language)
+	repoModal, err := params.repoDao.CreateRepository(t.GitURL, t.Ref, repoName, repoPath, models.RepositoryStatusCloned, t.Language)
+	if err == nil {
+		params.repoDao.UpdateRepositoryCommit(repoModal.ID, commit)
+	}
 	return nil
 }
 
+// openLocal registers an imported directory, it is documented in place.
+func (t *Task) openLocal(params *TaskProcessParams) error {
+	info, err := os.Stat(t.GitURL)
+	if err != nil {
+		return fmt.Errorf("open local directory failed: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", t.GitURL)
+	}
+
+	t.reporter.Stage(progress.StageClone, t.GitURL)
+	if _, err = params.repoDao.GetRepositoryByGitURLAndBranch(t.GitURL, ""); err != nil {
+		_, err = params.repoDao.CreateRepository(t.GitURL, "", filepath.Base(t.GitURL), t.GitURL, models.RepositoryStatusCloned, t.Language)
+		if err != nil {
+			return err
+		}
+	}
+	return t.UpdateStatus(params, models.RepositoryStatusCloned)
+}
+
+// repoDirName returns the local directory name of a repository ref.
+func repoDirName(repoName, ref string) string {
+	if len(ref) == 0 {
+		return repoName
+	}
+	return repoName + "@" + strings.NewReplacer("/", "_", "\\", "_").Replace(ref)
+}
+
+// checkoutRef checks out a branch, tag or commit and returns the resolved commit.
+func checkoutRef(repo *git.Repository, ref string) (string, error) {
+	if len(ref) == 0 {
+		head, err := repo.Head()
+		if err != nil {
+			return "", err
+		}
+		return head.Hash().String(), nil
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	// a branch is checked out as a local branch so it can be pulled later
+	if remoteRef, err := repo.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, ref), true); err == nil {
+		options := &git.CheckoutOptions{
+			Branch: plumbing.NewBranchReferenceName(ref),
+		}
+		if _, err = repo.Reference(options.Branch, false); err != nil {
+			// only the default branch exists locally after a clone
+			options.Hash = remoteRef.Hash()
+			options.Create = true
+		}
+		if err = worktree.Checkout(options); err != nil {
+			return "", err
+		}
+		return remoteRef.Hash().String(), nil
+	}
+
+	// tags and commits are pinned, the head is detached
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve ref %s: %w", ref, err)
+	}
+	err = worktree.Checkout(&git.CheckoutOptions{
+		Hash: *hash,
+	})
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
 // Analyze analyzes the repository.
-func (t *Task) Analyze(params *TaskProcessParams) error {
+func (t *Task) Analyze(ctx context.Context, params *TaskProcessParams) error {
 
 	var r *analyzer.Repository
 	// first check if the repository already exists.
-	repoModal, err := params.repoDao.GetRepositoryByGitURL(t.GitURL)
+	repoModal, err := params.repoDao.GetRepositoryByGitURLAndBranch(t.GitURL, t.Ref)
 	if err == nil {
 		// repo already exists, update status to analyzed.
 		zap.L().Info("Repository already exists", zap.Uint("repository_id", repoModal.ID))
@@ -147,9 +304,11 @@ func (t *Task) Analyze(params *TaskProcessParams) error {
 
 	if err != nil {
 		zap.L().Error("Failed to create repository: %v", zap.Error(err))
-		t.UpdateStatus(params, models.RepositoryStatusFailed)
 		return err
 	}
+	r.SetReporter(t.reporter)
+	useLLMCache(r, repoModal.ID)
+	useLLMMeter(r, t.ID, repoModal.ID)
 
 	// get the repository description
 	if len(r.Description) == 0 {
@@ -163,17 +322,22 @@ func (t *Task) Analyze(params *TaskProcessParams) error {
 
 	// get the repository readme content
 	if len(r.Readme) == 0 {
+		t.stage(r, progress.StageReadme)
 		r.Readme, err = r.ParseReadme()
 		if err != nil || len(r.Readme) == 0 {
 			// generate README content if not found or failed to parse
-			r.Readme, err = r.GenerateReadme()
+			r.Readme, err = r.GenerateReadme(ctx)
+			if errors.Is(err, ErrBudgetExceeded) {
+				return err
+			}
 		}
 		params.repoDao.UpdateRepositoryReadme(repoModal.ID, r.Readme)
 	}
 
 	// get the repository catalog string
 	if len(r.StructedCatalogue) == 0 {
-		r.StructedCatalogue, err = r.GenerateStructedCatalogue()
+		t.stage(r, progress.StageCatalogue)
+		r.StructedCatalogue, err = r.GenerateStructedCatalogue(ctx)
 		if err != nil {
 			zap.L().Error("get repository catalog failed", zap.Error(err))
 			return err
@@ -183,7 +347,8 @@ func (t *Task) Analyze(params *TaskProcessParams) error {
 
 	// generate the repository overview
 	if len(r.Overview) == 0 {
-		r.Overview, err = r.GenerateOverview()
+		t.stage(r, progress.StageOverview)
+		r.Overview, err = r.GenerateOverview(ctx)
 		if err != nil {
 			zap.L().Error("generate repository overview failed", zap.Error(err))
 			return err
@@ -191,10 +356,10 @@ func (t *Task) Analyze(params *TaskProcessParams) error {
 		params.repoDao.UpdateRepositoryOverview(repoModal.ID, r.Overview)
 	}
 
+	t.reporter.Stage(progress.StageIndex, "")
 	savePath, err := r.IndexCode()
 	if err != nil {
 		zap.L().Error("Failed to index repository: %v", zap.Error(err))
-		t.UpdateStatus(params, models.RepositoryStatusFailed)
 		return err
 	}
 
@@ -202,31 +367,68 @@ func (t *Task) Analyze(params *TaskProcessParams) error {
 		params.repoDao.UpdateRepositoryCodePath(repoModal.ID, r.StructedCodePath)
 		params.repoDao.UpdateRepositoryVectorPath(repoModal.ID, r.StructedVectorPath)
 	}
+	if t.Status != models.RepositoryStatusAnalyzed {
+		if err = t.UpdateStatus(params, models.RepositoryStatusAnalyzed); err != nil {
+			return err
+		}
+	}
+
+	// the catalogue is saved so a resumed task generates the same documents
+	if len(r.DocumentCatalogue) == 0 {
+		t.stage(r, progress.StageThink)
+		r.DocumentCatalogue, err = r.GenerateDocumentCatalogue(ctx)
+		if err != nil {
+			zap.L().Error("generate document catalogue failed", zap.Error(err))
+			return err
+		}
+		params.repoDao.UpdateRepositoryDocumentCatalogue(repoModal.ID, r.DocumentCatalogue)
+	}
 
-	doc, err := r.CreateDocuments()
+	// every generated document is saved at once, a restarted task continues from there
+	checkpoint := newDocumentCheckpoint(repoModal.ID)
+	r.SetCheckpoint(checkpoint)
+
+	doc, err := r.CreateDocuments(ctx)
 	if err != nil {
 		zap.L().Error("Failed to create documents: %v", zap.Error(err))
-		t.UpdateStatus(params, models.RepositoryStatusFailed)
 
 		s, _ := json.Marshal(doc)
 		os.WriteFile(r.Name+".json", s, 0644)
 
-		t.dumpDocuments(repoModal.ID, doc)
 		return err
 	}
 
-	t.dumpDocuments(repoModal.ID, doc)
-	t.UpdateStatus(params, models.RepositoryStatusCompleted)
-	return nil
+	// the checkpoints are only dropped once the documents are saved, a failed save resumes from them
+	if err = t.dumpDocuments(repoModal.ID, doc); err != nil {
+		return fmt.Errorf("save documents failed: %w", err)
+	}
+	if err = checkpoint.Clear(); err != nil {
+		zap.L().Warn("clear document checkpoints failed", zap.Uint("repository_id", repoModal.ID), zap.Error(err))
+	}
+
+	// the changelog is optional, a failure does not fail the task unless the budget is spent,
+	// the checkpoints are kept so a retry only summarises the remaining commits
+	if err = t.generateHistory(ctx, r, repoModal.ID); err != nil {
+		zap.L().Warn("generate repository history failed", zap.Error(err))
+		if errors.Is(err, ErrBudgetExceeded) {
+			return err
+		}
+	}
+
+	// record the documented commit for incremental updates
+	if head, err := r.HeadCommit(); err == nil {
+		params.repoDao.UpdateRepositoryCommit(repoModal.ID, head)
+	}
+
+	return t.UpdateStatus(params, models.RepositoryStatusCompleted)
 }
 
 func (t *Task) dumpDocuments(repoId uint, doc *analyzer.WikiDocument) error {
 	docModels := []*models.Document{}
 	docModels = wikiDocumentToModel(doc, docModels, repoId, 0)
 
-	docDao := dao.NewDocumentDao()
-	docDao.CreateDocuments(docModels)
-	return nil
+	// replace the documents of a previous run
+	return dao.NewDocumentDao().ReplaceDocuments(repoId, docModels)
 }
 
 func wikiDocumentToModel(doc *analyzer.WikiDocument, list []*models.Document, repoId uint, depth int) []*models.Document {
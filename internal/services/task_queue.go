@@ -1,11 +1,12 @@
 package services
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"os"
 	"time"
 
+	"github.com/o0olele/opendeepwiki-go/internal/config"
 	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
 	"github.com/o0olele/opendeepwiki-go/internal/database/models"
 	"go.uber.org/zap"
@@ -13,14 +14,21 @@ import (
 
 // TaskQueue manages a queue of documentation generation tasks
 type TaskQueue struct {
-	repoDir  string
-	taskChan chan *Task
-	taskDao  *dao.RepositoryTaskDAO
-	repoDao  *dao.RepositoryDAO
+	repoDir      string
+	workers      int
+	scheduler    *fairScheduler
+	taskChan     chan *Task
+	doneChan     chan *Task
+	researchChan chan *ResearchTask
+	// researchWake makes the research consumer load new research tasks without waiting for the next poll
+	researchWake chan struct{}
+	taskDao      *dao.RepositoryTaskDAO
+	repoDao      *dao.RepositoryDAO
+	researchDao  *dao.ResearchDAO
 }
 
 // NewTaskQueue creates a new task queue
-func NewTaskQueue(repoDir string) *TaskQueue {
+func NewTaskQueue(repoDir string, taskConfig *config.TaskConfig) *TaskQueue {
 	// Create repos directory if it doesn't exist
 	if _, err := os.Stat(repoDir); os.IsNotExist(err) {
 		err := os.MkdirAll(repoDir, 0755)
@@ -30,11 +38,22 @@ func NewTaskQueue(repoDir string) *TaskQueue {
 		}
 	}
 
+	workers := taskConfig.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
 	taskQueue := &TaskQueue{
-		repoDir:  repoDir,
-		taskChan: make(chan *Task, 1024), // Buffer size of 1024 tasks
-		taskDao:  dao.NewRepositoryTaskDAO(),
-		repoDao:  dao.NewRepositoryDAO(),
+		repoDir:      repoDir,
+		workers:      workers,
+		scheduler:    newFairScheduler(taskConfig.MaxPerOwner),
+		taskChan:     make(chan *Task, 1024), // Buffer size of 1024 tasks
+		doneChan:     make(chan *Task, workers),
+		researchChan: make(chan *ResearchTask, 1024),
+		researchWake: make(chan struct{}, 1),
+		taskDao:      dao.NewRepositoryTaskDAO(),
+		repoDao:      dao.NewRepositoryDAO(),
+		researchDao:  dao.NewResearchDAO(),
 	}
 
 	return taskQueue
@@ -48,11 +67,20 @@ func (tq *TaskQueue) recoverPendingTasks() {
 
 	zap.L().Info("Recovering pending tasks from database...")
 
-	// find all tasks with status not in ["completed", "failed"]
-	tasks, err := tq.taskDao.ListRepositoryTasksByStatus(models.RepositoryStatusPending, 10, 0)
-	if err != nil {
-		zap.L().Error("Failed to list repository tasks: %v", zap.Error(err))
-		return
+	// find all unfinished tasks, tasks interrupted by a restart resume from their last stage
+	var tasks []*models.RepositoryTask
+	for _, status := range []int{
+		models.RepositoryStatusPending,
+		models.RepositoryStatusCloned,
+		models.RepositoryStatusAnalyzed,
+		models.RepositoryStatusUpdating,
+	} {
+		list, err := tq.taskDao.ListRepositoryTasksByStatus(status, 100, 0)
+		if err != nil {
+			zap.L().Error("Failed to list repository tasks: %v", zap.Error(err))
+			return
+		}
+		tasks = append(tasks, list...)
 	}
 
 	if len(tasks) == 0 {
@@ -68,6 +96,36 @@ func (tq *TaskQueue) recoverPendingTasks() {
 	}
 }
 
+// recoverPendingResearch loads pending research tasks from the database, research tasks
+// interrupted by a restart are still running in the database and start over.
+func (tq *TaskQueue) recoverPendingResearch() {
+	if len(tq.researchChan) > 0 {
+		return
+	}
+
+	// research tasks run one at a time on the consumer that calls this, none is running now
+	var tasks []*models.ResearchTask
+	for _, status := range []int{
+		models.ResearchStatusPending,
+		models.ResearchStatusRunning,
+	} {
+		list, err := tq.researchDao.ListResearchTasksByStatus(status, 10, 0)
+		if err != nil {
+			zap.L().Error("Failed to list research tasks: %v", zap.Error(err))
+			return
+		}
+		tasks = append(tasks, list...)
+	}
+
+	for _, modelTask := range tasks {
+		err := tq.AddResearchTask(NewResearchTaskFromModel(modelTask))
+		if err != nil {
+			zap.L().Error("Failed to add research task to queue: %v", zap.Error(err))
+			break
+		}
+	}
+}
+
 // AddTask adds a new task to the queue
 func (tq *TaskQueue) AddTask(task *Task) error {
 	select {
@@ -79,31 +137,174 @@ func (tq *TaskQueue) AddTask(task *Task) error {
 	}
 }
 
+// AddResearchTask adds a new research task to the queue
+func (tq *TaskQueue) AddResearchTask(task *ResearchTask) error {
+	select {
+	case tq.researchChan <- task:
+		zap.L().Info("Added research task to queue", zap.Uint("research_id", task.ID))
+		return nil
+	default:
+		return fmt.Errorf("research queue is full")
+	}
+}
+
+// ResearchQueued wakes the research consumer for a research task created in the database.
+func (tq *TaskQueue) ResearchQueued() {
+	select {
+	case tq.researchWake <- struct{}{}:
+	default:
+	}
+}
+
+func (tq *TaskQueue) processParams() *TaskProcessParams {
+	return &TaskProcessParams{
+		taskDao:     tq.taskDao,
+		repoDao:     tq.repoDao,
+		researchDao: tq.researchDao,
+		repoDir:     tq.repoDir,
+	}
+}
+
+// runTask processes a task, a panic only fails this task.
+func (tq *TaskQueue) runTask(task *Task) {
+	params := tq.processParams()
+
+	// register before reading the status, a cancel either is seen in the status or cancels ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	registerCancel(task.ID, cancel)
+	defer func() {
+		unregisterCancel(task.ID)
+		cancel()
+	}()
+
+	// the task may have been cancelled or finished while it was waiting in the queue
+	modelTask, err := tq.taskDao.GetRepositoryTaskByID(task.ID)
+	if err != nil ||
+		modelTask.Status == models.RepositoryStatusCompleted ||
+		modelTask.Status == models.RepositoryStatusFailed ||
+		modelTask.Status == models.RepositoryStatusCancelled {
+		return
+	}
+	task.Status = modelTask.Status
+
+	defer func() {
+		if err := recover(); err != nil {
+			zap.L().Error("Task panicked", zap.Uint("task_id", task.ID), zap.Any("error", err), zap.Stack("stack"))
+			params.taskDao.UpdateRepositoryTaskErrors(task.ID, fmt.Sprintf("panic: %v", err))
+			task.UpdateStatus(params, models.RepositoryStatusFailed)
+		}
+	}()
+
+	task.Process(ctx, params)
+	if ctx.Err() != nil {
+		zap.L().Info("Task cancelled", zap.Uint("task_id", task.ID))
+		task.UpdateStatus(params, models.RepositoryStatusCancelled)
+	}
+}
+
+// runResearchTask processes a research task, a panic only fails this task.
+func (tq *TaskQueue) runResearchTask(task *ResearchTask) {
+	params := tq.processParams()
+
+	// register before reading the status, a cancel either is seen in the status or cancels ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	registerResearchCancel(task.ID, cancel)
+	defer func() {
+		unregisterResearchCancel(task.ID)
+		cancel()
+	}()
+
+	// the research may have been cancelled or finished while it was waiting in the queue
+	modelTask, err := tq.researchDao.GetResearchTaskByID(task.ID)
+	if err != nil ||
+		modelTask.Status == models.ResearchStatusCompleted ||
+		modelTask.Status == models.ResearchStatusFailed ||
+		modelTask.Status == models.ResearchStatusCancelled {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			zap.L().Error("Research task panicked", zap.Uint("research_id", task.ID), zap.Any("error", err), zap.Stack("stack"))
+			params.researchDao.UpdateResearchTaskErrors(task.ID, fmt.Sprintf("panic: %v", err))
+			task.UpdateStatus(params, models.ResearchStatusFailed)
+		}
+	}()
+
+	task.Process(ctx, params)
+	if ctx.Err() != nil {
+		zap.L().Info("Research task cancelled", zap.Uint("research_id", task.ID))
+		task.UpdateStatus(params, models.ResearchStatusCancelled)
+	}
+}
+
 // ProcessTasks starts processing tasks from the queue
 func (tq *TaskQueue) ProcessTasks() {
-	go func() {
-		defer func() {
-			if err := recover(); err != nil {
-				log.Printf("Process Tasks err: %v", err)
+	workChan := make(chan *Task, tq.workers)
+	for i := 0; i < tq.workers; i++ {
+		go func() {
+			for task := range workChan {
+				tq.runTask(task)
+				tq.doneChan <- task
 			}
 		}()
+	}
+
+	go tq.scheduleRefresh()
+	go tq.cleanLLMCache()
 
+	// the dispatcher owns the scheduler, workers only report finished tasks
+	go func() {
 		var ticker = time.NewTicker(10 * time.Second)
 
 		for {
 			select {
 			case task := <-tq.taskChan:
-				task.Process(&TaskProcessParams{
-					taskDao: tq.taskDao,
-					repoDao: tq.repoDao,
-					repoDir: tq.repoDir,
-				})
+				tq.scheduler.Push(task)
+			case task := <-tq.doneChan:
+				tq.scheduler.Done(task)
 			case <-ticker.C:
 				if len(tq.taskChan) > 0 {
 					continue
 				}
 				go tq.recoverPendingTasks()
+			case <-wakeChan:
+				go tq.recoverPendingTasks()
+			}
+
+			for tq.scheduler.Running() < tq.workers {
+				task := tq.scheduler.Pop()
+				if task == nil {
+					break
+				}
+				workChan <- task
 			}
 		}
 	}()
+
+	// research tasks are short compared to wiki generation, consume them separately
+	go tq.processResearch()
+}
+
+// processResearch runs the research tasks one at a time.
+func (tq *TaskQueue) processResearch() {
+	var ticker = time.NewTicker(10 * time.Second)
+
+	for {
+		select {
+		case task := <-tq.researchChan:
+			tq.runResearchTask(task)
+			// more tasks may be pending than one recovery loads
+			if len(tq.researchChan) == 0 {
+				tq.recoverPendingResearch()
+			}
+		case <-ticker.C:
+			if len(tq.researchChan) > 0 {
+				continue
+			}
+			tq.recoverPendingResearch()
+		case <-tq.researchWake:
+			tq.recoverPendingResearch()
+		}
+	}
 }
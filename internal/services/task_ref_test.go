@@ -0,0 +1,135 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// newRefRemote creates a repository with a commit on the default branch, one on the release/1.0
+// branch and the tag v1.0 on the first commit, it returns the path and the commits by name.
+func newRefRemote(t *testing.T) (string, map[string]string) {
+	path := t.TempDir()
+	repo, err := git.PlainInit(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(message string) plumbing.Hash {
+		if err := os.WriteFile(filepath.Join(path, "README.md"), []byte(message), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add("README.md"); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := worktree.Commit(message, &git.CommitOptions{
+			Author: &object.Signature{Name: "tester", Email: "tester@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	commits := make(map[string]string)
+	first := commit("first")
+	commits["first"] = first.String()
+	if _, err = repo.CreateTag("v1.0", first, nil); err != nil {
+		t.Fatal(err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("release/1.0"), Create: true}); err != nil {
+		t.Fatal(err)
+	}
+	commits["release"] = commit("release fix").String()
+
+	if err = worktree.Checkout(&git.CheckoutOptions{Branch: head.Name()}); err != nil {
+		t.Fatal(err)
+	}
+	commits["main"] = commit("second").String()
+	return path, commits
+}
+
+func TestCheckoutRef(t *testing.T) {
+	remote, commits := newRefRemote(t)
+
+	tests := []struct {
+		name       string
+		ref        string
+		want       string
+		wantBranch string // checked out branch, empty for a detached head
+	}{
+		{"default branch", "", commits["main"], "master"},
+		{"branch", "release/1.0", commits["release"], "release/1.0"},
+		{"tag", "v1.0", commits["first"], ""},
+		{"commit", commits["first"], commits["first"], ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo, err := git.PlainClone(t.TempDir(), false, &git.CloneOptions{URL: remote})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			commit, err := checkoutRef(repo, test.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if commit != test.want {
+				t.Fatalf("got commit %s, want %s", commit, test.want)
+			}
+			head, err := repo.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if head.Hash().String() != test.want {
+				t.Fatalf("head is %s, want %s", head.Hash(), test.want)
+			}
+			// a branch is pulled by later syncs, a tag or commit stays detached
+			var branch string
+			if head.Name().IsBranch() {
+				branch = head.Name().Short()
+			}
+			if branch != test.wantBranch {
+				t.Fatalf("got branch %q, want %q", branch, test.wantBranch)
+			}
+		})
+	}
+
+	repo, err := git.PlainClone(t.TempDir(), false, &git.CloneOptions{URL: remote})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = checkoutRef(repo, "missing"); err == nil {
+		t.Fatal("got no error for an unknown ref")
+	}
+}
+
+func TestRepoDirName(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"", "sample"},
+		{"main", "sample@main"},
+		{"release/1.0", "sample@release_1.0"},
+		{`v1\2`, "sample@v1_2"},
+	}
+	for _, test := range tests {
+		if got := repoDirName("sample", test.ref); got != test.want {
+			t.Errorf("repoDirName(%q) = %q, want %q", test.ref, got, test.want)
+		}
+	}
+}
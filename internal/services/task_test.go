@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/o0olele/opendeepwiki-go/internal/analyzer"
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/llm/chat"
+	"gorm.io/gorm"
+)
+
+// recordFixtures replaces the hand-written fixtures of the sample repository with answers recorded
+// from the OpenAI compatible server at OPENDEEPWIKI_TEST_LLM_BASE_URL serving OPENDEEPWIKI_TEST_LLM_MODEL.
+var recordFixtures = flag.Bool("record", false, "record the llm fixtures of the sample repository")
+
+const (
+	sampleFixtures = "testdata/llm"
+	sampleDir      = "testdata/sample"
+)
+
+// useSampleLLM configures the llm for the sample repository, the fixtures are replayed from dir.
+func useSampleLLM(t *testing.T, dir string) {
+	llm := config.LLMConfig{
+		ProviderType:  string(chat.ProviderVLLM),
+		Model:         "sample-model",
+		MaxTokens:     4096,
+		ContextWindow: 32768,
+		Concurrency:   2,
+		Replay:        config.LLMReplayConfig{Mode: chat.ReplayModeReplay, Dir: dir},
+	}
+	if *recordFixtures {
+		llm.BaseURL = os.Getenv("OPENDEEPWIKI_TEST_LLM_BASE_URL")
+		llm.APIKey = os.Getenv("OPENDEEPWIKI_TEST_LLM_API_KEY")
+		if model := os.Getenv("OPENDEEPWIKI_TEST_LLM_MODEL"); len(model) > 0 {
+			llm.Model = model
+		}
+		llm.Replay.Mode = chat.ReplayModeRecord
+	}
+
+	previous := *config.GetLLMConfig()
+	config.SetLLMConfig(llm)
+	// the fixtures include the summaries of the changelog
+	taskConfig := config.GetTaskConfig()
+	changelog := taskConfig.Changelog
+	taskConfig.Changelog = true
+	t.Cleanup(func() {
+		config.SetLLMConfig(previous)
+		taskConfig.Changelog = changelog
+	})
+}
+
+// newSampleTask commits the sample repository in two months and registers it as cloned from gitURL.
+func newSampleTask(t *testing.T, gitURL string) (*Task, *TaskProcessParams, *models.Repository) {
+	path := t.TempDir()
+	repo, err := git.PlainInit(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commits := []struct {
+		message string
+		files   []string
+		when    time.Time
+	}{
+		{"Add the greeting library", []string{"README.md", "Cargo.toml", "src/lib.rs"}, time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)},
+		{"Add the command line tool", []string{"src/main.rs"}, time.Date(2024, 2, 10, 10, 0, 0, 0, time.UTC)},
+	}
+	for _, commit := range commits {
+		for _, file := range commit.files {
+			data, err := os.ReadFile(filepath.Join(sampleDir, file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			target := filepath.Join(path, file)
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err = os.WriteFile(target, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = worktree.Add(file); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// fixed signatures keep the commit ids, and the prompts of the history, stable
+		signature := &object.Signature{Name: "Sample Author", Email: "author@example.com", When: commit.when}
+		if _, err = worktree.Commit(commit.message, &git.CommitOptions{Author: signature, Committer: signature}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	params := &TaskProcessParams{
+		taskDao:     dao.NewRepositoryTaskDAO(),
+		repoDao:     dao.NewRepositoryDAO(),
+		researchDao: dao.NewResearchDAO(),
+		repoDir:     config.GetRepositoryConfig().Dir,
+	}
+	modelTask, err := params.taskDao.CreateRepositoryTask(gitURL, "", models.LanguageEnglish, "tester", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = params.taskDao.UpdateRepositoryTaskStatus(modelTask.ID, models.RepositoryStatusCloned); err != nil {
+		t.Fatal(err)
+	}
+	modelTask.Status = models.RepositoryStatusCloned
+	repoModel, err := params.repoDao.CreateRepository(gitURL, "", "sample", path, models.RepositoryStatusCloned, models.LanguageEnglish)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the git url is part of the prompts, the rows are removed for the next run of the test
+	t.Cleanup(func() {
+		db := database.GetDB()
+		db.Unscoped().Where("repo_id = ?", repoModel.ID).Delete(&models.Document{})
+		db.Unscoped().Where("repo_id = ?", repoModel.ID).Delete(&models.DocumentCommitRecord{})
+		db.Unscoped().Delete(&models.Repository{}, repoModel.ID)
+		db.Unscoped().Delete(&models.RepositoryTask{}, modelTask.ID)
+	})
+	return NewTaskFromModel(modelTask), params, repoModel
+}
+
+func TestTaskProcessReplaysSampleRepository(t *testing.T) {
+	useSampleLLM(t, sampleFixtures)
+	task, params, repoModel := newSampleTask(t, "https://github.com/example/sample.git")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	task.Process(ctx, params)
+
+	modelTask, err := params.taskDao.GetRepositoryTaskByID(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modelTask.Status != models.RepositoryStatusCompleted {
+		t.Fatalf("task is %s, want completed: %s", modelTask.StatusString(), modelTask.Errors)
+	}
+
+	repo, err := params.repoDao.GetRepositoryByID(repoModel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.Overview) == 0 || len(repo.DocumentCatalogue) == 0 {
+		t.Fatal("overview or document catalogue not saved")
+	}
+	gitRepo, err := git.PlainOpen(repo.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head, _ := gitRepo.Head(); repo.CommitID != head.Hash().String() {
+		t.Fatalf("documented commit %s, want %s", repo.CommitID, head.Hash())
+	}
+
+	docs, err := dao.NewDocumentDao().GetDocumentByRepoId(repo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) < 2 {
+		t.Fatalf("got %d documents, want the catalogue items", len(docs))
+	}
+	// the first document is the root of the catalogue
+	for _, doc := range docs[1:] {
+		if len(doc.Title) == 0 || len(doc.Content) == 0 {
+			t.Fatalf("document %d has no title or content", doc.Index)
+		}
+	}
+
+	records, err := dao.NewDocumentCommitRecordDao().GetRecordsByRepoId(repo.ID, models.DocumentRecordHistory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Title != "2024-02" || records[1].Title != "2024-01" {
+		t.Fatalf("got changelog %+v, want 2024-02 and 2024-01", records)
+	}
+}
+
+func TestTaskProcessFailsOnMissingFixture(t *testing.T) {
+	if *recordFixtures {
+		t.Skip("fixtures are being recorded")
+	}
+	useSampleLLM(t, t.TempDir())
+	task, params, _ := newSampleTask(t, "https://github.com/example/missing.git")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	task.Process(ctx, params)
+
+	modelTask, err := params.taskDao.GetRepositoryTaskByID(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modelTask.Status != models.RepositoryStatusFailed {
+		t.Fatalf("task is %s, want failed", modelTask.StatusString())
+	}
+	if !strings.Contains(modelTask.Errors, chat.ErrFixtureNotFound.Error()) {
+		t.Fatalf("task error %q does not name the missing fixture", modelTask.Errors)
+	}
+}
+
+var errInsertDocuments = errors.New("insert documents failed")
+
+// failDocumentInserts fails the inserts of documents until the returned func is called.
+func failDocumentInserts(t *testing.T) func() {
+	callbacks := database.GetDB().Callback().Create()
+	err := callbacks.Before("gorm:create").Register("test:fail_documents", func(tx *gorm.DB) {
+		if _, ok := tx.Statement.Model.([]*models.Document); ok {
+			tx.AddError(errInsertDocuments)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remove := func() {
+		callbacks.Remove("test:fail_documents")
+	}
+	t.Cleanup(remove)
+	return remove
+}
+
+func TestTaskProcessKeepsCheckpointsWhenDocumentsAreNotSaved(t *testing.T) {
+	if *recordFixtures {
+		t.Skip("fixtures are being recorded")
+	}
+	useSampleLLM(t, sampleFixtures)
+	task, params, repoModel := newSampleTask(t, "https://github.com/example/sample.git")
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Where("repo_id = ?", repoModel.ID).Delete(&models.DocumentCheckpoint{})
+	})
+	failDocumentInserts(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	task.Process(ctx, params)
+
+	modelTask, err := params.taskDao.GetRepositoryTaskByID(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modelTask.Status != models.RepositoryStatusFailed || !strings.Contains(modelTask.Errors, errInsertDocuments.Error()) {
+		t.Fatalf("task is %s with %q, want failed on the document save", modelTask.StatusString(), modelTask.Errors)
+	}
+	checkpoints, err := dao.NewDocumentCheckpointDao().GetCheckpointsByRepoId(repoModel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoints) == 0 {
+		t.Fatal("checkpoints were cleared, want them kept for the retry")
+	}
+}
+
+func TestDumpDocumentsKeepsOldDocumentsOnFailure(t *testing.T) {
+	const repoId = 9001
+	docDao := dao.NewDocumentDao()
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Where("repo_id = ?", repoId).Delete(&models.Document{})
+	})
+	if err := docDao.CreateDocuments([]*models.Document{{RepoId: repoId, Index: 1, Title: "Old", Content: "old"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the insert of the new documents fails after the old ones were deleted
+	removeCallback := failDocumentInserts(t)
+	task := &Task{}
+	doc := &analyzer.WikiDocument{Title: "New", Content: "new", Children: []*analyzer.WikiDocument{{Title: "Child"}}}
+	err := task.dumpDocuments(repoId, doc)
+	removeCallback()
+	if !errors.Is(err, errInsertDocuments) {
+		t.Fatalf("got %v, want the insert error", err)
+	}
+
+	docs, err := docDao.GetDocumentByRepoId(repoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].Title != "Old" {
+		t.Fatalf("got documents %+v, want the old one kept", docs)
+	}
+
+	if err = task.dumpDocuments(repoId, doc); err != nil {
+		t.Fatal(err)
+	}
+	docs, err = docDao.GetDocumentByRepoId(repoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 || docs[0].Title != "New" || docs[1].Title != "Child" {
+		t.Fatalf("got documents %+v, want New and Child", docs)
+	}
+}
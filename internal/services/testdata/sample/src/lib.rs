@@ -0,0 +1,9 @@
+//! Greeting helpers shared by the command line tool.
+
+/// Returns the greeting for a name, an empty name greets the world.
+pub fn greet(name: &str) -> String {
+    if name.is_empty() {
+        return "Hello, world!".to_string();
+    }
+    format!("Hello, {}!", name)
+}
@@ -0,0 +1,10 @@
+use std::env;
+
+fn main() {
+    let args: Vec<String> = env::args().collect();
+    let name = match args.iter().position(|arg| arg == "--name") {
+        Some(idx) => args.get(idx + 1).cloned().unwrap_or_default(),
+        None => String::new(),
+    };
+    println!("{}", greeter::greet(&name));
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/o0olele/opendeepwiki-go/internal/analyzer"
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/llm/chat"
+	"go.uber.org/zap"
+)
+
+// ErrBudgetExceeded is returned for the requests of a task run that spent its llm budget.
+var ErrBudgetExceeded = errors.New("llm budget exceeded")
+
+// taskMeter records the usage of a task run and enforces its budget.
+type taskMeter struct {
+	taskId  uint
+	repoId  uint
+	pricing map[string]config.LLMPrice
+	budget  config.LLMBudgetConfig
+	dao     *dao.LLMUsageDao
+
+	mu     sync.Mutex
+	tokens int
+	cost   float64
+}
+
+// useLLMMeter records the usage of the requests the repository makes for the task,
+// the budget applies to this run, a retried task starts again from zero.
+func useLLMMeter(r *analyzer.Repository, taskId, repoId uint) {
+	llm := config.GetLLMConfig()
+	r.SetMeter(&taskMeter{
+		taskId:  taskId,
+		repoId:  repoId,
+		pricing: llm.Pricing,
+		budget:  llm.Budget,
+		dao:     dao.NewLLMUsageDao(),
+	})
+}
+
+func (m *taskMeter) Allow() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.budget.MaxTokens > 0 && m.tokens >= m.budget.MaxTokens {
+		return fmt.Errorf("%w: %d of %d tokens used", ErrBudgetExceeded, m.tokens, m.budget.MaxTokens)
+	}
+	if m.budget.MaxCost > 0 && m.cost >= m.budget.MaxCost {
+		return fmt.Errorf("%w: %.4f of %.4f spent", ErrBudgetExceeded, m.cost, m.budget.MaxCost)
+	}
+	return nil
+}
+
+func (m *taskMeter) Record(stage, model string, usage chat.Usage) {
+	var cost float64
+	if price, ok := m.price(model); ok {
+		cost = (float64(usage.PromptTokens)*price.Input + float64(usage.CompletionTokens)*price.Output) / 1e6
+	}
+
+	err := m.dao.CreateUsage(&models.LLMUsage{
+		TaskId:           m.taskId,
+		RepoId:           m.repoId,
+		Stage:            stage,
+		ModelLLM:         model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Estimated:        usage.Estimated,
+		Cost:             cost,
+	})
+	if err != nil {
+		zap.L().Warn("Failed to record llm usage", zap.Uint("task_id", m.taskId), zap.Error(err))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens += usage.PromptTokens + usage.CompletionTokens
+	m.cost += cost
+}
+
+// price looks up the model with its provider name first, e.g. default/gpt-4o, then without it.
+func (m *taskMeter) price(model string) (config.LLMPrice, bool) {
+	if price, ok := m.pricing[model]; ok {
+		return price, true
+	}
+	if _, name, ok := strings.Cut(model, "/"); ok {
+		price, ok := m.pricing[name]
+		return price, ok
+	}
+	return config.LLMPrice{}, false
+}
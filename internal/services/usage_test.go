@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/o0olele/opendeepwiki-go/internal/config"
+	"github.com/o0olele/opendeepwiki-go/internal/database"
+	"github.com/o0olele/opendeepwiki-go/internal/database/dao"
+	"github.com/o0olele/opendeepwiki-go/internal/database/models"
+	"github.com/o0olele/opendeepwiki-go/internal/llm/chat"
+)
+
+func TestTaskMeterPrice(t *testing.T) {
+	meter := &taskMeter{pricing: map[string]config.LLMPrice{
+		"gpt-4o":       {Input: 2.5, Output: 10},
+		"local/gpt-4o": {Input: 0, Output: 0.1},
+	}}
+
+	tests := []struct {
+		model string
+		want  config.LLMPrice
+		found bool
+	}{
+		{"local/gpt-4o", config.LLMPrice{Input: 0, Output: 0.1}, true},
+		{"default/gpt-4o", config.LLMPrice{Input: 2.5, Output: 10}, true},
+		{"gpt-4o", config.LLMPrice{Input: 2.5, Output: 10}, true},
+		{"default/gpt-4o-mini", config.LLMPrice{}, false},
+		{"qwen3", config.LLMPrice{}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.model, func(t *testing.T) {
+			price, ok := meter.price(test.model)
+			if ok != test.found || price != test.want {
+				t.Fatalf("got %+v and found %v, want %+v and %v", price, ok, test.want, test.found)
+			}
+		})
+	}
+}
+
+func TestTaskMeterBudget(t *testing.T) {
+	const taskId, repoId = 9201, 9202
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Where("task_id = ?", taskId).Delete(&models.LLMUsage{})
+	})
+	pricing := map[string]config.LLMPrice{"gpt-4o": {Input: 2, Output: 10}}
+
+	tests := []struct {
+		name    string
+		budget  config.LLMBudgetConfig
+		usages  []chat.Usage // recorded before the next request
+		allowed []bool       // Allow before each usage and after the last one
+	}{
+		{"unlimited", config.LLMBudgetConfig{}, []chat.Usage{{PromptTokens: 1e6, CompletionTokens: 1e6}}, []bool{true, true}},
+		{"below the token limit", config.LLMBudgetConfig{MaxTokens: 1000}, []chat.Usage{{PromptTokens: 400, CompletionTokens: 100}, {PromptTokens: 400, CompletionTokens: 99}}, []bool{true, true, true}},
+		{"token limit reached", config.LLMBudgetConfig{MaxTokens: 1000}, []chat.Usage{{PromptTokens: 400, CompletionTokens: 100}, {PromptTokens: 400, CompletionTokens: 100}}, []bool{true, true, false}},
+		// 0.5 million prompt tokens cost 1, 0.1 million completion tokens cost 1
+		{"below the cost limit", config.LLMBudgetConfig{MaxCost: 3}, []chat.Usage{{PromptTokens: 500000, CompletionTokens: 100000}}, []bool{true, true}},
+		{"cost limit reached", config.LLMBudgetConfig{MaxCost: 3}, []chat.Usage{{PromptTokens: 500000, CompletionTokens: 100000}, {PromptTokens: 500000}}, []bool{true, true, false}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			meter := &taskMeter{taskId: taskId, repoId: repoId, pricing: pricing, budget: test.budget, dao: dao.NewLLMUsageDao()}
+			for idx, allowed := range test.allowed {
+				err := meter.Allow()
+				if allowed != (err == nil) {
+					t.Fatalf("request %d allowed %v, want %v: %v", idx, err == nil, allowed, err)
+				}
+				if err != nil && !errors.Is(err, ErrBudgetExceeded) {
+					t.Fatalf("request %d refused with %v, want ErrBudgetExceeded", idx, err)
+				}
+				if idx < len(test.usages) {
+					meter.Record("document", "default/gpt-4o", test.usages[idx])
+				}
+			}
+		})
+	}
+
+	// every request is recorded with its cost
+	summaries, err := dao.NewLLMUsageDao().SummarizeUsage(repoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 12 + 0.00359 + 0.0036 + 2 + 3; summaries[0].Requests != 8 || math.Abs(summaries[0].Cost-want) > 1e-9 {
+		t.Fatalf("recorded %+v, want 8 requests costing %v", summaries[0], want)
+	}
+}
+
+func TestTaskProcessFailsOverBudget(t *testing.T) {
+	if *recordFixtures {
+		t.Skip("fixtures are being recorded")
+	}
+	useSampleLLM(t, sampleFixtures)
+	llm := *config.GetLLMConfig()
+	llm.Budget.MaxTokens = 1
+	config.SetLLMConfig(llm)
+	task, params, repoModel := newSampleTask(t, "https://github.com/example/sample.git")
+	t.Cleanup(func() {
+		database.GetDB().Unscoped().Where("repo_id = ?", repoModel.ID).Delete(&models.LLMUsage{})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	task.Process(ctx, params)
+
+	modelTask, err := params.taskDao.GetRepositoryTaskByID(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modelTask.Status != models.RepositoryStatusFailed {
+		t.Fatalf("task is %s, want failed", modelTask.StatusString())
+	}
+	if !strings.Contains(modelTask.Errors, ErrBudgetExceeded.Error()) {
+		t.Fatalf("task error %q does not name the budget", modelTask.Errors)
+	}
+	// the first request spends the budget, the refused requests are neither sent nor retried
+	summaries, err := dao.NewLLMUsageDao().SummarizeUsage(repoModel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summaries[0].Requests != 1 {
+		t.Fatalf("%d requests recorded, want only the first one", summaries[0].Requests)
+	}
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsArchive reports whether the file name is a supported archive.
+func IsArchive(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".zip") ||
+		strings.HasSuffix(name, ".tar.gz") ||
+		strings.HasSuffix(name, ".tgz")
+}
+
+// ExtractArchive extracts a .zip, .tar.gz or .tgz file into dest.
+// Entries escaping dest and links are skipped, maxSize limits the extracted bytes.
+func ExtractArchive(src, name, dest string, maxSize int64) error {
+	if strings.HasSuffix(strings.ToLower(name), ".zip") {
+		return extractZip(src, dest, maxSize)
+	}
+	return extractTarGz(src, dest, maxSize)
+}
+
+// ArchiveRoot returns the single top level directory of an extracted archive, or dir itself.
+func ArchiveRoot(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		return dir
+	}
+	return filepath.Join(dir, entries[0].Name())
+}
+
+func extractZip(src, dest string, maxSize int64) error {
+	reader, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("open zip failed: %w", err)
+	}
+	defer reader.Close()
+
+	var total int64
+	for _, file := range reader.File {
+		target, ok := archiveTarget(dest, file.Name)
+		if !ok {
+			continue
+		}
+
+		if file.FileInfo().IsDir() {
+			if err = os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !file.Mode().IsRegular() {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		written, err := writeArchiveFile(target, rc, maxSize-total)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		total += written
+	}
+	return nil
+}
+
+func extractTarGz(src, dest string, maxSize int64) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("open gzip failed: %w", err)
+	}
+	defer gz.Close()
+
+	var total int64
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar failed: %w", err)
+		}
+
+		target, ok := archiveTarget(dest, header.Name)
+		if !ok {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			written, err := writeArchiveFile(target, reader, maxSize-total)
+			if err != nil {
+				return err
+			}
+			total += written
+		}
+	}
+}
+
+// archiveTarget returns the extraction path of an entry, false if it escapes dest.
+func archiveTarget(dest, name string) (string, bool) {
+	target := filepath.Join(dest, filepath.FromSlash(name))
+	if target != dest && !strings.HasPrefix(target, filepath.Clean(dest)+string(os.PathSeparator)) {
+		return "", false
+	}
+	return target, true
+}
+
+func writeArchiveFile(target string, r io.Reader, remaining int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, err
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	// read one byte more than allowed to detect oversized archives
+	written, err := io.Copy(out, io.LimitReader(r, remaining+1))
+	if err != nil {
+		return written, err
+	}
+	if written > remaining {
+		return written, fmt.Errorf("archive exceeds the size limit")
+	}
+	return written, nil
+}
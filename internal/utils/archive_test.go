@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// archiveEntry is an entry of a test archive, link is the target of a symlink.
+type archiveEntry struct {
+	name    string
+	content string
+	link    string
+}
+
+func writeZip(t *testing.T, path string, entries []archiveEntry) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writer := zip.NewWriter(file)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		content := entry.content
+		if len(entry.link) > 0 {
+			header.SetMode(os.ModeSymlink | 0777)
+			content = entry.link
+		}
+		w, err := writer.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTarGz(t *testing.T, path string, entries []archiveEntry) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	writer := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content)), Typeflag: tar.TypeReg}
+		switch {
+		case len(entry.link) > 0:
+			header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, entry.link, 0
+		case strings.HasSuffix(entry.name, "/"):
+			header.Typeflag, header.Mode = tar.TypeDir, 0755
+		}
+		if err = writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err = writer.Write([]byte(entry.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []archiveEntry
+		maxSize int64
+		want    map[string]string // extracted files and their content
+		absent  []string          // paths relative to dest that must not exist
+		escaped []string          // paths relative to the parent of dest that must not exist
+		wantErr bool
+	}{
+		{
+			name: "files and directories",
+			entries: []archiveEntry{
+				{name: "project/"},
+				{name: "project/README.md", content: "# Project"},
+				{name: "project/src/main.go", content: "package main"},
+			},
+			maxSize: 1024,
+			want:    map[string]string{"project/README.md": "# Project", "project/src/main.go": "package main"},
+		},
+		{
+			name: "zip slip",
+			entries: []archiveEntry{
+				{name: "../evil.txt", content: "evil"},
+				{name: "project/../../evil2.txt", content: "evil"},
+				{name: "project/ok.txt", content: "ok"},
+			},
+			maxSize: 1024,
+			want:    map[string]string{"project/ok.txt": "ok"},
+			escaped: []string{"evil.txt", "evil2.txt"},
+		},
+		{
+			name: "absolute path",
+			entries: []archiveEntry{
+				{name: "/etc/evil.txt", content: "evil"},
+			},
+			maxSize: 1024,
+			want:    map[string]string{"etc/evil.txt": "evil"},
+		},
+		{
+			name: "symlinks",
+			entries: []archiveEntry{
+				{name: "project/passwd", link: "/etc/passwd"},
+				{name: "project/up", link: "../.."},
+				{name: "project/up/evil.txt", content: "evil"},
+				{name: "project/ok.txt", content: "ok"},
+			},
+			maxSize: 1024,
+			want:    map[string]string{"project/ok.txt": "ok", "project/up/evil.txt": "evil"},
+			absent:  []string{"project/passwd"},
+			escaped: []string{"evil.txt"},
+		},
+		{
+			name: "size limit",
+			entries: []archiveEntry{
+				{name: "a.txt", content: strings.Repeat("a", 60)},
+				{name: "b.txt", content: strings.Repeat("b", 60)},
+			},
+			maxSize: 100,
+			wantErr: true,
+		},
+		{
+			name: "exactly the size limit",
+			entries: []archiveEntry{
+				{name: "a.txt", content: strings.Repeat("a", 60)},
+				{name: "b.txt", content: strings.Repeat("b", 40)},
+			},
+			maxSize: 100,
+			want:    map[string]string{"a.txt": strings.Repeat("a", 60), "b.txt": strings.Repeat("b", 40)},
+		},
+	}
+
+	formats := []struct {
+		name  string
+		write func(*testing.T, string, []archiveEntry)
+	}{
+		{"upload.zip", writeZip},
+		{"upload.tar.gz", writeTarGz},
+	}
+	for _, format := range formats {
+		for _, test := range tests {
+			t.Run(format.name+"/"+test.name, func(t *testing.T) {
+				dir := t.TempDir()
+				src := filepath.Join(dir, format.name)
+				format.write(t, src, test.entries)
+				dest := filepath.Join(dir, "uploads", "upload")
+
+				err := ExtractArchive(src, format.name, dest, test.maxSize)
+				if test.wantErr {
+					if err == nil {
+						t.Fatal("extracted an archive over the size limit")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("ExtractArchive failed: %v", err)
+				}
+
+				for name, content := range test.want {
+					data, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+					if err != nil || string(data) != content {
+						t.Fatalf("got %s %q, %v, want %q", name, data, err, content)
+					}
+				}
+				for _, name := range test.absent {
+					if _, err := os.Lstat(filepath.Join(dest, filepath.FromSlash(name))); err == nil {
+						t.Fatalf("%s was extracted", name)
+					}
+				}
+				for _, name := range test.escaped {
+					for _, parent := range []string{filepath.Dir(dest), dir} {
+						if _, err := os.Lstat(filepath.Join(parent, name)); err == nil {
+							t.Fatalf("%s escaped into %s", name, parent)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestArchiveRoot(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"single directory", []string{"project/README.md"}, "project"},
+		{"several entries", []string{"project/README.md", "LICENSE"}, ""},
+		{"single file", []string{"README.md"}, ""},
+		{"empty", nil, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, file := range test.files {
+				path := filepath.Join(dir, filepath.FromSlash(file))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := ArchiveRoot(dir); got != filepath.Join(dir, test.want) {
+				t.Fatalf("got %s, want %s", got, filepath.Join(dir, test.want))
+			}
+		})
+	}
+}
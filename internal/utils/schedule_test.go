@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// a monday
+	monday := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name  string
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{"interval", "6h", monday, monday.Add(6 * time.Hour)},
+		{"minimum interval", "5m", monday, monday.Add(5 * time.Minute)},
+		{"every five minutes", "*/5 * * * *", monday.Add(20 * time.Second), at(1, 15, 10, 35)},
+		{"step", "*/15 * * * *", monday, at(1, 15, 10, 45)},
+		{"step from a value", "5/20 * * * *", monday, at(1, 15, 10, 45)},
+		{"range with step", "10-20/5 8 * * *", monday, at(1, 16, 8, 10)},
+		{"list", "0 0,12 * * *", monday, at(1, 15, 12, 0)},
+		{"minutes far apart in one hour", "0,58 3 * * *", at(1, 15, 3, 0), at(1, 15, 3, 58)},
+		{"weekdays", "0 3 * * 1-5", monday, at(1, 16, 3, 0)},
+		{"weekdays over the weekend", "0 3 * * 1-5", at(1, 19, 10, 0), at(1, 22, 3, 0)},
+		{"day of month", "0 0 13 * *", monday, at(2, 13, 0, 0)},
+		{"day of month or friday", "0 0 13 * 5", monday, at(1, 19, 0, 0)},
+		{"day of month before friday", "0 0 13 * 5", at(2, 10, 0, 0), at(2, 13, 0, 0)},
+		{"sunday as 0", "0 0 * * 0", monday, at(1, 21, 0, 0)},
+		{"sunday as 7", "0 0 * * 7", monday, at(1, 21, 0, 0)},
+		{"month", "30 6 1 3 *", monday, at(3, 1, 6, 30)},
+		{"leap day", "0 0 29 2 *", at(3, 1, 0, 0), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", "@hourly", monday, at(1, 15, 11, 0)},
+		{"@daily", "@daily", monday, at(1, 16, 0, 0)},
+		{"@weekly", "@weekly", monday, at(1, 21, 0, 0)},
+		{"@monthly", "@monthly", monday, at(2, 1, 0, 0)},
+		{"spaces", "  @daily ", monday, at(1, 16, 0, 0)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(test.spec)
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q) failed: %v", test.spec, err)
+			}
+			if got := schedule.Next(test.after); !got.Equal(test.want) {
+				t.Fatalf("Next(%s) = %s, want %s", test.after, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"1m",
+		"4m59s",
+		"* * * * *",
+		"*/1 * * * *",
+		"*/4 * * * *",
+		"0,3 * * * *",
+		"0-2 3 * * *",
+		"0,58 * * * *",
+		"0,58 3,4 * * *",
+		"0,58 0,23 * * *",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-b * * * *",
+		"@yearly",
+		"0 0 30 2 *",
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded", spec)
+		}
+	}
+}